@@ -0,0 +1,261 @@
+// Command cli plays Snake & Ladder in a terminal, on the same engine, board
+// files and seeds as the server.
+//
+//	go run ./cmd/cli                      # asks for the board size and players
+//	go run ./cmd/cli --grid 8 --seed 42   # replays the board and dice of seed 42
+//	go run ./cmd/cli --board boards/track.json
+//	go run ./cmd/cli --auto --json --seed 42 --players Arun,Megha --rules pawns=2
+//	go run ./cmd/cli --server http://localhost:8080 --game 3 --name Arun
+//	go run ./cmd/cli render --seed 42 -o board.png
+//
+// On a terminal the game is played full screen; --ui plain keeps to lines of
+// text, as does a dumb terminal or redirected input. With --server the player
+// takes one seat at a game on a server, shared with browser and other
+// terminal players, in lines of text. --auto plays a whole game unattended,
+// as text or, with --json, as one JSON event per line. The render command
+// draws a board, or a saved game, as SVG or PNG.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// maxGrid bounds the board side, as the server's default max_grid does;
+// defaultGrid is the side of unattended games given no board.
+const (
+	maxGrid     = 100
+	defaultGrid = 10
+)
+
+// options are the command line settings.
+type options struct {
+	grid   int
+	layout string
+	board  string
+	seed   int64
+	seeded bool
+	rules  game.Rules
+	// players are seated without asking; auto plays every turn, json
+	// reports them as JSON lines.
+	players []seat
+	auto    bool
+	json    bool
+	ui      string
+	// server, game and name take a seat at a game on a server instead.
+	server string
+	game   string
+	name   string
+}
+
+// User interfaces.
+const (
+	uiAuto  = "auto"
+	uiFull  = "full"
+	uiPlain = "plain"
+)
+
+func main() { os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv)) }
+
+// run plays one game reading players' input from in, and returns the exit
+// status: 0 once the game is over, 1 for an invalid setup and 2 for bad flags.
+func run(args []string, in io.Reader, out, errOut io.Writer, getenv func(string) string) int {
+	if len(args) > 0 && args[0] == "render" { return renderBoard(args[1:], out, errOut) }
+	opts, err := parseFlags(args, errOut)
+	if errors.Is(err, flag.ErrHelp) { return 0 }
+	if err != nil { return 2 }
+	t := &terminal{in: bufio.NewReader(in), out: out}
+	if opts.auto {
+		g, err := setup(t, opts)
+		if err != nil {
+			fmt.Fprintln(errOut, "Invalid setup:", err)
+			return 1
+		}
+		if err := autoPlay(g, out, opts.json); err != nil {
+			fmt.Fprintln(errOut, err)
+			return 1
+		}
+		return 0
+	}
+	if opts.server != "" {
+		if err := playOnline(context.Background(), t, opts); err != nil {
+			fmt.Fprintln(errOut, err)
+			return 1
+		}
+		return 0
+	}
+	g, err := setup(t, opts)
+	if err != nil {
+		fmt.Fprintln(errOut, "Invalid setup:", err)
+		return 1
+	}
+	play := t.play
+	if u := fullScreen(opts.ui, in, out, getenv); u != nil {
+		u.in = t.in
+		play = u.play
+		if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+			restore, err := makeRaw(f.Fd())
+			if err != nil {
+				fmt.Fprintln(errOut, err)
+				return 1
+			}
+			defer restore()
+		}
+	}
+	if err := play(g); err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	return 0
+}
+
+func parseFlags(args []string, errOut io.Writer) (options, error) {
+	var opts options
+	fs := flag.NewFlagSet("cli", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.IntVar(&opts.grid, "grid", 0, "board side; asked for when neither --grid nor --board is given")
+	fs.StringVar(&opts.layout, "layout", "", "square numbering: serpentine (default) or row-major")
+	fs.StringVar(&opts.board, "board", "", "board file, as sent to the server")
+	seed := fs.String("seed", "", "seed fixing board generation and dice")
+	players := fs.String("players", "", "comma-separated players to seat without asking; name:team plays in teams")
+	rules := fs.String("rules", "", "comma-separated rules, as on the server: pawns=2,moveSeconds=10,collision=capture|swap,teamWin=all,undo=vote|off")
+	fs.BoolVar(&opts.auto, "auto", false, "play every turn unattended until someone wins; needs --players")
+	fs.BoolVar(&opts.json, "json", false, "with --auto, write JSON lines of game events")
+	fs.StringVar(&opts.ui, "ui", uiAuto, "auto (full screen on a terminal), full or plain")
+	fs.StringVar(&opts.server, "server", "", "play online on the server at this URL, such as http://localhost:8080")
+	fs.StringVar(&opts.game, "game", "", "with --server, the game to join; a new game is created when empty")
+	fs.StringVar(&opts.name, "name", "", "with --server, the name to play under, name:team in a team game; asked for when empty")
+	err := fs.Parse(args)
+	if err != nil { return opts, err }
+	if fs.NArg() > 0 {
+		fmt.Fprintf(errOut, "unexpected argument %q\n", fs.Arg(0))
+		return opts, errors.New("unexpected arguments")
+	}
+	if opts.ui != uiAuto && opts.ui != uiFull && opts.ui != uiPlain {
+		fmt.Fprintf(errOut, "invalid --ui %q: want auto, full or plain\n", opts.ui)
+		return opts, errors.New("invalid --ui")
+	}
+	if opts.server == "" && (opts.game != "" || opts.name != "") {
+		fmt.Fprintln(errOut, "--game and --name need --server")
+		return opts, errors.New("online flags without --server")
+	}
+	if opts.game != "" && (opts.grid != 0 || opts.layout != "" || opts.board != "" || *seed != "") {
+		fmt.Fprintln(errOut, "--grid, --layout, --board and --seed only apply to new games, not with --game")
+		return opts, errors.New("board flags with --game")
+	}
+	if *players != "" {
+		if opts.players, err = parsePlayers(*players); err != nil {
+			fmt.Fprintf(errOut, "invalid --players: %v\n", err)
+			return opts, err
+		}
+	}
+	if opts.rules, err = parseRules(*rules); err != nil {
+		fmt.Fprintf(errOut, "invalid --rules: %v\n", err)
+		return opts, err
+	}
+	switch {
+	case opts.json && !opts.auto:
+		err = errors.New("--json needs --auto")
+	case opts.auto && opts.players == nil:
+		err = errors.New("--auto needs --players")
+	case opts.auto && opts.server != "":
+		err = errors.New("--auto plays on this machine, not with --server")
+	}
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return opts, err
+	}
+	if *seed != "" {
+		if _, err := fmt.Sscan(*seed, &opts.seed); err != nil {
+			fmt.Fprintf(errOut, "invalid --seed %q\n", *seed)
+			return opts, err
+		}
+		opts.seeded = true
+	}
+	return opts, nil
+}
+
+// setup creates the game from opts, asking for what they leave out, and
+// seats the players.
+func setup(t *terminal, opts options) (*game.Game, error) {
+	def, err := boardDef(t, opts)
+	if err != nil { return nil, err }
+	var g *game.Game
+	if opts.seeded {
+		g, err = game.NewSeeded(def, opts.seed)
+	} else {
+		g, err = game.NewFromDef(def)
+	}
+	if err != nil { return nil, err }
+	if err := g.SetRules(opts.rules); err != nil { return nil, err }
+	if opts.players != nil {
+		for _, s := range opts.players {
+			if s.team != "" {
+				err = g.AddPlayerToTeam(s.name, s.team)
+			} else {
+				err = g.AddPlayer(s.name)
+			}
+			if err != nil { return nil, err }
+		}
+		return g, nil
+	}
+	n, err := t.askInt("Enter the number of players: ")
+	if err != nil { return nil, err }
+	if n < 2 { return nil, errors.New("show courtesy loner! Play with someone else") }
+	for i := 1; i <= n; {
+		name, err := t.ask(fmt.Sprintf("Enter Player %d name: ", i))
+		if err != nil { return nil, err }
+		err = g.AddPlayer(strings.TrimSpace(name))
+		// a blank or unprintable name is asked for again
+		if errors.Is(err, game.ErrInvalidName) {
+			fmt.Fprintf(t.out, "Name refused: %v.\n", err)
+			continue
+		}
+		if err != nil { return nil, err }
+		i++
+	}
+	return g, nil
+}
+
+func boardDef(t *terminal, opts options) (*game.BoardDef, error) {
+	if opts.board != "" { return game.LoadBoardDef(opts.board) }
+	grid := opts.grid
+	if grid == 0 && opts.auto { grid = defaultGrid }
+	if grid == 0 {
+		n, err := t.askInt("Enter the size of the grid: ")
+		if err != nil { return nil, err }
+		grid = n
+	}
+	if grid < 2 || grid > maxGrid { return nil, fmt.Errorf("grid size must be between 2 and %d", maxGrid) }
+	return &game.BoardDef{Width: grid, Height: grid, Layout: game.Layout(opts.layout)}, nil
+}
+
+// fullScreen returns the full-screen game for ui, or nil for plain text:
+// with ui auto, when in and out are both a terminal that is not dumb.
+// Colors follow NO_COLOR and Unicode the locale.
+func fullScreen(ui string, in io.Reader, out io.Writer, getenv func(string) string) *tui {
+	term := getenv("TERM")
+	fout, _ := out.(*os.File)
+	if ui == uiPlain { return nil }
+	if ui == uiAuto {
+		fin, ok := in.(*os.File)
+		if !ok || fout == nil || !isTerminal(fin.Fd()) || !isTerminal(fout.Fd()) || term == "" || term == "dumb" { return nil }
+	}
+	u := &tui{out: out, color: getenv("NO_COLOR") == "" && term != "dumb", glyphs: asciiGlyphs, frames: 8, frameDelay: 50 * time.Millisecond}
+	u.size = func() (int, int) { return 0, 0 }
+	if fout != nil { u.size = func() (int, int) { return termSize(fout.Fd()) } }
+	locale := getenv("LC_ALL")
+	if locale == "" { locale = getenv("LC_CTYPE") }
+	if locale == "" { locale = getenv("LANG") }
+	if l := strings.ToLower(locale); strings.Contains(l, "utf-8") || strings.Contains(l, "utf8") { u.glyphs = unicodeGlyphs }
+	return u
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// play runs the command with the given input and returns its exit status
+// and output.
+func play(t *testing.T, input string, args ...string) (int, string, string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	code := run(args, strings.NewReader(input), &out, &errOut, func(string) string { return "" })
+	return code, out.String(), errOut.String()
+}
+
+func TestPlaysTheServerGame(t *testing.T) {
+	input := "2\nArun\nMegha\n" + strings.Repeat("\n", 500)
+	code, out, errOut := play(t, input, "--grid", "8", "--seed", "7")
+	if code != 0 { t.Fatalf("exit %d: %s", code, errOut) }
+
+	// the same seed on the engine, as the server would create it
+	g, err := game.NewSeeded(&game.BoardDef{Width: 8, Height: 8}, 7)
+	if err != nil { t.Fatal(err) }
+	g.AddPlayer("Arun")
+	g.AddPlayer("Megha")
+	board := g.Snapshot().Board
+	if want := "Snakes: " + jumps(board.Snakes) + "\nLadders: " + jumps(board.Ladders) + "\n"; !strings.Contains(out, want) { t.Fatalf("board differs, want %q in:\n%s", want, out) }
+	for {
+		m, winner, err := g.Roll()
+		if err != nil { t.Fatal(err) }
+		if !strings.Contains(out, "]: "+describe(m)+"\n") { t.Fatalf("missing move %q in:\n%s", describe(m), out) }
+		if winner != nil {
+			if !strings.HasSuffix(out, "Player "+*winner+" has won the game\n") { t.Fatalf("wrong ending:\n%s", out) }
+			return
+		}
+	}
+}
+
+func TestBoardFile(t *testing.T) {
+	code, out, errOut := play(t, "2\nArun\nMegha\nq\n", "--board", "../../boards/track.json", "--seed", "1")
+	if code != 1 || !strings.Contains(errOut, "game abandoned") { t.Fatalf("exit %d: %s", code, errOut) }
+	if !strings.Contains(out, "Player Arun roll your dice") { t.Fatalf("game did not start:\n%s", out) }
+}
+
+func TestInvalidSetup(t *testing.T) {
+	for _, c := range []struct {
+		name, input string
+		args        []string
+		code        int
+		err         string
+	}{
+		{"grid too small", "1\n", nil, 1, "grid size"},
+		{"asks again for a number", "ten\n10\n1\n", nil, 1, "loner"},
+		{"duplicate names", "2\nArun\narun\n", []string{"--grid", "5"}, 1, "duplicate player name"},
+		{"input ends", "2\nArun\n", []string{"--grid", "5"}, 1, "unexpected EOF"},
+		{"unknown layout", "", []string{"--grid", "5", "--layout", "spiral"}, 1, "unknown layout"},
+		{"missing board file", "", []string{"--board", "nope.json"}, 1, "nope.json"},
+		{"bad seed", "", []string{"--seed", "x"}, 2, "invalid --seed"},
+		{"unknown flag", "", []string{"--bogus", "2"}, 2, "flag provided but not defined"},
+	} {
+		code, _, errOut := play(t, c.input, c.args...)
+		if code != c.code || !strings.Contains(errOut, c.err) { t.Errorf("%s: exit %d, %q; want %d and %q", c.name, code, errOut, c.code, c.err) }
+	}
+}
+
+func TestBlankNameAskedAgain(t *testing.T) {
+	code, out, errOut := play(t, "2\n \nArun\n\nMegha\nq\n", "--grid", "10", "--ui", "plain")
+	if code != 1 || !strings.Contains(errOut, "game abandoned") { t.Fatalf("exit %d: %s", code, errOut) }
+	if !strings.Contains(out, "Enter Player 1 name: Name refused: name is blank.\nEnter Player 1 name: ") || !strings.Contains(out, "Enter Player 2 name: Name refused: name is blank.\n") || !strings.Contains(out, "Player Arun roll") {
+		t.Fatalf("names not asked again:\n%s", out)
+	}
+}
+
+func TestBadPawnChoice(t *testing.T) {
+	// a's second roll needs a pawn choice; pawn 9 does not exist
+	code, out, errOut := play(t, "\n\n\n9\n1\nq\n", "--grid", "10", "--seed", "3", "--players", "a,b", "--rules", "pawns=2")
+	if code != 1 || !strings.Contains(errOut, "game abandoned") { t.Fatalf("exit %d: %s", code, errOut) }
+	want := "Which pawn moves 1, 2? Pawn 9 cannot move 1.\na rolled 1. Which pawn moves 1, 2? a rolled 1: 4 → 5\n"
+	if !strings.Contains(out, want) { t.Fatalf("expected the choice asked again, want %q in:\n%s", want, out) }
+}
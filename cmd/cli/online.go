@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arsulegai/snakeandladder/pkg/client"
+)
+
+// errGameGone ends an online game the server no longer streams.
+var errGameGone = errors.New("the server ended the game")
+
+// online is one seat at a game on a server, which others may play from
+// their browsers or terminals.
+type online struct {
+	t    *terminal
+	c    *client.Client
+	id   string
+	// name and, in a team game, team are the seat taken
+	name, team string
+	// seen counts the moves shown so far; prompted is the state last asked
+	// to roll or pick a pawn in, so the prompt is not repeated.
+	seen     int
+	prompted string
+}
+
+// playOnline joins, or creates and joins, the game on opts.server and plays
+// it as opts.name until someone wins or the player quits. A name given as
+// name:team joins that team, as with --players.
+func playOnline(ctx context.Context, t *terminal, opts options) error {
+	o := &online{t: t, c: client.New(opts.server), id: opts.game}
+	name := opts.name
+	if strings.TrimSpace(name) == "" {
+		var err error
+		if name, err = t.ask("Enter your name: "); err != nil { return err }
+	}
+	name, team, _ := strings.Cut(name, ":")
+	o.name, o.team = strings.TrimSpace(name), strings.TrimSpace(team)
+	if o.id == "" {
+		def, err := boardDef(t, opts)
+		if err != nil { return err }
+		create := client.GameOptions{Board: def, Rules: opts.rules}
+		if opts.seeded { create.Seed = &opts.seed }
+		if o.id, err = o.c.CreateGame(ctx, create); err != nil { return err }
+		fmt.Fprintf(t.out, "Created game %s; others join it with --game %s or from the browser.\n", o.id, o.id)
+	}
+	st, err := o.c.AddPlayerToTeam(ctx, o.id, o.name, o.team)
+	switch {
+	case errors.Is(err, client.ErrDuplicateName):
+		// back after losing the connection
+		fmt.Fprintf(t.out, "Rejoining game %s as %s.\n", o.id, o.name)
+		if st, err = o.c.State(ctx, o.id); err != nil { return err }
+	case err != nil:
+		return err
+	case o.team != "":
+		fmt.Fprintf(t.out, "Joined game %s as %s, in team %s.\n", o.id, o.name, o.team)
+	default:
+		fmt.Fprintf(t.out, "Joined game %s as %s.\n", o.id, o.name)
+	}
+	snap, err := o.c.Snapshot(ctx, o.id)
+	if err != nil { return err }
+	t.printBoard(snap.Board)
+	o.seen = st.Moves
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := o.c.Stream(ctx, o.id)
+	if err != nil { return err }
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for {
+			line, err := t.in.ReadString('\n')
+			if line != "" || err == nil {
+				select {
+				case lines <- strings.TrimRight(line, "\r\n"):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil { return }
+		}
+	}()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok { return errGameGone }
+			switch {
+			case ev.State != nil:
+				st = *ev.State
+				if done, err := o.update(ctx, st); done || err != nil { return err }
+			case ev.Name == "rollback" && ev.Move != nil:
+				fmt.Fprintln(t.out, "Took back: "+describe(*ev.Move))
+				o.seen, o.prompted = o.seen-1, ""
+			}
+		case line, ok := <-lines:
+			if !ok { return io.ErrUnexpectedEOF }
+			if strings.EqualFold(strings.TrimSpace(line), "q") { return errQuit }
+			if err := o.act(ctx, st, strings.TrimSpace(line)); err != nil { fmt.Fprintln(t.out, err) }
+		}
+	}
+}
+
+// update shows what changed in st and asks the player to act when it is
+// their turn. done is true once the game is won.
+func (o *online) update(ctx context.Context, st client.State) (done bool, err error) {
+	if st.Moves > o.seen {
+		moves := []client.Move{}
+		if st.Moves == o.seen+1 && st.LastMove != nil {
+			moves = append(moves, *st.LastMove)
+		} else if moves, err = o.c.Events(ctx, o.id, o.seen); err != nil {
+			return false, err
+		}
+		for _, m := range moves { fmt.Fprintln(o.t.out, describe(m)) }
+	}
+	o.seen = st.Moves
+	if st.Winner != nil {
+		fmt.Fprintf(o.t.out, "Player %s has won the game\n", *st.Winner)
+		return true, nil
+	}
+	var prompt string
+	switch {
+	case st.Pending != nil && strings.EqualFold(st.Pending.Player, o.name):
+		prompt = fmt.Sprintf("You rolled %d. Which pawn moves %s? ", st.Pending.Roll, pawnList(st.Pending.Pawns))
+	case st.Pending != nil:
+		prompt = fmt.Sprintf("Waiting for %s to pick a pawn...\n", st.Pending.Player)
+	case len(st.Players) < 2:
+		prompt = "Waiting for another player to join...\n"
+	case strings.EqualFold(st.Players[st.TurnIndex].Name, o.name):
+		prompt = "Your turn, roll your dice [press Enter, q to quit]: "
+	default:
+		prompt = fmt.Sprintf("Waiting for %s to roll...\n", st.Players[st.TurnIndex].Name)
+	}
+	if key := strconv.Itoa(st.Moves) + prompt; key != o.prompted {
+		fmt.Fprint(o.t.out, prompt)
+		o.prompted = key
+	}
+	return false, nil
+}
+
+// act handles a line typed by the player: rolling, or picking a pawn by its
+// number.
+func (o *online) act(ctx context.Context, st client.State, line string) error {
+	if st.Winner != nil { return nil }
+	if st.Pending != nil {
+		if !strings.EqualFold(st.Pending.Player, o.name) { return fmt.Errorf("waiting for %s to pick a pawn", st.Pending.Player) }
+		n, err := strconv.Atoi(line)
+		if err != nil { return fmt.Errorf("enter the number of the pawn to move: %s", pawnList(st.Pending.Pawns)) }
+		_, err = o.c.MovePawn(ctx, o.id, n-1)
+		return err
+	}
+	if len(st.Players) < 2 { return errors.New("waiting for another player to join") }
+	if who := st.Players[st.TurnIndex].Name; !strings.EqualFold(who, o.name) { return fmt.Errorf("it is %s's turn", who) }
+	_, err := o.c.Roll(ctx, o.id)
+	if errors.Is(err, client.ErrGameBusy) { return errors.New("the game is busy, press Enter to try again") }
+	return err
+}
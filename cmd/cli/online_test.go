@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+	"github.com/arsulegai/snakeandladder/pkg/client"
+)
+
+// startServer serves the parts of the server's API the online game uses,
+// over the same engine.
+func startServer(t *testing.T) *httptest.Server {
+	reg := game.NewRegistry()
+	reply := func(w http.ResponseWriter, v interface{}, err error) {
+		w.Header().Set("Content-Type", "application/json")
+		var ge *game.Error
+		if errors.As(err, &ge) {
+			w.WriteHeader(http.StatusConflict)
+			v = map[string]interface{}{"error": map[string]interface{}{"code": ge.Code, "message": ge.Message}}
+		}
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	withGame := func(h func(http.ResponseWriter, *http.Request, *game.Game)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			g, ok := reg.Get(r.PathValue("id"))
+			if !ok { http.NotFound(w, r); return }
+			h(w, r, g)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/games", func(w http.ResponseWriter, r *http.Request) {
+		def, err := game.ParseBoardDef(r.Body)
+		if err != nil { t.Errorf("board: %v", err) }
+		seed, _ := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64)
+		g, err := game.NewSeeded(def, seed)
+		if err != nil { t.Errorf("new game: %v", err) }
+		w.WriteHeader(http.StatusCreated)
+		reply(w, map[string]string{"id": reg.Add(g)}, nil)
+	})
+	mux.HandleFunc("POST /api/v1/games/{id}/players", withGame(func(w http.ResponseWriter, r *http.Request, g *game.Game) {
+		var body struct{ Name, Team string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		add := g.AddPlayer
+		if body.Team != "" { add = func(name string) error { return g.AddPlayerToTeam(name, body.Team) } }
+		err := add(body.Name)
+		reply(w, g.State(), err)
+	}))
+	mux.HandleFunc("POST /api/v1/games/{id}/roll", withGame(func(w http.ResponseWriter, r *http.Request, g *game.Game) {
+		m, _, err := g.Roll()
+		reply(w, map[string]interface{}{"move": m}, err)
+	}))
+	mux.HandleFunc("GET /api/v1/games/{id}/state", withGame(func(w http.ResponseWriter, r *http.Request, g *game.Game) { reply(w, g.State(), nil) }))
+	mux.HandleFunc("GET /api/v1/games/{id}/snapshot", withGame(func(w http.ResponseWriter, r *http.Request, g *game.Game) { reply(w, g.Snapshot(), nil) }))
+	mux.HandleFunc("GET /api/v1/games/{id}/events", withGame(func(w http.ResponseWriter, r *http.Request, g *game.Game) {
+		since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+		reply(w, g.History()[since:], nil)
+	}))
+	mux.HandleFunc("GET /api/v1/games/{id}/stream", withGame(func(w http.ResponseWriter, r *http.Request, g *game.Game) { g.Subscribe(w, r) }))
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// screen collects output written from several goroutines.
+type screen struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *screen) Write(p []byte) (int, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *screen) String() string {
+	s.mu.Lock(); defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// waitFor waits until the output holds want n times.
+func (s *screen) waitFor(t *testing.T, want string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); strings.Count(s.String(), want) < n; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) { t.Fatalf("no %q (%d) in:\n%s", want, n, s.String()) }
+	}
+}
+
+func TestOnlineGame(t *testing.T) {
+	ts := startServer(t)
+	in, keys := io.Pipe()
+	var out, errOut screen
+	done := make(chan int)
+	go func() { done <- run([]string{"--server", ts.URL, "--name", "Arun", "--grid", "8", "--seed", "7"}, in, &out, &errOut, func(string) string { return "" }) }()
+	out.waitFor(t, "Waiting for another player to join...", 1)
+
+	// Megha plays from the browser, rolling on her turns
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := client.New(ts.URL)
+	if _, err := c.AddPlayer(ctx, "1", "Megha"); err != nil { t.Fatal(err) }
+	events, err := c.Stream(ctx, "1")
+	if err != nil { t.Fatal(err) }
+	go func() {
+		for ev := range events {
+			if st := ev.State; st != nil && st.Winner == nil && len(st.Players) == 2 && st.TurnIndex == 1 { _, _ = c.Roll(ctx, "1") }
+		}
+	}()
+	// Arun rolls whenever asked, until someone wins
+	for turns := 1; ; turns++ {
+		deadline := time.Now().Add(5 * time.Second)
+		for strings.Count(out.String(), "Your turn") < turns && !strings.Contains(out.String(), "has won the game") {
+			if time.Now().After(deadline) { t.Fatalf("stuck at turn %d:\n%s", turns, out.String()) }
+			time.Sleep(5 * time.Millisecond)
+		}
+		if strings.Contains(out.String(), "has won the game") { break }
+		_, _ = keys.Write([]byte("\n"))
+	}
+	if code := <-done; code != 0 { t.Fatalf("exit %d: %s", code, errOut.String()) }
+
+	text := out.String()
+	if !strings.Contains(text, "Created game 1; others join it with --game 1") || !strings.Contains(text, "Joined game 1 as Arun.") { t.Fatalf("no game created:\n%s", text) }
+	st, _ := c.State(ctx, "1")
+	moves, _ := c.Events(ctx, "1", 0)
+	for _, m := range moves {
+		if !strings.Contains(text, describe(m)+"\n") { t.Fatalf("move %q not shown:\n%s", describe(m), text) }
+	}
+	if !strings.HasSuffix(text, "Player "+*st.Winner+" has won the game\n") { t.Fatalf("wrong ending:\n%s", text) }
+}
+
+func TestOnlineRejoinAndTurns(t *testing.T) {
+	ts := startServer(t)
+	c := client.New(ts.URL)
+	ctx := context.Background()
+	id, _ := c.CreateGame(ctx, client.GameOptions{Board: &game.BoardDef{Width: 6, Height: 6}})
+	c.AddPlayer(ctx, id, "Arun")
+	c.AddPlayer(ctx, id, "Megha")
+
+	in, keys := io.Pipe()
+	var out, errOut screen
+	done := make(chan int)
+	go func() { done <- run([]string{"--server", ts.URL, "--game", id}, in, &out, &errOut, func(string) string { return "" }) }()
+	out.waitFor(t, "Enter your name: ", 1)
+	_, _ = keys.Write([]byte("megha\n"))
+	out.waitFor(t, "Waiting for Arun to roll...", 1)
+	if !strings.Contains(out.String(), "Rejoining game "+id+" as megha.") { t.Fatalf("not rejoined:\n%s", out.String()) }
+	_, _ = keys.Write([]byte("\n"))
+	out.waitFor(t, "it is Arun's turn", 1)
+	if _, err := c.Roll(ctx, id); err != nil { t.Fatal(err) }
+	out.waitFor(t, "Your turn", 1)
+	if !strings.Contains(out.String(), "Arun rolled") { t.Fatalf("Arun's move not shown:\n%s", out.String()) }
+	_, _ = keys.Write([]byte("q\n"))
+	if code := <-done; code != 1 || !strings.Contains(errOut.String(), "game abandoned") { t.Fatalf("quit: exit %d %s", code, errOut.String()) }
+}
+
+func TestOnlineTeamGame(t *testing.T) {
+	ts := startServer(t)
+	c := client.New(ts.URL)
+	ctx := context.Background()
+	id, _ := c.CreateGame(ctx, client.GameOptions{Board: &game.BoardDef{Width: 6, Height: 6}})
+	if _, err := c.AddPlayerToTeam(ctx, id, "Arun", "Red"); err != nil { t.Fatal(err) }
+
+	in, keys := io.Pipe()
+	var out, errOut screen
+	done := make(chan int)
+	go func() { done <- run([]string{"--server", ts.URL, "--game", id, "--name", "Megha:Blue"}, in, &out, &errOut, func(string) string { return "" }) }()
+	out.waitFor(t, "Joined game "+id+" as Megha, in team Blue.", 1)
+	if st, _ := c.State(ctx, id); len(st.Players) != 2 || st.Players[1].Team != "Blue" { t.Fatalf("Megha not seated in Blue: %+v", st.Players) }
+	_, _ = keys.Write([]byte("q\n"))
+	if code := <-done; code != 1 { t.Fatalf("quit: exit %d %s", code, errOut.String()) }
+
+	// without a team the server turns the terminal player away
+	code, _, errOut2 := play(t, "", "--server", ts.URL, "--game", id, "--name", "Ravi")
+	if code != 1 || !strings.Contains(errOut2, "team") { t.Fatalf("joined a team game without a team: exit %d %s", code, errOut2) }
+}
+
+func TestOnlineFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"--game", "1"},
+		{"--name", "Arun"},
+		{"--server", "http://localhost:1", "--game", "1", "--grid", "8"},
+	} {
+		if code, _, _ := play(t, "", args...); code != 2 { t.Errorf("%v: exit %d, want 2", args, code) }
+	}
+	if code, _, errOut := play(t, "", "--server", "http://127.0.0.1:1", "--game", "1", "--name", "Arun"); code != 1 || !strings.Contains(errOut, "connect") { t.Errorf("no server: exit %d %s", code, errOut) }
+}
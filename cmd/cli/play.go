@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// errQuit ends the game at a player's request.
+var errQuit = errors.New("game abandoned")
+
+// terminal is the players' side of the game: prompts on out, answers on in.
+type terminal struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints prompt and returns the next line of input.
+func (t *terminal) ask(prompt string) (string, error) {
+	fmt.Fprint(t.out, prompt)
+	line, err := t.in.ReadString('\n')
+	if err == io.EOF && line == "" { return "", io.ErrUnexpectedEOF }
+	if err != nil && err != io.EOF { return "", err }
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (t *terminal) askInt(prompt string) (int, error) {
+	for {
+		line, err := t.ask(prompt)
+		if err != nil { return 0, err }
+		if n, err := strconv.Atoi(strings.TrimSpace(line)); err == nil { return n, nil }
+		fmt.Fprintln(t.out, "Please enter a number.")
+	}
+}
+
+// play runs turns until someone wins or a player quits.
+func (t *terminal) play(g *game.Game) error {
+	t.printBoard(g.Snapshot().Board)
+	for {
+		st := g.State()
+		if st.Winner != nil {
+			fmt.Fprintf(t.out, "Player %s has won the game\n", *st.Winner)
+			return nil
+		}
+		line, err := t.ask(fmt.Sprintf("Player %s roll your dice [press Enter, q to quit]: ", st.Players[st.TurnIndex].Name))
+		if err != nil { return err }
+		if strings.EqualFold(strings.TrimSpace(line), "q") { return errQuit }
+		m, _, err := g.Roll()
+		if err != nil { return err }
+		// the roll waiting for a choice stays put until a pawn can move
+		for pending := m; pending.Choices != nil; pending = m {
+			pawn, err := t.askInt(fmt.Sprintf("%s rolled %d. Which pawn moves %s? ", pending.Player, pending.Roll, pawnList(pending.Choices)))
+			if err != nil { return err }
+			moved, _, err := g.MovePawn(pawn - 1)
+			if errors.Is(err, game.ErrPawnCannotMove) {
+				fmt.Fprintf(t.out, "Pawn %d cannot move %d.\n", pawn, pending.Roll)
+				continue
+			}
+			if errors.Is(err, game.ErrNoPawnChoice) {
+				// the move deadline picked a pawn meanwhile
+				if last := g.State().LastMove; last != nil { m = *last }
+				m.Choices = nil
+				break
+			}
+			if err != nil { return err }
+			m = moved
+		}
+		fmt.Fprintln(t.out, describe(m))
+	}
+}
+
+// printBoard lists the snakes, ladders and special squares.
+func (t *terminal) printBoard(def game.BoardDef) {
+	fmt.Fprintln(t.out, "Snakes:", jumps(def.Snakes))
+	fmt.Fprintln(t.out, "Ladders:", jumps(def.Ladders))
+	for _, tile := range def.Tiles {
+		if tile.Effect == game.TileTeleport {
+			fmt.Fprintf(t.out, "Square %d: %s to %d\n", tile.Square, tile.Effect, tile.Target)
+		} else {
+			fmt.Fprintf(t.out, "Square %d: %s\n", tile.Square, tile.Effect)
+		}
+	}
+}
+
+func jumps(js []game.Jump) string {
+	if len(js) == 0 { return "none" }
+	parts := make([]string, len(js))
+	for i, j := range js { parts[i] = fmt.Sprintf("%d→%d", j.From, j.To) }
+	return strings.Join(parts, ", ")
+}
+
+// describe tells what a move did, in one line.
+func describe(m game.Move) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s rolled %d", m.Player, m.Roll)
+	switch {
+	case !m.Moved:
+		b.WriteString(": too far, no move")
+	case m.From == 0:
+		fmt.Fprintf(&b, ": start → %d", m.Landed)
+	default:
+		fmt.Fprintf(&b, ": %d → %d", m.From, m.Landed)
+	}
+	for _, s := range m.Steps { b.WriteString(", " + describeStep(s)) }
+	return b.String()
+}
+
+func describeStep(s game.Step) string {
+	switch s.Kind {
+	case game.StepSnake:
+		return fmt.Sprintf("bitten by the snake on %d, down to %d", s.From, s.To)
+	case game.StepLadder:
+		return fmt.Sprintf("up the ladder on %d to %d", s.From, s.To)
+	case game.StepTeleport:
+		return fmt.Sprintf("teleported from %d to %d", s.From, s.To)
+	case game.StepShield:
+		return fmt.Sprintf("the shield stopped the snake on %d", s.From)
+	case game.StepGainShield:
+		return "picked up a shield"
+	case game.StepSkipTurn:
+		return "will miss the next turn"
+	case game.StepExtraRoll:
+		return "rolls again"
+	case game.StepSwap:
+		return fmt.Sprintf("swapped places with %s (%d ↔ %d)", s.Player, s.From, s.To)
+	case game.StepSkipped:
+		return s.Player + " misses this turn"
+	case game.StepCapture:
+		return fmt.Sprintf("sent %s back to the start", s.Player)
+	case game.StepBump:
+		return fmt.Sprintf("bumped %s back to %d", s.Player, s.To)
+	}
+	return string(s.Kind)
+}
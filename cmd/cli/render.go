@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+	"github.com/arsulegai/snakeandladder/internal/render"
+)
+
+// renderBoard is the render command: it draws the board --grid, --layout,
+// --board and --seed describe, as a game would be set up, or the game saved
+// in a --snapshot with its pawns, as SVG or PNG. The exit status is as for
+// run.
+func renderBoard(args []string, out, errOut io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("cli render", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.IntVar(&opts.grid, "grid", defaultGrid, "board side")
+	fs.StringVar(&opts.layout, "layout", "", "square numbering: serpentine (default) or row-major")
+	fs.StringVar(&opts.board, "board", "", "board file, as sent to the server")
+	fs.Int64Var(&opts.seed, "seed", 0, "seed fixing board generation, as for a game")
+	snapshot := fs.String("snapshot", "", "game snapshot, as exported by the server, to draw with its pawns")
+	cell := fs.Int("cell", 0, fmt.Sprintf("side of a square in pixels, %d to %d (default %d)", render.MinCell, render.MaxCell, render.DefaultCell))
+	format := fs.String("format", "", "svg or png; taken from the -o extension when empty, svg otherwise")
+	file := fs.String("o", "", "file to write instead of standard output")
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) { return 0 }
+	if err != nil { return 2 }
+	if fs.NArg() > 0 {
+		fmt.Fprintf(errOut, "unexpected argument %q\n", fs.Arg(0))
+		return 2
+	}
+	boardFlag := ""
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			opts.seeded = true
+			fallthrough
+		case "grid", "layout", "board":
+			boardFlag = f.Name
+		}
+	})
+	if *format == "" {
+		*format = "svg"
+		if strings.EqualFold(filepath.Ext(*file), ".png") { *format = "png" }
+	}
+	draw := map[string]func(io.Writer, *render.Board, render.Options) error{"svg": render.SVG, "png": render.PNG}[*format]
+	switch {
+	case draw == nil:
+		err = fmt.Errorf("invalid --format %q: want svg or png", *format)
+	case *snapshot != "" && boardFlag != "":
+		err = fmt.Errorf("--%s describes a new board, not with --snapshot", boardFlag)
+	case opts.board == "" && (opts.grid < 2 || opts.grid > maxGrid):
+		err = fmt.Errorf("--grid must be between 2 and %d", maxGrid)
+	case *cell != 0 && (*cell < render.MinCell || *cell > render.MaxCell):
+		err = fmt.Errorf("--cell must be between %d and %d", render.MinCell, render.MaxCell)
+	}
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 2
+	}
+
+	g, err := renderedGame(opts, *snapshot)
+	if err != nil {
+		fmt.Fprintln(errOut, "Invalid board:", err)
+		return 1
+	}
+	var buf bytes.Buffer
+	if err := draw(&buf, render.FromGame(g, true), render.Options{Cell: *cell}); err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	if *file == "" {
+		_, err = out.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*file, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	return 0
+}
+
+// renderedGame is the game whose board gets drawn.
+func renderedGame(opts options, snapshot string) (*game.Game, error) {
+	if snapshot != "" {
+		data, err := os.ReadFile(snapshot)
+		if err != nil { return nil, err }
+		var snap game.Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil { return nil, fmt.Errorf("invalid snapshot: %v", err) }
+		return game.FromSnapshot(snap)
+	}
+	def, err := boardDef(nil, opts)
+	if err != nil { return nil, err }
+	if opts.seeded { return game.NewSeeded(def, opts.seed) }
+	return game.NewFromDef(def)
+}
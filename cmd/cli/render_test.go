@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+func TestRenderBoard(t *testing.T) {
+	code, svg, errOut := play(t, "", "render", "--grid", "6", "--seed", "7", "--cell", "20")
+	if code != 0 { t.Fatalf("exit %d: %s", code, errOut) }
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="120" height="120"`) || !strings.Contains(svg, ">36</text>") { t.Fatalf("unexpected SVG:\n%.300s", svg) }
+	// the seed fixes the board
+	if _, again, _ := play(t, "", "render", "--grid", "6", "--seed", "7", "--cell", "20"); again != svg { t.Fatal("the same seed drew another board") }
+
+	// a saved game is drawn with its pawns, as PNG after the file name
+	g := seededGame(t, game.Rules{}, "Arun", "Megha")
+	for i := 0; i < 6; i++ { _, _, _ = g.Roll() }
+	dir := t.TempDir()
+	snap, _ := json.Marshal(g.Snapshot())
+	if err := os.WriteFile(filepath.Join(dir, "game.json"), snap, 0o644); err != nil { t.Fatal(err) }
+	code, _, errOut = play(t, "", "render", "--snapshot", filepath.Join(dir, "game.json"), "-o", filepath.Join(dir, "board.PNG"))
+	if code != 0 { t.Fatalf("exit %d: %s", code, errOut) }
+	f, err := os.Open(filepath.Join(dir, "board.PNG"))
+	if err != nil { t.Fatal(err) }
+	defer f.Close()
+	if cfg, err := png.DecodeConfig(f); err != nil || cfg.Width != 8*48 { t.Fatalf("expected an 8x8 board of 48 pixel squares, got %+v: %v", cfg, err) }
+	_, svg, _ = play(t, "", "render", "--snapshot", filepath.Join(dir, "game.json"))
+	if !strings.Contains(svg, ">A</text>") || !strings.Contains(svg, ">M</text>") { t.Fatalf("expected both pawns in:\n%s", svg) }
+}
+
+func TestRenderFlags(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		code int
+		err  string
+	}{
+		{[]string{"--format", "gif"}, 2, `invalid --format "gif"`},
+		{[]string{"--cell", "500"}, 2, "--cell must be between 8 and 128"},
+		{[]string{"--grid", "0"}, 2, "--grid must be between 2 and 100"},
+		{[]string{"--snapshot", "game.json", "--seed", "1"}, 2, "--seed describes a new board"},
+		{[]string{"extra"}, 2, `unexpected argument "extra"`},
+		{[]string{"--board", "nope.json"}, 1, "nope.json"},
+		{[]string{"--snapshot", "nope.json"}, 1, "nope.json"},
+	} {
+		if code, _, errOut := play(t, "", append([]string{"render"}, c.args...)...); code != c.code || !strings.Contains(errOut, c.err) { t.Errorf("%v: exit %d %q, want %d and %q", c.args, code, errOut, c.code, c.err) }
+	}
+	// a board too large to draw leaves no file behind
+	dir := t.TempDir()
+	huge := filepath.Join(dir, "huge.json")
+	if err := os.WriteFile(huge, []byte(`{"path": [[0,0],[0,1],[3000,3000]]}`), 0o644); err != nil { t.Fatal(err) }
+	if code, _, errOut := play(t, "", "render", "--board", huge, "-o", filepath.Join(dir, "huge.png")); code != 1 || !strings.Contains(errOut, "too large to draw") { t.Errorf("huge board: exit %d %q", code, errOut) }
+	if _, err := os.Stat(filepath.Join(dir, "huge.png")); !os.IsNotExist(err) { t.Errorf("expected no picture, got %v", err) }
+}
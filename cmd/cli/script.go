@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// seat is a player given on the command line, with their team if any.
+type seat struct {
+	name, team string
+}
+
+// parsePlayers reads a comma-separated list of name or name:team.
+func parsePlayers(s string) ([]seat, error) {
+	var seats []seat
+	for _, p := range strings.Split(s, ",") {
+		name, team, _ := strings.Cut(p, ":")
+		name, team = strings.TrimSpace(name), strings.TrimSpace(team)
+		if name == "" { return nil, fmt.Errorf("empty player name in %q", s) }
+		seats = append(seats, seat{name, team})
+	}
+	if len(seats) < 2 { return nil, fmt.Errorf("need at least 2 players, got %d", len(seats)) }
+	return seats, nil
+}
+
+// parseRules reads comma-separated key=value rules named like the server's
+// query parameters. The game checks the values.
+func parseRules(s string) (game.Rules, error) {
+	var r game.Rules
+	if strings.TrimSpace(s) == "" { return r, nil }
+	numbers := map[string]*int{"pawns": &r.Pawns, "moveSeconds": &r.MoveSeconds}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || v == "" { return r, fmt.Errorf("%q is not key=value", kv) }
+		switch k {
+		case "pawns", "moveSeconds":
+			n, err := strconv.Atoi(v)
+			if err != nil { return r, fmt.Errorf("%s %q is not a number", k, v) }
+			*numbers[k] = n
+		case "collision":
+			r.Collision = game.CollisionRule(v)
+		case "teamWin":
+			r.TeamWin = game.TeamWinRule(v)
+		case "undo":
+			r.Undo = game.UndoRule(v)
+		default:
+			return r, fmt.Errorf("unknown rule %q", k)
+		}
+	}
+	return r, nil
+}
+
+// event is one JSON line of an unattended game. Event names it: "start"
+// with the board, rules, players and seed; "move" with the move; "won" with
+// the winner.
+type event struct {
+	Event   string         `json:"event"`
+	Seed    *int64         `json:"seed,omitempty"`
+	Board   *game.BoardDef `json:"board,omitempty"`
+	Rules   *game.Rules    `json:"rules,omitempty"`
+	Players []seatJSON     `json:"players,omitempty"`
+	*game.Move
+	Winner      string `json:"winner,omitempty"`
+	WinningTeam string `json:"winningTeam,omitempty"`
+	Moves       int    `json:"moves,omitempty"`
+}
+
+type seatJSON struct {
+	Name string `json:"name"`
+	Team string `json:"team,omitempty"`
+}
+
+// autoPlay plays every turn of g until someone wins, moving the most
+// advanced pawn when there is a choice, as the server does when a player
+// runs out of time. It writes the moves as text, or as JSON lines when
+// asJSON is set.
+func autoPlay(g *game.Game, out io.Writer, asJSON bool) error {
+	enc := json.NewEncoder(out)
+	snap := g.Snapshot()
+	if asJSON {
+		start := event{Event: "start", Seed: &snap.Dice.Seed, Board: &snap.Board, Rules: &snap.Rules}
+		for _, p := range g.State().Players { start.Players = append(start.Players, seatJSON{p.Name, p.Team}) }
+		if err := enc.Encode(start); err != nil { return err }
+	} else {
+		fmt.Fprintln(out, "Seed:", snap.Dice.Seed)
+		(&terminal{out: out}).printBoard(snap.Board)
+	}
+	for {
+		m, winner, err := g.Roll()
+		if err != nil { return err }
+		if m.Choices != nil {
+			st := g.State()
+			pawns := st.Players[st.TurnIndex].Pawns
+			pick := m.Choices[0]
+			for _, i := range m.Choices {
+				if pawns[i].Square() > pawns[pick].Square() { pick = i }
+			}
+			if m, winner, err = g.MovePawn(pick); err != nil { return err }
+		}
+		if asJSON {
+			err = enc.Encode(event{Event: "move", Move: &m})
+		} else {
+			_, err = fmt.Fprintln(out, describe(m))
+		}
+		if err != nil { return err }
+		if winner == nil { continue }
+		st := g.State()
+		if !asJSON {
+			_, err = fmt.Fprintf(out, "Player %s has won the game\n", *winner)
+			return err
+		}
+		won := event{Event: "won", Winner: *winner, Moves: st.Moves}
+		if st.WinningTeam != nil { won.WinningTeam = *st.WinningTeam }
+		return enc.Encode(won)
+	}
+}
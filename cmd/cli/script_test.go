@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestAutoGolden plays seeded games unattended and compares the output with
+// testdata; go test ./cmd/cli -update rewrites it after a deliberate change.
+func TestAutoGolden(t *testing.T) {
+	for _, c := range []struct {
+		golden string
+		args   []string
+	}{
+		{"classic.txt", []string{"--grid", "8", "--seed", "7", "--players", "Arun,Megha"}},
+		{"default-grid.txt", []string{"--seed", "3", "--players", "Arun, Megha, Zoe"}},
+		{"track.jsonl", []string{"--json", "--board", "../../boards/track.json", "--seed", "3", "--players", "Arun,Megha,Zoe", "--rules", "pawns=2,collision=capture"}},
+		{"teams.jsonl", []string{"--json", "--grid", "6", "--seed", "5", "--players", "Arun:red,Megha:blue,Zoe:red,Ravi:blue", "--rules", "teamWin=all,pawns=2,collision=swap"}},
+	} {
+		t.Run(c.golden, func(t *testing.T) {
+			code, out, errOut := play(t, "", append([]string{"--auto"}, c.args...)...)
+			if code != 0 { t.Fatalf("exit %d: %s", code, errOut) }
+			path := filepath.Join("testdata", c.golden)
+			if *update {
+				if err := os.WriteFile(path, []byte(out), 0o644); err != nil { t.Fatal(err) }
+			}
+			want, err := os.ReadFile(path)
+			if err != nil { t.Fatal(err) }
+			if out != string(want) { t.Fatalf("output differs from %s; run with -update after a deliberate change\ngot:\n%s", path, out) }
+		})
+	}
+}
+
+func TestAutoJSONEvents(t *testing.T) {
+	_, out, _ := play(t, "", "--auto", "--json", "--seed", "11", "--players", "Arun,Megha", "--rules", "pawns=3")
+	sc := bufio.NewScanner(strings.NewReader(out))
+	var events []event
+	for sc.Scan() {
+		var ev event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil { t.Fatalf("line %q: %v", sc.Text(), err) }
+		events = append(events, ev)
+	}
+	first, last := events[0], events[len(events)-1]
+	if first.Event != "start" || *first.Seed != 11 || first.Rules.Pawns != 3 || first.Board.Width != defaultGrid || len(first.Players) != 2 { t.Fatalf("start: %+v", first) }
+	if last.Event != "won" || last.Moves != len(events)-2 || last.Winner == "" { t.Fatalf("end: %+v", last) }
+	for _, ev := range events[1 : len(events)-1] {
+		if ev.Event != "move" || ev.Move == nil || ev.Choices != nil { t.Fatalf("move: %+v", ev) }
+	}
+}
+
+func TestScriptedFlags(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--auto"}, "--auto needs --players"},
+		{[]string{"--json", "--players", "A,B"}, "--json needs --auto"},
+		{[]string{"--auto", "--players", "Arun"}, "need at least 2 players"},
+		{[]string{"--auto", "--players", "Arun,,Megha"}, "empty player name"},
+		{[]string{"--auto", "--players", "A,B", "--rules", "pawns"}, `"pawns" is not key=value`},
+		{[]string{"--auto", "--players", "A,B", "--rules", "pawns=two"}, `pawns "two" is not a number`},
+		{[]string{"--auto", "--players", "A,B", "--rules", "speed=2"}, `unknown rule "speed"`},
+		{[]string{"--auto", "--players", "A,B", "--server", "http://localhost:8080"}, "not with --server"},
+	} {
+		if code, _, errOut := play(t, "", c.args...); code != 2 || !strings.Contains(errOut, c.err) { t.Errorf("%v: exit %d %q, want %q", c.args, code, errOut, c.err) }
+	}
+	// the game checks the values
+	if code, _, errOut := play(t, "", "--auto", "--players", "A,B", "--rules", "pawns=9"); code != 1 || !strings.Contains(errOut, "pawns must be between 1 and 4") { t.Errorf("pawns=9: exit %d %q", code, errOut) }
+	if code, _, errOut := play(t, "", "--auto", "--players", "A:red,B"); code != 1 || !strings.Contains(errOut, "team") { t.Errorf("mixed teams: exit %d %q", code, errOut) }
+}
+
+func TestPipedPlayers(t *testing.T) {
+	// with --players and --grid nothing is asked but the rolls
+	code, out, errOut := play(t, strings.Repeat("\n", 500), "--ui", "plain", "--grid", "5", "--seed", "2", "--players", "Arun,Megha")
+	if code != 0 { t.Fatalf("exit %d: %s", code, errOut) }
+	if strings.Contains(out, "Enter the") || strings.Contains(out, "Enter Player") || !strings.Contains(out, "has won the game") { t.Fatalf("unexpected output:\n%s", out) }
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// Elsewhere the full-screen game is not available and the plain one is
+// played instead.
+
+func isTerminal(fd uintptr) bool { return false }
+
+func makeRaw(fd uintptr) (restore func(), err error) {
+	return nil, errors.New("raw terminal input is not supported on this system")
+}
+
+func termSize(fd uintptr) (cols, rows int) { return 0, 0 }
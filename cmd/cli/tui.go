@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// ANSI escape sequences.
+const (
+	altScreen  = "\x1b[?1049h\x1b[?25l"
+	mainScreen = "\x1b[?25h\x1b[?1049l"
+	home       = "\x1b[H\x1b[2J"
+	reset      = "\x1b[0m"
+	bold       = "\x1b[1m"
+)
+
+// pawnColors tells players apart, in joining order.
+var pawnColors = []string{"\x1b[1;31m", "\x1b[1;34m", "\x1b[1;32m", "\x1b[1;35m", "\x1b[1;33m", "\x1b[1;36m", "\x1b[1;91m", "\x1b[1;94m"}
+
+const (
+	snakeColor  = "\x1b[31m"
+	ladderColor = "\x1b[32m"
+	tileColor   = "\x1b[33m"
+)
+
+// minPaneWidth and maxPaneWidth bound the side pane: players, board
+// legend and the move log.
+const (
+	minPaneWidth = 40
+	maxPaneWidth = 64
+)
+
+// logSize is how many moves the log pane keeps.
+const logSize = 8
+
+// glyphs draw the board, in ASCII or Unicode.
+type glyphs struct {
+	h, v string
+	// joints are the corners and crossings of the grid: top, middle and
+	// bottom rows, each left, inner and right
+	joints        [3][3]string
+	snake, ladder string
+	tile, finish  string
+	dice          []string
+}
+
+var (
+	asciiGlyphs   = glyphs{h: "-", v: "|", joints: [3][3]string{{"+", "+", "+"}, {"+", "+", "+"}, {"+", "+", "+"}}, snake: "S", ladder: "L", tile: "*", finish: "#", dice: []string{"[1]", "[2]", "[3]", "[4]", "[5]", "[6]"}}
+	unicodeGlyphs = glyphs{h: "─", v: "│", joints: [3][3]string{{"┌", "┬", "┐"}, {"├", "┼", "┤"}, {"└", "┴", "┘"}}, snake: "↓", ladder: "↑", tile: "✦", finish: "★", dice: []string{"⚀", "⚁", "⚂", "⚃", "⚄", "⚅"}}
+)
+
+// tui is the full-screen game: it redraws the board and panes after every
+// key. Keys are read from in, which should be a terminal in raw mode.
+type tui struct {
+	in     *bufio.Reader
+	out    io.Writer
+	color  bool
+	glyphs glyphs
+	// size returns the terminal's columns and rows, 0 when unknown.
+	size func() (cols, rows int)
+	// frames is how many faces the dice shows before settling, frameDelay
+	// how long each stays.
+	frames     int
+	frameDelay time.Duration
+
+	log    []string
+	status string
+	dice   string
+	marks  []string // each player's pawn letter
+	// voting is set while waiting for the player asking to take back a move
+	voting bool
+}
+
+// play runs the game until someone wins and a key is pressed, or a player
+// quits.
+func (u *tui) play(g *game.Game) error {
+	fmt.Fprint(u.out, altScreen)
+	defer fmt.Fprint(u.out, mainScreen)
+	u.marks = pawnMarks(g.State().Players)
+	u.status = "Press Enter or Space to roll."
+	for {
+		u.draw(g)
+		key, err := u.in.ReadByte()
+		if err != nil { return err }
+		st := g.State()
+		switch key {
+		case 'q', 'Q', 3, 4: // Ctrl-C, Ctrl-D
+			if st.Winner != nil { return nil }
+			return errQuit
+		}
+		if st.Winner != nil { return nil }
+		if u.voting {
+			u.voting, u.status = false, "Undo cancelled."
+			if i := int(key - '1'); key >= '1' && i < len(st.Players) { u.undo(g, st.Players[i].Name) }
+			continue
+		}
+		switch {
+		case key == '\r' || key == '\n' || key == ' ':
+			if st.Pending != nil {
+				u.status = fmt.Sprintf("%s, pick a pawn: %s.", st.Pending.Player, pawnList(st.Pending.Pawns))
+				break
+			}
+			u.roll(g, func() (game.Move, *string, error) { return g.Roll() })
+		case key >= '1' && key <= '4' && st.Pending != nil:
+			u.roll(g, func() (game.Move, *string, error) { return g.MovePawn(int(key - '1')) })
+		case key == 'u' || key == 'U':
+			if len(st.Players) == 0 { break }
+			// the host is the only one who may ask, unless every player votes
+			if st.Rules.Undo != game.UndoVote {
+				u.undo(g, st.Players[0].Name)
+				break
+			}
+			names := make([]string, len(st.Players))
+			for i, p := range st.Players { names[i] = fmt.Sprintf("%d %s", i+1, p.Name) }
+			u.voting = true
+			u.status = "Who asks to take back the last move? " + strings.Join(names, ", ") + "; any other key cancels."
+		}
+	}
+}
+
+// undo asks to take back the last move on behalf of player.
+func (u *tui) undo(g *game.Game, player string) {
+	res, err := g.Undo(player)
+	switch {
+	case err != nil:
+		u.status = err.Error()
+	case res.Undone != nil:
+		u.addLog("Took back: " + describe(*res.Undone))
+		u.status = "Move taken back."
+	default:
+		u.status = fmt.Sprintf("%s asks to take back the last move: %d of %d votes.", player, len(res.Votes), res.Needed)
+	}
+}
+
+// roll animates the dice, then plays the move.
+func (u *tui) roll(g *game.Game, move func() (game.Move, *string, error)) {
+	for i := 0; i < u.frames; i++ {
+		u.dice = u.glyphs.dice[rand.Intn(6)]
+		u.draw(g)
+		time.Sleep(u.frameDelay)
+	}
+	m, winner, err := move()
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+	u.dice = u.glyphs.dice[m.Roll-1] + " " + strconv.Itoa(m.Roll)
+	if m.Choices != nil {
+		u.status = fmt.Sprintf("%s rolled %d, pick a pawn: %s.", m.Player, m.Roll, pawnList(m.Choices))
+		return
+	}
+	u.addLog(describe(m))
+	u.status = "Press Enter or Space to roll."
+	if winner != nil { u.status = fmt.Sprintf("%s has won the game! Press any key.", *winner) }
+}
+
+func (u *tui) addLog(line string) {
+	u.log = append(u.log, line)
+	if len(u.log) > logSize { u.log = u.log[len(u.log)-logSize:] }
+}
+
+// draw repaints the screen: the board with the side pane to its right, or
+// below it when the terminal is too narrow.
+func (u *tui) draw(g *game.Game) {
+	st, def := g.State(), g.Snapshot().Board
+	board := u.boardLines(st, def)
+	cols, _ := u.size()
+	if cols == 0 { cols = 80 }
+	boardWidth := visibleLen(board[0])
+	side := cols-boardWidth-2 >= minPaneWidth
+	width := min(cols, maxPaneWidth)
+	if side { width = min(cols-boardWidth-2, maxPaneWidth) }
+	pane := u.paneLines(st, def, width)
+	var b strings.Builder
+	b.WriteString(home)
+	if side {
+		for i := 0; i < len(board) || i < len(pane); i++ {
+			line := strings.Repeat(" ", boardWidth)
+			if i < len(board) { line = board[i] }
+			b.WriteString(line)
+			if i < len(pane) { b.WriteString("  " + pane[i]) }
+			b.WriteString("\r\n")
+		}
+	} else {
+		for _, l := range append(board, pane...) { b.WriteString(l + "\r\n") }
+	}
+	fmt.Fprint(u.out, b.String())
+}
+
+// boardLines draws the board, the finishing row on top. Each square shows
+// its number and marker on one line and the pawns on it on the next.
+func (u *tui) boardLines(st game.State, def game.BoardDef) []string {
+	squares := st.Board.Squares
+	cells := boardCells(st.Board)
+	at := map[[2]int]int{} // cell to square number
+	for i, c := range cells { at[c] = i + 1 }
+	marker := map[int]string{squares: u.paint(tileColor, u.glyphs.finish)}
+	for _, s := range def.Snakes { marker[s.From] = u.paint(snakeColor, u.glyphs.snake) }
+	for _, l := range def.Ladders { marker[l.From] = u.paint(ladderColor, u.glyphs.ladder) }
+	for _, t := range def.Tiles { marker[t.Square] = u.paint(tileColor, u.glyphs.tile) }
+	pawns := map[int][]string{}
+	for i, p := range st.Players {
+		for _, sq := range pawnSquares(p) {
+			if sq > 0 { pawns[sq] = append(pawns[sq], u.paint(pawnColors[i%len(pawnColors)], u.marks[i])) }
+		}
+	}
+
+	width := max(len(strconv.Itoa(squares))+1, 4)
+	border := func(j [3]string) string {
+		cells := make([]string, st.Board.Width)
+		for i := range cells { cells[i] = strings.Repeat(u.glyphs.h, width) }
+		return j[0] + strings.Join(cells, j[1]) + j[2]
+	}
+	lines := []string{border(u.glyphs.joints[0])}
+	for row := st.Board.Height - 1; row >= 0; row-- {
+		top, bottom := u.glyphs.v, u.glyphs.v
+		for col := 0; col < st.Board.Width; col++ {
+			sq, ok := at[[2]int{row, col}]
+			if !ok {
+				top += strings.Repeat(" ", width) + u.glyphs.v
+				bottom += strings.Repeat(" ", width) + u.glyphs.v
+				continue
+			}
+			m := marker[sq]
+			if m == "" { m = " " }
+			top += fmt.Sprintf("%*d", width-1, sq) + m + u.glyphs.v
+			here := pawns[sq]
+			if len(here) > width { here = append(here[:width-2], "+"+strconv.Itoa(len(here)-width+2)) }
+			cell := strings.Join(here, "")
+			bottom += cell + strings.Repeat(" ", width-visibleLen(cell)) + u.glyphs.v
+		}
+		lines = append(lines, top, bottom, border(u.glyphs.joints[1]))
+	}
+	lines[len(lines)-1] = border(u.glyphs.joints[2])
+	return lines
+}
+
+// paneLines draws the side pane, width characters wide.
+func (u *tui) paneLines(st game.State, board game.BoardDef, width int) []string {
+	lines := []string{u.paint(bold, "Snake & Ladder") + fmt.Sprintf(" · %d squares", st.Board.Squares), ""}
+	dice := u.dice
+	if dice == "" { dice = "-" }
+	lines = append(lines, "Dice: "+dice, "")
+	for i, p := range st.Players {
+		where := "start"
+		if sq := p.Position.Square(); sq > 0 { where = strconv.Itoa(sq) }
+		if len(p.Pawns) > 0 {
+			var sqs []string
+			for _, sq := range pawnSquares(p) { sqs = append(sqs, strconv.Itoa(sq)) }
+			where = strings.Join(sqs, " ")
+		}
+		turn := "  "
+		if st.Winner == nil && i == st.TurnIndex { turn = "> " }
+		lines = append(lines, turn+u.paint(pawnColors[i%len(pawnColors)], u.marks[i])+" "+fmt.Sprintf("%-16s", truncate(p.Name, 16))+" "+where)
+	}
+	lines = append(lines, "",
+		truncate(u.paint(snakeColor, u.glyphs.snake)+" snakes  "+jumps(board.Snakes), width),
+		truncate(u.paint(ladderColor, u.glyphs.ladder)+" ladders "+jumps(board.Ladders), width))
+	if len(board.Tiles) > 0 {
+		var tiles []string
+		for _, t := range board.Tiles { tiles = append(tiles, strconv.Itoa(t.Square)+" "+string(t.Effect)) }
+		lines = append(lines, truncate(u.paint(tileColor, u.glyphs.tile)+" "+strings.Join(tiles, ", "), width))
+	}
+	lines = append(lines, "", u.paint(bold, "Moves"))
+	for _, l := range u.log { lines = append(lines, truncate(l, width)) }
+	lines = append(lines, "", truncate(u.status, width), "Enter roll · 1-4 pawn · u undo · q quit")
+	return lines
+}
+
+// paint colors s when colors are on.
+func (u *tui) paint(color, s string) string {
+	if !u.color { return s }
+	return color + s + reset
+}
+
+// pawnMarks gives every player a letter: their initial, or their number when
+// another player already has it.
+func pawnMarks(players []game.Player) []string {
+	marks := make([]string, len(players))
+	taken := map[string]bool{}
+	for i, p := range players {
+		r, _ := utf8.DecodeRuneInString(p.Name)
+		m := strings.ToUpper(string(r))
+		if taken[m] || r == utf8.RuneError { m = strconv.Itoa(i + 1) }
+		taken[m] = true
+		marks[i] = m
+	}
+	return marks
+}
+
+// pawnSquares lists the squares of p's pawns, 0 for the start.
+func pawnSquares(p game.Player) []int {
+	if len(p.Pawns) == 0 { return []int{p.Position.Square()} }
+	sqs := make([]int, len(p.Pawns))
+	for i, pt := range p.Pawns { sqs[i] = pt.Square() }
+	return sqs
+}
+
+func pawnList(pawns []int) string {
+	parts := make([]string, len(pawns))
+	for i, p := range pawns { parts[i] = strconv.Itoa(p + 1) }
+	return strings.Join(parts, ", ")
+}
+
+// boardCells lists the cells of the path of play, square 1 first.
+func boardCells(s game.Shape) [][2]int {
+	if s.Cells != nil { return s.Cells }
+	b, err := game.NewGridBoard(s.Width, s.Height, s.Layout)
+	if err != nil { return nil }
+	var cells [][2]int
+	for _, c := range b.Cells() { cells = append(cells, [2]int{c.X, c.Y}) }
+	return cells
+}
+
+// visibleLen counts the characters of s a terminal shows, leaving out escape
+// sequences.
+func visibleLen(s string) int {
+	n, esc := 0, false
+	for _, r := range s {
+		switch {
+		case esc:
+			if r >= '@' && r <= '~' && r != '[' { esc = false }
+		case r == '\x1b':
+			esc = true
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// truncate shortens s to at most n visible characters.
+func truncate(s string, n int) string {
+	if visibleLen(s) <= n { return s }
+	var b strings.Builder
+	count, esc := 0, false
+	for _, r := range s {
+		switch {
+		case esc:
+			if r >= '@' && r <= '~' && r != '[' { esc = false }
+		case r == '\x1b':
+			esc = true
+		default:
+			if count == n-1 {
+				b.WriteString("…")
+				if strings.Contains(s, "\x1b") { b.WriteString(reset) }
+				return b.String()
+			}
+			count++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
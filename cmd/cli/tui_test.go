@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+func newTestTUI(keys string) (*tui, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &tui{in: bufio.NewReader(strings.NewReader(keys)), out: &out, glyphs: asciiGlyphs, size: func() (int, int) { return 120, 40 }}, &out
+}
+
+// lastScreen returns what the final redraw showed.
+func lastScreen(out string) string {
+	out = strings.TrimSuffix(out, mainScreen)
+	return out[strings.LastIndex(out, home)+len(home):]
+}
+
+func seededGame(t *testing.T, rules game.Rules, names ...string) *game.Game {
+	t.Helper()
+	g, err := game.NewSeeded(&game.BoardDef{Width: 8, Height: 8}, 7)
+	if err != nil { t.Fatal(err) }
+	if err := g.SetRules(rules); err != nil { t.Fatal(err) }
+	for _, n := range names {
+		if err := g.AddPlayer(n); err != nil { t.Fatal(err) }
+	}
+	return g
+}
+
+func TestFullScreenBoard(t *testing.T) {
+	g := seededGame(t, game.Rules{}, "Arun", "Megha")
+	u, out := newTestTUI("\r \rq")
+	if err := u.play(g); !errors.Is(err, errQuit) { t.Fatalf("quit: %v", err) }
+	screen := lastScreen(out.String())
+	lines := strings.Split(screen, "\r\n")
+	// 8 rows of two lines and their borders, the finishing row on top
+	if !strings.HasPrefix(lines[0], "+----+") || !strings.HasPrefix(lines[1], "| 64#| 63 |") || !strings.HasPrefix(lines[22], "|  1 |  2 |") {
+		t.Fatalf("unexpected board:\n%s", screen)
+	}
+	// the pane sits next to the board
+	if !strings.Contains(lines[0], "Snake & Ladder · 64 squares") { t.Fatalf("pane not beside the board:\n%s", screen) }
+	for _, want := range []string{"|  7L|", "| 48S|", "Dice: [2] 2", "  A Arun             24", "> M Megha            6", "S snakes  42→35, 48→27, 38→5", "Arun rolled 2: 5 → 7, up the ladder on 7 to 24"} {
+		if !strings.Contains(screen, want) { t.Errorf("missing %q in:\n%s", want, screen) }
+	}
+	if st := g.State(); st.Players[0].Position.Square() != 24 { t.Fatalf("Arun on %d", st.Players[0].Position.Square()) }
+	if !strings.HasPrefix(out.String(), altScreen) || !strings.HasSuffix(out.String(), mainScreen) { t.Fatal("alternate screen not entered and left") }
+}
+
+func TestFullScreenUndoAndColors(t *testing.T) {
+	g := seededGame(t, game.Rules{}, "Arun", "Megha")
+	u, out := newTestTUI(" uuq")
+	u.color, u.glyphs = true, unicodeGlyphs
+	_ = u.play(g)
+	screen := lastScreen(out.String())
+	if len(g.History()) != 0 { t.Fatalf("move not taken back: %v", g.History()) }
+	if !strings.Contains(screen, "nothing to undo") || !strings.Contains(screen, "Took back: Arun rolled 5") { t.Fatalf("undo not reported:\n%s", screen) }
+	if !strings.Contains(screen, pawnColors[0]+"A"+reset) || !strings.Contains(screen, "┌────┬") { t.Fatalf("no colors or box drawing:\n%s", screen) }
+}
+
+func TestFullScreenUndoVote(t *testing.T) {
+	g := seededGame(t, game.Rules{Undo: game.UndoVote}, "Arun", "Megha")
+	u, out := newTestTUI(" u2uxu1q")
+	_ = u.play(g)
+	if !strings.Contains(out.String(), "Who asks to take back the last move? 1 Arun, 2 Megha") { t.Fatal("voter never asked for") }
+	if !strings.Contains(out.String(), "Megha asks to take back the last move: 1 of 2 votes.") || !strings.Contains(out.String(), "Undo cancelled.") { t.Fatalf("vote not reported:\n%s", lastScreen(out.String())) }
+	if len(g.History()) != 0 || !strings.Contains(lastScreen(out.String()), "Took back: Arun rolled 5") { t.Fatalf("move not taken back once both voted: %v", g.History()) }
+}
+
+func TestFullScreenPawnChoice(t *testing.T) {
+	g := seededGame(t, game.Rules{Pawns: 2}, "Arun", "Megha")
+	u, out := newTestTUI(strings.Repeat(" 1", 400) + "x")
+	if err := u.play(g); err != nil { t.Fatalf("play: %v", err) }
+	st := g.State()
+	if st.Winner == nil { t.Fatal("no winner") }
+	if screen := lastScreen(out.String()); !strings.Contains(screen, *st.Winner+" has won the game!") { t.Fatalf("no winner shown:\n%s", screen) }
+	if !strings.Contains(out.String(), "pick a pawn: 1, 2.") { t.Fatal("pawn choice never offered") }
+}
+
+func TestNarrowTerminal(t *testing.T) {
+	g := seededGame(t, game.Rules{}, "Arun", "Megha")
+	u, out := newTestTUI("q")
+	u.size = func() (int, int) { return 60, 40 }
+	_ = u.play(g)
+	lines := strings.Split(lastScreen(out.String()), "\r\n")
+	if len(lines[0]) != len("+----+")*8-7 || !strings.HasPrefix(lines[25], "Snake & Ladder") { t.Fatalf("pane not below the board:\n%s", strings.Join(lines, "\n")) }
+}
+
+func TestChoosingTheUI(t *testing.T) {
+	env := func(vars ...string) func(string) string {
+		return func(k string) string {
+			for i := 0; i+1 < len(vars); i += 2 {
+				if vars[i] == k { return vars[i+1] }
+			}
+			return ""
+		}
+	}
+	var out bytes.Buffer
+	if u := fullScreen(uiAuto, strings.NewReader(""), &out, env("TERM", "xterm")); u != nil { t.Fatal("full screen without a terminal") }
+	if u := fullScreen(uiPlain, strings.NewReader(""), &out, env("TERM", "xterm")); u != nil { t.Fatal("full screen with --ui plain") }
+	u := fullScreen(uiFull, strings.NewReader(""), &out, env("TERM", "xterm", "LANG", "en_GB.UTF-8"))
+	if u == nil || !u.color || u.glyphs.snake != unicodeGlyphs.snake { t.Fatalf("--ui full on a UTF-8 terminal: %+v", u) }
+	u = fullScreen(uiFull, strings.NewReader(""), &out, env("TERM", "xterm", "LC_ALL", "C", "LANG", "en_GB.UTF-8", "NO_COLOR", "1"))
+	if u.color || u.glyphs.snake != asciiGlyphs.snake { t.Fatalf("NO_COLOR and the C locale: %+v", u) }
+
+	code, screen, _ := play(t, "2\nArun\nMegha\nq", "--ui", "full", "--grid", "8")
+	if code != 1 || !strings.Contains(screen, altScreen) { t.Fatalf("--ui full: exit %d\n%s", code, screen) }
+	if code, _, errOut := play(t, "", "--ui", "fancy"); code != 2 || !strings.Contains(errOut, "invalid --ui") { t.Fatalf("--ui fancy: %d %s", code, errOut) }
+}
+
+func TestTruncate(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"Arun rolled 5", 8, "Arun ro…"},
+		{"\x1b[31mSnakes\x1b[0m here", 4, "\x1b[31mSna…\x1b[0m"},
+	} {
+		if got := truncate(c.in, c.n); got != c.want { t.Errorf("truncate(%q, %d) = %q, want %q", c.in, c.n, got, c.want) }
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arsulegai/snakeandladder/internal/config"
+	"github.com/arsulegai/snakeandladder/internal/game"
+	"github.com/arsulegai/snakeandladder/internal/render"
+)
+
+// apiV1 is the prefix of the current API. The same routes are served under
+// apiLegacy for clients written before versioning; a v2 gets its own route
+// table mounted next to them.
+const (
+	apiV1     = "/api/v1"
+	apiLegacy = "/api"
+)
+
+// api serves the REST API over a registry of games.
+type api struct {
+	reg *game.Registry
+	cfg config.Config
+	// rate limits per client address and per game; nil when off
+	perIP, perGame *limiter
+	metrics        *serverMetrics
+	pictures       *pictureCache
+	// set once the server stops taking new games
+	draining atomic.Bool
+}
+
+func newAPI(reg *game.Registry, cfg config.Config, m *serverMetrics) *api {
+	return &api{
+		reg:     reg,
+		cfg:     cfg,
+		metrics: m,
+		pictures: newPictureCache(),
+		perIP:   newLimiter(cfg.IPRate, cfg.IPBurst),
+		perGame: newLimiter(cfg.GameRate, cfg.GameBurst),
+	}
+}
+
+// route is one path of the API and its handler for each allowed method.
+// Paths use ServeMux patterns and are relative to the version prefix.
+type route struct {
+	path    string
+	methods map[string]http.HandlerFunc
+}
+
+// gameHandler handles a request for the game named by the {id} wildcard.
+type gameHandler func(w http.ResponseWriter, r *http.Request, id string, g *game.Game)
+
+func (a *api) routes() []route {
+	return []route{
+		{"/openapi.json", map[string]http.HandlerFunc{http.MethodGet: serveOpenAPI}},
+		{"/games", map[string]http.HandlerFunc{http.MethodPost: a.limitIP(a.createGame)}},
+		{"/games/import", map[string]http.HandlerFunc{http.MethodPost: a.limitIP(a.importGame)}},
+		{"/games/{id}", map[string]http.HandlerFunc{http.MethodGet: a.game(a.state)}},
+		{"/games/{id}/state", map[string]http.HandlerFunc{http.MethodGet: a.game(a.state)}},
+		{"/games/{id}/players", map[string]http.HandlerFunc{http.MethodPost: a.limitIP(a.game(a.limitGame(a.locked(a.addPlayer))))}},
+		{"/games/{id}/roll", map[string]http.HandlerFunc{http.MethodPost: a.limitIP(a.game(a.limitGame(a.locked(a.roll))))}},
+		{"/games/{id}/move", map[string]http.HandlerFunc{http.MethodPost: a.limitIP(a.game(a.limitGame(a.locked(a.movePawn))))}},
+		{"/games/{id}/undo", map[string]http.HandlerFunc{http.MethodPost: a.limitIP(a.game(a.limitGame(a.locked(a.undo))))}},
+		{"/games/{id}/events", map[string]http.HandlerFunc{http.MethodGet: a.game(a.events)}},
+		{"/games/{id}/snapshot", map[string]http.HandlerFunc{http.MethodGet: a.game(a.snapshot)}},
+		{"/games/{id}/board.svg", map[string]http.HandlerFunc{http.MethodGet: a.limitIP(a.game(a.drawBoard("image/svg+xml", render.SVG)))}},
+		{"/games/{id}/board.png", map[string]http.HandlerFunc{http.MethodGet: a.limitIP(a.game(a.drawBoard("image/png", render.PNG)))}},
+		{"/games/{id}/fork", map[string]http.HandlerFunc{http.MethodPost: a.limitIP(a.game(a.fork))}},
+		{"/games/{id}/stream", map[string]http.HandlerFunc{http.MethodGet: a.game(a.stream)}},
+	}
+}
+
+// mount registers routes under prefix. Other methods on a known path get a
+// 405 listing the allowed ones in Allow; unknown paths get a 404. Request
+// bodies are cut off after cfg.MaxBody bytes.
+func (a *api) mount(mux *http.ServeMux, prefix string, routes []route) {
+	for _, rt := range routes {
+		methods := rt.methods
+		allow := make([]string, 0, len(methods))
+		for m := range methods { allow = append(allow, m) }
+		sort.Strings(allow)
+		mux.Handle(prefix+rt.path, a.metrics.instrument(prefix+rt.path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h, ok := methods[r.Method]
+			if !ok {
+				w.Header().Set("Allow", strings.Join(allow, ", "))
+				methodNotAllowed(w)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, int64(a.cfg.MaxBody))
+			h(w, r)
+		})))
+	}
+	mux.Handle(prefix+"/", a.metrics.instrument(prefix+"/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notFound(w)
+	})))
+}
+
+// limitIP applies the per-address rate limit to h.
+func (a *api) limitIP(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, retry := a.perIP.allow(clientIP(r)); !ok {
+			logFrom(r.Context()).Warn("rate limited", "limit", "ip", "retry_after", retry)
+			tooManyRequests(w, retry)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// limitGame applies the per-game rate limit to h.
+func (a *api) limitGame(h gameHandler) gameHandler {
+	return func(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+		if ok, retry := a.perGame.allow(id); !ok {
+			logFrom(r.Context()).Warn("rate limited", "limit", "game", "retry_after", retry)
+			tooManyRequests(w, retry)
+			return
+		}
+		h(w, r, id, g)
+	}
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil { return r.RemoteAddr }
+	return host
+}
+
+// game looks up the game for h, answering 404 when there is none. The
+// request's logger carries the game ID from here on.
+func (a *api) game(h gameHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		r = withLogger(r, logFrom(r.Context()).With("game", id))
+		g, ok, err := a.reg.Load(id)
+		if err != nil {
+			fail(w, r, "game not loaded", err)
+			return
+		}
+		if !ok {
+			gameNotFound(w, id)
+			return
+		}
+		h(w, r, id, g)
+	}
+}
+
+// locked runs h, which changes the game, holding the game's lock, so that
+// servers sharing games change each one in turn.
+func (a *api) locked(h gameHandler) gameHandler {
+	return func(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+		unlock, err := a.reg.Lock(id)
+		if err != nil {
+			fail(w, r, "game not locked", err)
+			return
+		}
+		defer func() {
+			if err := unlock(); err != nil { logFrom(r.Context()).Error("game not shared", "err", err) }
+		}()
+		h(w, r, id, g)
+	}
+}
+
+func (a *api) createGame(w http.ResponseWriter, r *http.Request) {
+	def, err := boardDefFromRequest(r, a.cfg)
+	if err != nil {
+		fail(w, r, "create game refused", err)
+		return
+	}
+	rules, err := rulesFromRequest(r)
+	if err != nil {
+		fail(w, r, "create game refused", err)
+		return
+	}
+	seed, err := seedFromRequest(r)
+	if err != nil {
+		fail(w, r, "create game refused", err)
+		return
+	}
+	var g *game.Game
+	if seed != nil {
+		g, err = game.NewSeeded(def, *seed)
+	} else {
+		g, err = game.NewFromDef(def)
+	}
+	if err == nil {
+		err = g.SetRules(rules)
+	}
+	if err != nil {
+		fail(w, r, "create game refused", err)
+		return
+	}
+	id, err := a.add(g)
+	if err != nil {
+		fail(w, r, "create game refused", err)
+		return
+	}
+	b := g.Board()
+	logFrom(r.Context()).Info("game created", "game", id, "width", b.Width(), "height", b.Height(), "layout", b.Layout(), "squares", b.Squares())
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (a *api) importGame(w http.ResponseWriter, r *http.Request) {
+	var snap game.Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snap); err != nil {
+		badBody(w, r, "import refused", err)
+		return
+	}
+	if err := checkSnapshot(snap, a.cfg); err != nil {
+		fail(w, r, "import refused", err)
+		return
+	}
+	g, err := game.FromSnapshot(snap)
+	if err != nil {
+		fail(w, r, "import refused", err)
+		return
+	}
+	id, err := a.add(g)
+	if err != nil {
+		fail(w, r, "import refused", err)
+		return
+	}
+	logFrom(r.Context()).Info("game imported", "game", id, "moves", snap.Moves)
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+// add registers a new game, applying the player limit. It fails with
+// errShuttingDown once the server is draining.
+func (a *api) add(g *game.Game) (string, error) {
+	if a.draining.Load() { return "", errShuttingDown }
+	g.SetMaxPlayers(a.cfg.MaxPlayers)
+	return a.reg.Register(g)
+}
+
+func (a *api) state(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	writeJSON(w, http.StatusOK, g.State())
+}
+
+func (a *api) addPlayer(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	var body struct {
+		Name string `json:"name"`
+		Team string `json:"team"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badBody(w, r, "add player refused", err)
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		missingField(w, "name")
+		return
+	}
+	for _, f := range []struct{ field, value string }{{"name", body.Name}, {"team", body.Team}} {
+		if utf8.RuneCountInString(f.value) > a.cfg.MaxName {
+			fail(w, r, "add player refused", &game.Error{
+				Kind:    game.KindInvalid,
+				Code:    game.ErrInvalidName.Code,
+				Message: fmt.Sprintf("%s is longer than %d characters", f.field, a.cfg.MaxName),
+				Details: map[string]interface{}{"field": f.field, "max": a.cfg.MaxName},
+			})
+			return
+		}
+	}
+	add := g.AddPlayer
+	if strings.TrimSpace(body.Team) != "" {
+		add = func(name string) error { return g.AddPlayerToTeam(name, body.Team) }
+	}
+	if err := add(body.Name); err != nil {
+		fail(w, r, "add player refused", err, "player", body.Name)
+		return
+	}
+	logFrom(r.Context()).Info("player added", "player", body.Name, "team", body.Team)
+	writeJSON(w, http.StatusCreated, g.State())
+}
+
+func (a *api) roll(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	start := time.Now()
+	move, winner, err := g.Roll()
+	a.metrics.rolls.Observe(time.Since(start).Seconds())
+	if err != nil {
+		fail(w, r, "roll refused", err)
+		return
+	}
+	logMove(r, move, winner)
+	writeMove(w, move, winner)
+}
+
+func (a *api) movePawn(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	var body struct{ Pawn *int `json:"pawn"` }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badBody(w, r, "move refused", err)
+		return
+	}
+	if body.Pawn == nil {
+		missingField(w, "pawn")
+		return
+	}
+	move, winner, err := g.MovePawn(*body.Pawn)
+	if err != nil {
+		fail(w, r, "move refused", err, "pawn", *body.Pawn)
+		return
+	}
+	logMove(r, move, winner)
+	writeMove(w, move, winner)
+}
+
+// fail logs why a request was refused, with any extra attributes, and
+// answers with err.
+func fail(w http.ResponseWriter, r *http.Request, msg string, err error, attrs ...any) {
+	logFrom(r.Context()).Info(msg, append(attrs, "err", err)...)
+	writeErr(w, err)
+}
+
+// badBody is fail for a body that could not be decoded.
+func badBody(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	logFrom(r.Context()).Info(msg, "err", err)
+	invalidBody(w, err)
+}
+
+// logMove logs a roll or a pawn choice.
+func logMove(r *http.Request, move game.Move, winner *string) {
+	l := logFrom(r.Context())
+	if winner != nil { l = l.With("winner", *winner) }
+	l.Info("move", "player", move.Player, "pawn", move.Pawn, "roll", move.Roll, "from", move.From, "to", move.To, "moved", move.Moved)
+}
+
+// writeMove answers a roll or a pawn choice.
+func writeMove(w http.ResponseWriter, move game.Move, winner *string) {
+	resp := map[string]interface{}{"roll": move.Roll, "move": move}
+	if winner != nil { resp["winner"] = *winner }
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *api) undo(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	var body struct{ Player string `json:"player"` }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badBody(w, r, "undo refused", err)
+		return
+	}
+	if strings.TrimSpace(body.Player) == "" {
+		missingField(w, "player")
+		return
+	}
+	res, err := g.Undo(body.Player)
+	if err != nil {
+		fail(w, r, "undo refused", err, "player", body.Player)
+		return
+	}
+	if res.Undone == nil {
+		// vote recorded, waiting for the other players
+		logFrom(r.Context()).Info("undo vote", "player", body.Player)
+		writeJSON(w, http.StatusAccepted, res)
+		return
+	}
+	logFrom(r.Context()).Info("move undone", "player", body.Player, "undone_player", res.Undone.Player)
+	writeJSON(w, http.StatusOK, res)
+}
+
+// events returns the move log, optionally only the moves after the first
+// ?since=.
+func (a *api) events(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	moves := g.History()
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeErr(w, &paramError{"since", v})
+			return
+		}
+		if n > len(moves) { n = len(moves) }
+		moves = moves[n:]
+	}
+	writeJSON(w, http.StatusOK, moves)
+}
+
+func (a *api) snapshot(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	writeJSON(w, http.StatusOK, g.Snapshot())
+}
+
+// drawBoard answers with a picture of the game's board drawn by draw:
+// ?pawns=false leaves the pawns out and ?cell= sets the side of a square in
+// pixels. Boards too large to draw get a 422. Pictures are kept until the
+// game changes.
+func (a *api) drawBoard(ctype string, draw func(io.Writer, *render.Board, render.Options) error) gameHandler {
+	return func(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+		q := r.URL.Query()
+		pawns, opts := true, render.Options{}
+		if v := q.Get("pawns"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				writeErr(w, &paramError{"pawns", v})
+				return
+			}
+			pawns = b
+		}
+		if v := q.Get("cell"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < render.MinCell || n > render.MaxCell {
+				writeErr(w, &paramError{"cell", v})
+				return
+			}
+			opts.Cell = n
+		}
+		key := pictureKey{id: id, rev: g.Revision(), ctype: ctype, pawns: pawns, cell: opts.Cell}
+		body, ok := a.pictures.get(key)
+		if !ok {
+			var buf bytes.Buffer
+			err := draw(&buf, render.FromGame(g, pawns), opts)
+			if errors.Is(err, render.ErrTooLarge) {
+				err = &game.Error{Kind: game.KindInvalid, Code: game.ErrInvalidBoard.Code, Message: err.Error(), Details: map[string]interface{}{"max": render.MaxBoardSide}}
+			}
+			if err != nil {
+				fail(w, r, "board not drawn", err)
+				return
+			}
+			body = buf.Bytes()
+			a.pictures.put(key, body)
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(body)
+	}
+}
+
+func (a *api) fork(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	snap := g.Snapshot()
+	if err := checkSnapshot(snap, a.cfg); err != nil {
+		fail(w, r, "fork refused", err)
+		return
+	}
+	fork, err := game.FromSnapshot(snap)
+	if err != nil {
+		fail(w, r, "fork refused", err)
+		return
+	}
+	forkID, err := a.add(fork)
+	if err != nil {
+		fail(w, r, "fork refused", err)
+		return
+	}
+	logFrom(r.Context()).Info("game forked", "fork", forkID)
+	writeJSON(w, http.StatusCreated, map[string]string{"id": forkID})
+}
+
+func (a *api) stream(w http.ResponseWriter, r *http.Request, id string, g *game.Game) {
+	logFrom(r.Context()).Info("stream opened")
+	// streams outlive the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	g.Subscribe(w, r)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// apiError is the body of every error response. Code is stable, so clients
+// can react to it and localize Message:
+//
+//	{"error": {"code": "not_host", "message": "only the host (Arun) can undo", "details": {"host": "Arun"}}}
+type apiError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	writeJSON(w, status, map[string]apiError{"error": {Code: code, Message: message, Details: details}})
+}
+
+// paramError reports a query parameter that could not be parsed.
+type paramError struct {
+	name, value string
+}
+
+func (e *paramError) Error() string { return "invalid " + e.name + " " + strconv.Quote(e.value) }
+
+// errShuttingDown refuses new games while the server stops.
+var errShuttingDown = errors.New("server is shutting down")
+
+// writeErr reports err with the status its kind calls for: 422 for requests
+// breaking the rules, 409 when the game is in the wrong state, 403 when the
+// player may not do it.
+func writeErr(w http.ResponseWriter, err error) {
+	var ge *game.Error
+	var pe *paramError
+	var me *http.MaxBytesError
+	switch {
+	case errors.Is(err, errShuttingDown):
+		writeError(w, http.StatusServiceUnavailable, "shutting_down", err.Error(), nil)
+	case errors.As(err, &me):
+		writeError(w, http.StatusRequestEntityTooLarge, "body_too_large", "request body is larger than "+strconv.FormatInt(me.Limit, 10)+" bytes", map[string]interface{}{"max": me.Limit})
+	case errors.As(err, &ge):
+		status := http.StatusUnprocessableEntity
+		switch ge.Kind {
+		case game.KindConflict:
+			status = http.StatusConflict
+		case game.KindForbidden:
+			status = http.StatusForbidden
+		}
+		writeError(w, status, ge.Code, ge.Message, ge.Details)
+	case errors.As(err, &pe):
+		writeError(w, http.StatusBadRequest, "invalid_parameter", pe.Error(), map[string]interface{}{"parameter": pe.name})
+	default:
+		writeError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed", nil)
+}
+
+func notFound(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, "not_found", "not found", nil)
+}
+
+func gameNotFound(w http.ResponseWriter, id string) {
+	writeError(w, http.StatusNotFound, "game_not_found", "game not found", map[string]interface{}{"id": id})
+}
+
+// tooManyRequests reports a request over a rate limit, saying when to retry.
+func tooManyRequests(w http.ResponseWriter, retry time.Duration) {
+	secs := int(math.Ceil(retry.Seconds()))
+	if secs < 1 { secs = 1 }
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	writeError(w, http.StatusTooManyRequests, "rate_limited", "too many requests, retry in "+strconv.Itoa(secs)+"s", map[string]interface{}{"retryAfter": secs})
+}
+
+// invalidBody reports a request body that is not the expected JSON, or one
+// cut off for being too large.
+func invalidBody(w http.ResponseWriter, err error) {
+	var me *http.MaxBytesError
+	if errors.As(err, &me) {
+		writeErr(w, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, "invalid_body", "invalid body: "+err.Error(), nil)
+}
+
+// missingField reports a required body field left out or empty.
+func missingField(w http.ResponseWriter, field string) {
+	writeError(w, http.StatusUnprocessableEntity, "missing_field", field+" is required", map[string]interface{}{"field": field})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arsulegai/snakeandladder/internal/config"
+)
+
+// maxLogValue is the longest string value written to the log; longer ones,
+// such as odd request paths, are cut.
+const maxLogValue = 256
+
+// newLogger returns a logger writing cfg.LogFormat to w at cfg.LogLevel.
+// Values are always written as attributes, never spliced into messages, so
+// the handler quotes or escapes whatever users send.
+func newLogger(w io.Writer, cfg config.Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.LogLevel, ReplaceAttr: truncateAttr}
+	if cfg.LogFormat == config.LogJSON { return slog.New(slog.NewJSONHandler(w, opts)) }
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func truncateAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindString { return a }
+	s := a.Value.String()
+	if len(s) <= maxLogValue { return a }
+	cut := maxLogValue
+	for cut > 0 && !utf8.RuneStart(s[cut]) { cut-- }
+	return slog.String(a.Key, s[:cut]+"…")
+}
+
+type loggerKey struct{}
+
+// logFrom returns the request's logger, carrying its request ID and, once
+// known, its game.
+func logFrom(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok { return l }
+	return slog.Default()
+}
+
+func withLogger(r *http.Request, l *slog.Logger) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), loggerKey{}, l))
+}
+
+// withRequestID gives every request an ID, taken from a well-formed
+// X-Request-ID header or made up, echoes it in the response and logs the
+// request once it is answered.
+func withRequestID(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) { id = newRequestID() }
+		w.Header().Set("X-Request-ID", id)
+		l := logger.With("request_id", id)
+		start, path := time.Now(), r.URL.Path
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, withLogger(r, l))
+		level := slog.LevelInfo
+		if rec.status() >= 500 { level = slog.LevelError }
+		l.Log(r.Context(), level, "request",
+			"method", r.Method,
+			"path", path,
+			"status", rec.status(),
+			"duration", time.Since(start),
+			"remote", clientIP(r),
+		)
+	})
+}
+
+// validRequestID accepts IDs of up to 64 letters, digits, dots, dashes and
+// underscores, so a client cannot put anything else in the log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 { return false }
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') { return false }
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
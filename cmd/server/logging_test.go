@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/arsulegai/snakeandladder/internal/config"
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// syncBuffer is a bytes.Buffer safe for the server's goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines decodes every log line, failing on any that is not JSON.
+func (b *syncBuffer) lines(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]interface{}
+	for _, l := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &m); err != nil { t.Fatalf("log line is not JSON: %q", l) }
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestStructuredLogs(t *testing.T) {
+	cfg := config.Default()
+	cfg.LogFormat = config.LogJSON
+	var logs syncBuffer
+	reg := game.NewRegistry()
+	a := newApp(reg, cfg, newLogger(&logs, cfg))
+	a.health.ready.Store(true)
+	ts := httptest.NewServer(a.handler)
+	defer ts.Close()
+	id := reg.Add(game.New(10))
+
+	do := func(method, path, body, requestID string) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if requestID != "" { req.Header.Set("X-Request-ID", requestID) }
+		r, err := http.DefaultClient.Do(req)
+		if err != nil { t.Fatal(err) }
+		r.Body.Close()
+		return r
+	}
+	if r := do("POST", "/api/v1/games/"+id+"/players", `{"name":"Arun \"the\" = host"}`, "req-1"); r.Header.Get("X-Request-ID") != "req-1" {
+		t.Fatalf("request ID not echoed: %q", r.Header.Get("X-Request-ID"))
+	}
+	do("POST", "/api/v1/games/"+id+"/players", `{"name":"Megha"}`, "bad id; level=ERROR")
+	do("POST", "/api/v1/games/"+id+"/roll", "", "")
+	do("GET", "/api/v1/games/%0Afake%20level=ERROR/state", "", "")
+	do("GET", "/"+strings.Repeat("x", 1000), "", "")
+
+	var added, rolled, request, missing, long bool
+	ids := map[string]bool{}
+	for _, l := range logs.lines(t) {
+		rid, _ := l["request_id"].(string)
+		if !validRequestID(rid) { t.Fatalf("line without a valid request ID: %v", l) }
+		ids[rid] = true
+		switch {
+		case l["msg"] == "player added" && l["player"] == `Arun "the" = host`:
+			added = l["game"] == id && rid == "req-1"
+		case l["msg"] == "move":
+			rolled = l["game"] == id && l["player"] == `Arun "the" = host` && l["roll"] != nil
+		case l["msg"] == "request" && l["path"] == "/api/v1/games/"+id+"/roll":
+			request = l["status"] == float64(200) && l["method"] == "POST"
+		case l["msg"] == "request" && l["path"] == "/api/v1/games/\nfake level=ERROR/state":
+			missing = l["level"] == "INFO" && l["status"] == float64(404)
+		case l["msg"] == "request" && strings.HasPrefix(l["path"].(string), "/xxx"):
+			long = len(l["path"].(string)) <= maxLogValue+len("…")
+		}
+	}
+	if !added || !rolled || !request || !missing || !long {
+		t.Fatalf("missing log lines: added %v rolled %v request %v missing game %v long path %v\n%s", added, rolled, request, missing, long, logs.buf.String())
+	}
+	if ids["bad id; level=ERROR"] { t.Fatal("an invalid request ID reached the log") }
+}
+
+func TestTextLogsQuoteUserInput(t *testing.T) {
+	cfg := config.Default()
+	var logs syncBuffer
+	newLogger(&logs, cfg).Info("player added", "player", "Arun\nlevel=ERROR msg=forged")
+	if out := logs.buf.String(); strings.Count(out, "\n") != 1 || !strings.Contains(out, `player="Arun\nlevel=ERROR msg=forged"`) {
+		t.Fatalf("user input not quoted: %q", out)
+	}
+}
@@ -1,119 +1,257 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
-	"path"
-	"path/filepath"
+	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/arsulegai/snakeandladder/internal/broker"
+	"github.com/arsulegai/snakeandladder/internal/config"
 	"github.com/arsulegai/snakeandladder/internal/game"
 )
 
-// BuildMux constructs the HTTP handler for the API and static SPA.
-func BuildMux(reg *game.Registry) http.Handler {
+// BuildMux constructs the HTTP handler for the API and static SPA, ready to
+// serve.
+func BuildMux(reg *game.Registry, cfg config.Config) http.Handler {
+	a := newApp(reg, cfg, slog.Default())
+	a.health.ready.Store(true)
+	return a.handler
+}
+
+// app is the HTTP side of the server: the API, the frontend, health probes
+// and metrics.
+type app struct {
+	handler http.Handler
+	health  *health
+	api     *api
+}
+
+func newApp(reg *game.Registry, cfg config.Config, logger *slog.Logger) *app {
 	mux := http.NewServeMux()
+	m := newServerMetrics(reg)
 
-	// API routes
-	mux.HandleFunc("/api/games", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-			return
-		}
-		grid := 10
-		if v := r.URL.Query().Get("grid"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil && n > 1 {
-				grid = n
-			}
-		}
-		id, _ := reg.Create(grid)
-		log.Printf("created game id=%s grid=%d", id, grid)
-		writeJSON(w, http.StatusCreated, map[string]string{"id": id})
-	})
+	// API routes, under /api/v1 and the unversioned /api used by older clients
+	a := newAPI(reg, cfg, m)
+	routes := a.routes()
+	a.mount(mux, apiV1, routes)
+	a.mount(mux, apiLegacy, routes)
 
-	mux.HandleFunc("/api/games/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		// paths: /api/games/{id}/players, /api/games/{id}/roll, /api/games/{id}/state, /api/games/{id}/stream
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/games/"), "/")
-		if len(parts) < 1 {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
-			return
+	// Probes and metrics
+	h := &health{}
+	mux.Handle("/healthz", m.instrument("/healthz", http.HandlerFunc(h.live)))
+	mux.Handle("/readyz", m.instrument("/readyz", http.HandlerFunc(h.readiness)))
+	mux.Handle("/metrics", m.instrument("/metrics", http.HandlerFunc(m.serve)))
+
+	// Static frontend
+	mux.Handle("/", m.instrument("static", spaHandler(cfg.StaticDir)))
+
+	return &app{handler: withRequestID(logger, withCORS(mux, cfg.CORSOrigins)), health: h, api: a}
+}
+
+func main() {
+	cfg, print, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) { return }
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if print {
+		if err := config.Write(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		id := parts[0]
-		g, ok := reg.Get(id)
-		if !ok {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "game not found"})
-			return
+		return
+	}
+	logger := newLogger(os.Stderr, cfg)
+	slog.SetDefault(logger)
+
+	reg := game.NewRegistry()
+	reg.SetMaxGames(cfg.MaxGames)
+	if cfg.Broker != "" {
+		b, err := broker.Open(cfg.Broker)
+		if err != nil {
+			slog.Error("cannot reach the broker", "err", err)
+			os.Exit(1)
 		}
-		if len(parts) == 1 {
-			if r.Method == http.MethodGet {
-				writeJSON(w, http.StatusOK, g.State())
-				return
-			}
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
-			return
+		defer b.Close()
+		host, _ := os.Hostname()
+		reg.Share(b, host+"-"+strconv.Itoa(os.Getpid()), cfg.GameTTL)
+	}
+	go expireGames(reg, cfg)
+	a := newApp(reg, cfg, logger)
+	srv := newServer(cfg, a.handler)
+	srv.ErrorLog = slog.NewLogLogger(logger.Handler(), slog.LevelWarn)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		slog.Error("cannot listen", "addr", cfg.Addr, "err", err)
+		os.Exit(1)
+	}
+	a.health.ready.Store(true)
+	slog.Info("Snake & Ladder server listening", "addr", ln.Addr().String(), "tls", cfg.TLS())
+
+	stopped := make(chan error, 1)
+	go func() {
+		if cfg.TLS() {
+			stopped <- srv.ServeTLS(ln, cfg.TLSCert, cfg.TLSKey)
+		} else {
+			stopped <- srv.Serve(ln)
 		}
-		switch parts[1] {
-		case "players":
-			if r.Method != http.MethodPost {
-				writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-				return
-			}
-			var body struct{ Name string `json:"name"` }
-			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Name) == "" {
-				log.Printf("add player invalid body: %v", err)
-				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body"})
-				return
-			}
-			if err := g.AddPlayer(body.Name); err != nil {
-				log.Printf("add player error: %v", err)
-				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-				return
-			}
-			log.Printf("added player '%s' to game %s", body.Name, id)
-			writeJSON(w, http.StatusCreated, g.State())
-		case "roll":
-			if r.Method != http.MethodPost {
-				writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-				return
-			}
-			roll, winner, err := g.RollDice()
-			if err != nil {
-				log.Printf("roll error: %v", err)
-				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-				return
-			}
-			resp := map[string]interface{}{"roll": roll}
-			if winner != nil { resp["winner"] = *winner }
-			writeJSON(w, http.StatusOK, resp)
-		case "state":
-			writeJSON(w, http.StatusOK, g.State())
-		case "stream":
-			log.Printf("client subscribed to stream for game %s", id)
-			g.Subscribe(w, r)
-		default:
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}()
+	select {
+	case err := <-stopped:
+		slog.Error("server stopped", "err", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+	// a second signal kills the process
+	stop()
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := a.shutdown(ctx, srv); err != nil {
+		slog.Warn("requests cut off at the shutdown deadline", "err", err)
+		_ = srv.Close()
+	}
+	slog.Info("server stopped")
+}
+
+// newServer returns an HTTP server for handler with the configured timeouts.
+func newServer(cfg config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    64 << 10,
+	}
+}
+
+// expireGames removes idle and finished games as their TTLs run out.
+func expireGames(reg *game.Registry, cfg config.Config) {
+	if cfg.GameTTL == 0 && cfg.FinishedTTL == 0 { return }
+	for now := range time.Tick(time.Minute) {
+		if gone := reg.Expire(now, cfg.GameTTL, cfg.FinishedTTL); len(gone) > 0 {
+			slog.Info("games expired", "count", len(gone), "games", gone)
 		}
-	})
+	}
+}
 
-	// Static frontend
-	staticDir := filepath.FromSlash("web")
-	mux.Handle("/", spaHandler(staticDir))
+// boardDefFromRequest reads the board for a new game. A JSON body holding a
+// board definition wins; otherwise ?grid= (default cfg.DefaultGrid), ?width=,
+// ?height= and ?layout= describe a generated grid. Boards wider or taller than
+// cfg.MaxGrid are refused.
+func boardDefFromRequest(r *http.Request, cfg config.Config) (*game.BoardDef, error) {
+	def, err := readBoardDef(r, cfg.DefaultGrid)
+	if err != nil { return nil, err }
+	if err := checkBoardSize(def, cfg); err != nil { return nil, err }
+	return def, nil
+}
+
+// checkBoardSize refuses boards wider or taller than cfg.MaxGrid, path boards
+// included, before anything is built from them.
+func checkBoardSize(def *game.BoardDef, cfg config.Config) error {
+	w, h := def.Size()
+	if w <= cfg.MaxGrid && h <= cfg.MaxGrid { return nil }
+	return &game.Error{
+		Kind:    game.KindInvalid,
+		Code:    game.ErrInvalidBoard.Code,
+		Message: fmt.Sprintf("board %dx%d is larger than the %dx%d limit", w, h, cfg.MaxGrid, cfg.MaxGrid),
+		Details: map[string]interface{}{"max": cfg.MaxGrid},
+	}
+}
 
-	return withCORS(mux)
+// checkSnapshot applies the limits on new games to a snapshot about to be
+// imported or forked: the board size and how many dice draws get replayed.
+func checkSnapshot(s game.Snapshot, cfg config.Config) error {
+	if s.Dice.Draws > game.MaxDraws {
+		return &game.Error{
+			Kind:    game.KindInvalid,
+			Code:    game.ErrInvalidSnapshot.Code,
+			Message: fmt.Sprintf("dice draws %d exceed %d", s.Dice.Draws, game.MaxDraws),
+			Details: map[string]interface{}{"max": game.MaxDraws},
+		}
+	}
+	return checkBoardSize(&s.Board, cfg)
 }
 
-func main() {
-	reg := game.NewRegistry()
-	handler := BuildMux(reg)
-	addr := ":8080"
-	log.Printf("Snake & Ladder server listening on %s", addr)
-	if err := http.ListenAndServe(addr, handler); err != nil {
-		log.Fatal(err)
+func readBoardDef(r *http.Request, grid int) (*game.BoardDef, error) {
+	if r.Body != nil && r.ContentLength != 0 {
+		body, err := io.ReadAll(r.Body)
+		if err != nil { return nil, err }
+		if len(bytes.TrimSpace(body)) > 0 {
+			return game.ParseBoardDef(bytes.NewReader(body))
+		}
+	}
+	q := r.URL.Query()
+	if v := q.Get("grid"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 1 {
+			grid = n
+		}
+	}
+	def := &game.BoardDef{Width: grid, Height: grid}
+	for _, dim := range []struct {
+		name string
+		dst  *int
+	}{{"width", &def.Width}, {"height", &def.Height}} {
+		if v := q.Get(dim.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 { return nil, &paramError{dim.name, v} }
+			*dim.dst = n
+		}
+	}
+	layout, err := game.ParseLayout(q.Get("layout"))
+	if err != nil { return nil, err }
+	def.Layout = layout
+	return def, nil
+}
+
+// rulesFromRequest reads optional rule variations from the query string:
+// ?pawns= (1-4), ?moveSeconds=, ?collision=ignore|capture|swap,
+// ?teamWin=any|all and ?undo=host|vote|off.
+func rulesFromRequest(r *http.Request) (game.Rules, error) {
+	var rules game.Rules
+	q := r.URL.Query()
+	rules.Collision = game.CollisionRule(q.Get("collision"))
+	rules.TeamWin = game.TeamWinRule(q.Get("teamWin"))
+	rules.Undo = game.UndoRule(q.Get("undo"))
+	for _, opt := range []struct {
+		name string
+		dst  *int
+	}{{"pawns", &rules.Pawns}, {"moveSeconds", &rules.MoveSeconds}} {
+		if v := q.Get(opt.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil { return rules, &paramError{opt.name, v} }
+			*opt.dst = n
+		}
 	}
+	return rules, nil
+}
+
+// seedFromRequest reads an optional ?seed= fixing board generation and dice.
+func seedFromRequest(r *http.Request) (*int64, error) {
+	v := r.URL.Query().Get("seed")
+	if v == "" { return nil, nil }
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil { return nil, &paramError{"seed", v} }
+	return &n, nil
 }
 
 func writeJSON(w http.ResponseWriter, code int, v interface{}) {
@@ -122,26 +260,22 @@ func writeJSON(w http.ResponseWriter, code int, v interface{}) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func withCORS(next http.Handler) http.Handler {
+// withCORS lets browsers call the API from origins, or from anywhere when
+// origins is ["*"].
+func withCORS(next http.Handler, origins []string) http.Handler {
+	anyOrigin := len(origins) == 1 && origins[0] == "*"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if anyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if o := r.Header.Get("Origin"); o != "" && slices.Contains(origins, strings.TrimSuffix(o, "/")) {
+				w.Header().Set("Access-Control-Allow-Origin", o)
+			}
+		}
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
 		if r.Method == http.MethodOptions { w.WriteHeader(http.StatusNoContent); return }
 		next.ServeHTTP(w, r)
 	})
 }
-
-func spaHandler(staticDir string) http.Handler {
-	fs := http.FileServer(http.Dir(staticDir))
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		p := path.Clean(r.URL.Path)
-		if p == "/" || strings.HasPrefix(p, "/assets/") || strings.HasSuffix(p, ".css") || strings.HasSuffix(p, ".js") || strings.HasSuffix(p, ".png") || strings.HasSuffix(p, ".svg") {
-			fs.ServeHTTP(w, r)
-			return
-		}
-		// fallback to index
-		r.URL.Path = "/"
-		fs.ServeHTTP(w, r)
-	})
-}
@@ -3,22 +3,30 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/arsulegai/snakeandladder/internal/broker"
+	"github.com/arsulegai/snakeandladder/internal/config"
 	"github.com/arsulegai/snakeandladder/internal/game"
+	"github.com/arsulegai/snakeandladder/pkg/client"
 )
 
 type createResp struct{ ID string `json:"id"` }
 
 func TestCreateGameAndAddPlayers(t *testing.T) {
 	reg := game.NewRegistry()
-	ts := httptest.NewServer(BuildMux(reg))
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
 	defer ts.Close()
 
 	// create game
@@ -46,7 +54,7 @@ func TestCreateGameAndAddPlayers(t *testing.T) {
 
 func TestSSEInitialEvent(t *testing.T) {
 	reg := game.NewRegistry()
-	ts := httptest.NewServer(BuildMux(reg))
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
 	defer ts.Close()
 
 	// create game
@@ -75,3 +83,725 @@ func TestSSEInitialEvent(t *testing.T) {
 	}
 	t.Fatal("no SSE data line received in time")
 }
+
+func TestCreateGameLayout(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/api/games?grid=5&layout=row-major", "application/json", nil)
+	if err != nil { t.Fatalf("create game request err: %v", err) }
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+	r, err := http.Get(ts.URL + "/api/games/" + cr.ID + "/state")
+	if err != nil { t.Fatalf("state err: %v", err) }
+	defer r.Body.Close()
+	var st struct {
+		Layout string `json:"layout"`
+		Snakes []struct{ Head struct{ Square int `json:"square"` } `json:"head"` } `json:"snakes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil { t.Fatalf("decode state: %v", err) }
+	if st.Layout != "row-major" { t.Fatalf("expected row-major layout, got %q", st.Layout) }
+	for _, s := range st.Snakes {
+		if s.Head.Square < 1 || s.Head.Square > 25 { t.Fatalf("snake head square out of range: %d", s.Head.Square) }
+	}
+
+	bad, _ := http.Post(ts.URL+"/api/games?layout=spiral", "application/json", nil)
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusUnprocessableEntity { t.Fatalf("expected 422 for unknown layout, got %d", bad.StatusCode) }
+}
+
+func TestCreateGameCustomBoard(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+
+	def := `{"path": [[0,0],[0,1],[0,2],[1,2],[1,1],[1,0]], "ladders": [{"from": 2, "to": 5}]}`
+	resp, err := http.Post(ts.URL+"/api/games", "application/json", strings.NewReader(def))
+	if err != nil { t.Fatalf("create game request err: %v", err) }
+	if resp.StatusCode != http.StatusCreated { t.Fatalf("create game code=%d", resp.StatusCode) }
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+
+	r, err := http.Get(ts.URL + "/api/games/" + cr.ID + "/state")
+	if err != nil { t.Fatalf("state err: %v", err) }
+	defer r.Body.Close()
+	var st struct {
+		GridSize int `json:"gridSize"`
+		Board    struct {
+			Width   int      `json:"width"`
+			Height  int      `json:"height"`
+			Squares int      `json:"squares"`
+			Cells   [][2]int `json:"cells"`
+		} `json:"board"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil { t.Fatalf("decode state: %v", err) }
+	if st.GridSize != 0 || st.Board.Width != 3 || st.Board.Height != 2 || st.Board.Squares != 6 || len(st.Board.Cells) != 6 {
+		t.Fatalf("unexpected board in state: %+v gridSize=%d", st.Board, st.GridSize)
+	}
+
+	bad, _ := http.Post(ts.URL+"/api/games", "application/json", strings.NewReader(`{"path": [[0,0],[0,0]]}`))
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusUnprocessableEntity { t.Fatalf("expected 422 for invalid board, got %d", bad.StatusCode) }
+
+	rect, _ := http.Post(ts.URL+"/api/games?width=7&height=4", "application/json", nil)
+	rect.Body.Close()
+	if rect.StatusCode != http.StatusCreated { t.Fatalf("expected 201 for rectangular board, got %d", rect.StatusCode) }
+}
+
+func TestMoveEndpointNeedsPendingRoll(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+
+	resp, _ := http.Post(ts.URL+"/api/games?grid=10&pawns=2", "application/json", nil)
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated { t.Fatalf("create game code=%d", resp.StatusCode) }
+	g, _ := reg.Get(cr.ID)
+	if g.Rules().Pawns != 2 { t.Fatalf("expected 2 pawns, got %d", g.Rules().Pawns) }
+
+	r, _ := http.Post(ts.URL+"/api/games/"+cr.ID+"/move", "application/json", strings.NewReader(`{"pawn": 0}`))
+	r.Body.Close()
+	if r.StatusCode != http.StatusConflict { t.Fatalf("expected 409 without a pending roll, got %d", r.StatusCode) }
+	r, _ = http.Post(ts.URL+"/api/games/"+cr.ID+"/move", "application/json", strings.NewReader(`{}`))
+	r.Body.Close()
+	if r.StatusCode != http.StatusUnprocessableEntity { t.Fatalf("expected 422 without a pawn, got %d", r.StatusCode) }
+
+	bad, _ := http.Post(ts.URL+"/api/games?pawns=7", "application/json", nil)
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusUnprocessableEntity { t.Fatalf("expected 422 for 7 pawns, got %d", bad.StatusCode) }
+}
+
+func TestEventsLog(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+
+	resp, _ := http.Post(ts.URL+"/api/games?grid=10&collision=capture", "application/json", nil)
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+	g, _ := reg.Get(cr.ID)
+	_ = g.AddPlayer("Arun")
+	_ = g.AddPlayer("Megha")
+	for i := 0; i < 3; i++ {
+		if _, _, err := g.Roll(); err != nil { t.Fatalf("roll: %v", err) }
+	}
+	var moves []struct {
+		Player string `json:"player"`
+		Roll   int    `json:"roll"`
+	}
+	r, err := http.Get(ts.URL + "/api/games/" + cr.ID + "/events?since=1")
+	if err != nil { t.Fatalf("events err: %v", err) }
+	json.NewDecoder(r.Body).Decode(&moves)
+	r.Body.Close()
+	if len(moves) != 2 || moves[0].Player != "Megha" || moves[0].Roll < 1 { t.Fatalf("unexpected events %+v", moves) }
+
+	bad, _ := http.Post(ts.URL+"/api/games?collision=bounce", "application/json", nil)
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusUnprocessableEntity { t.Fatalf("expected 422 for unknown collision rule, got %d", bad.StatusCode) }
+}
+
+func TestAddPlayersToTeams(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+
+	resp, _ := http.Post(ts.URL+"/api/games?grid=10&teamWin=all", "application/json", nil)
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+	add := func(body string) int {
+		r, err := http.Post(ts.URL+"/api/games/"+cr.ID+"/players", "application/json", strings.NewReader(body))
+		if err != nil { t.Fatalf("add player err: %v", err) }
+		r.Body.Close()
+		return r.StatusCode
+	}
+	if c := add(`{"name":"Arun","team":"Red"}`); c != http.StatusCreated { t.Fatalf("add Arun code=%d", c) }
+	if c := add(`{"name":"Megha","team":"Blue"}`); c != http.StatusCreated { t.Fatalf("add Megha code=%d", c) }
+	if c := add(`{"name":"Solo"}`); c != http.StatusUnprocessableEntity { t.Fatalf("expected 422 for a player without team, got %d", c) }
+
+	r, err := http.Get(ts.URL + "/api/games/" + cr.ID + "/state")
+	if err != nil { t.Fatalf("state err: %v", err) }
+	defer r.Body.Close()
+	var st struct {
+		Rules struct{ TeamWin string `json:"teamWin"` } `json:"rules"`
+		Teams []struct {
+			Name    string   `json:"name"`
+			Members []string `json:"members"`
+		} `json:"teams"`
+	}
+	json.NewDecoder(r.Body).Decode(&st)
+	if st.Rules.TeamWin != "all" || len(st.Teams) != 2 || st.Teams[1].Name != "Blue" || st.Teams[1].Members[0] != "Megha" {
+		t.Fatalf("unexpected teams in state: %+v", st)
+	}
+}
+
+func TestUndoEndpoint(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+
+	resp, _ := http.Post(ts.URL+"/api/games?grid=10&undo=vote", "application/json", nil)
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+	g, _ := reg.Get(cr.ID)
+	_ = g.AddPlayer("Arun")
+	_ = g.AddPlayer("Megha")
+	if _, _, err := g.Roll(); err != nil { t.Fatalf("roll: %v", err) }
+
+	undo := func(player string) int {
+		r, err := http.Post(ts.URL+"/api/games/"+cr.ID+"/undo", "application/json", strings.NewReader(`{"player":"`+player+`"}`))
+		if err != nil { t.Fatalf("undo err: %v", err) }
+		r.Body.Close()
+		return r.StatusCode
+	}
+	if c := undo("Arun"); c != http.StatusAccepted { t.Fatalf("expected 202 for first vote, got %d", c) }
+	if c := undo("Megha"); c != http.StatusOK { t.Fatalf("expected 200 once all voted, got %d", c) }
+	if c := undo("Megha"); c != http.StatusConflict { t.Fatalf("expected 409 with nothing to undo, got %d", c) }
+	if st := g.State(); st.Moves != 0 { t.Fatalf("expected the roll to be undone, moves=%d", st.Moves) }
+}
+
+func TestSnapshotImportAndFork(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+
+	resp, _ := http.Post(ts.URL+"/api/games?grid=10&seed=3", "application/json", nil)
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+	g, _ := reg.Get(cr.ID)
+	_ = g.AddPlayer("Arun")
+	_ = g.AddPlayer("Megha")
+	if _, _, err := g.Roll(); err != nil { t.Fatalf("roll: %v", err) }
+
+	r, err := http.Get(ts.URL + "/api/games/" + cr.ID + "/snapshot")
+	if err != nil { t.Fatalf("snapshot err: %v", err) }
+	snap, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK { t.Fatalf("snapshot code=%d", r.StatusCode) }
+	var s game.Snapshot
+	if err := json.Unmarshal(snap, &s); err != nil || s.Version != game.SnapshotVersion || s.Dice.Seed != 3 || len(s.History) != 1 {
+		t.Fatalf("unexpected snapshot %s (%v)", snap, err)
+	}
+
+	for _, url := range []string{"/api/games/import", "/api/games/" + cr.ID + "/fork"} {
+		body := io.Reader(bytes.NewReader(snap))
+		if strings.HasSuffix(url, "/fork") { body = nil }
+		r, err := http.Post(ts.URL+url, "application/json", body)
+		if err != nil { t.Fatalf("%s err: %v", url, err) }
+		var cr2 createResp
+		json.NewDecoder(r.Body).Decode(&cr2)
+		r.Body.Close()
+		if r.StatusCode != http.StatusCreated || cr2.ID == "" || cr2.ID == cr.ID { t.Fatalf("%s code=%d id=%q", url, r.StatusCode, cr2.ID) }
+		dup, _ := reg.Get(cr2.ID)
+		if st := dup.State(); st.Moves != 1 || len(st.Players) != 2 { t.Fatalf("%s: unexpected state %+v", url, st) }
+	}
+
+	r, _ = http.Post(ts.URL+"/api/games/import", "application/json", strings.NewReader(`{"version":99}`))
+	r.Body.Close()
+	if r.StatusCode != http.StatusUnprocessableEntity { t.Fatalf("expected 422 for a bad snapshot, got %d", r.StatusCode) }
+}
+
+func TestClientAgainstServer(t *testing.T) {
+	ts := httptest.NewServer(BuildMux(game.NewRegistry(), config.Default()))
+	defer ts.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := client.New(ts.URL)
+
+	id, err := c.CreateGame(ctx, client.GameOptions{Grid: 6})
+	if err != nil { t.Fatalf("create game: %v", err) }
+	events, err := c.Stream(ctx, id)
+	if err != nil { t.Fatalf("stream: %v", err) }
+	if ev := <-events; ev.State == nil || ev.State.Board.Squares != 36 { t.Fatalf("expected initial state, got %+v", ev) }
+	if _, err := c.Roll(ctx, id); !errors.Is(err, client.ErrNotEnoughPlayers) { t.Fatalf("rolling without players should fail, got %v", err) }
+	for _, name := range []string{"Arun", "Megha"} {
+		if _, err := c.AddPlayer(ctx, id, name); err != nil { t.Fatalf("add %s: %v", name, err) }
+	}
+	res, err := c.Roll(ctx, id)
+	if err != nil || res.Roll < 1 || res.Move.Player != "Arun" { t.Fatalf("roll: %+v %v", res, err) }
+	st, err := c.State(ctx, id)
+	if err != nil || st.Moves != 1 || st.Players[0].Position.Square() != res.Move.To { t.Fatalf("state: %+v %v", st, err) }
+	for ev := range events {
+		if ev.State != nil && ev.State.Moves == 1 { return }
+	}
+	t.Fatal("stream ended before the roll arrived")
+}
+
+func TestErrorResponses(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+	g := game.New(10)
+	id := reg.Add(g)
+	_ = g.AddPlayer("Arun")
+	_ = g.AddPlayer("Megha")
+	if _, _, err := g.Roll(); err != nil { t.Fatalf("roll: %v", err) }
+
+	for _, tc := range []struct {
+		path, body string
+		status     int
+		code       string
+		details    map[string]interface{}
+	}{
+		{"/api/games/" + id + "/undo", `{"player":"Megha"}`, http.StatusForbidden, "not_host", map[string]interface{}{"host": "Arun"}},
+		{"/api/games/" + id + "/players", `{"name":"arun"}`, http.StatusConflict, "duplicate_name", map[string]interface{}{"name": "arun"}},
+		{"/api/games/" + id + "/players", `{"name":""}`, http.StatusUnprocessableEntity, "missing_field", map[string]interface{}{"field": "name"}},
+		{"/api/games/" + id + "/players", `{`, http.StatusBadRequest, "invalid_body", nil},
+		{"/api/games/nope/roll", ``, http.StatusNotFound, "game_not_found", map[string]interface{}{"id": "nope"}},
+		{"/api/games?width=wide", ``, http.StatusBadRequest, "invalid_parameter", map[string]interface{}{"parameter": "width"}},
+	} {
+		r, err := http.Post(ts.URL+tc.path, "application/json", strings.NewReader(tc.body))
+		if err != nil { t.Fatalf("%s: %v", tc.path, err) }
+		var body struct {
+			Error struct {
+				Code    string                 `json:"code"`
+				Message string                 `json:"message"`
+				Details map[string]interface{} `json:"details"`
+			} `json:"error"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&body)
+		r.Body.Close()
+		if err != nil || r.StatusCode != tc.status || body.Error.Code != tc.code || body.Error.Message == "" {
+			t.Errorf("%s %s: got %d %+v (%v), want %d %s", tc.path, tc.body, r.StatusCode, body.Error, err, tc.status, tc.code)
+			continue
+		}
+		for k, v := range tc.details {
+			if body.Error.Details[k] != v { t.Errorf("%s: details %v, want %s=%v", tc.path, body.Error.Details, k, v) }
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+	id := reg.Add(game.New(10))
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{http.MethodGet, "/api/v1/games/" + id, http.StatusOK, ""},
+		{http.MethodGet, "/api/games/" + id, http.StatusOK, ""},
+		{http.MethodGet, "/api/v1/games/" + id + "/state", http.StatusOK, ""},
+		{http.MethodPost, "/api/v1/games/" + id, http.StatusMethodNotAllowed, "GET"},
+		{http.MethodGet, "/api/v1/games/" + id + "/roll", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodPut, "/api/games/" + id + "/players", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodGet, "/api/v1/games/import", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodGet, "/api/v1/games/" + id + "/roll/again", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/v1/games/" + id + "/nope", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/v2/games/" + id, http.StatusNotFound, ""},
+	} {
+		req, _ := http.NewRequest(tc.method, ts.URL+tc.path, nil)
+		r, err := http.DefaultClient.Do(req)
+		if err != nil { t.Fatalf("%s %s: %v", tc.method, tc.path, err) }
+		r.Body.Close()
+		if r.StatusCode != tc.status || r.Header.Get("Allow") != tc.allow {
+			t.Errorf("%s %s: got %d Allow %q, want %d Allow %q", tc.method, tc.path, r.StatusCode, r.Header.Get("Allow"), tc.status, tc.allow)
+		}
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s %s: content type %q", tc.method, tc.path, ct)
+		}
+	}
+}
+
+func TestConfigLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxGrid, cfg.DefaultGrid, cfg.MaxPlayers = 12, 6, 2
+	reg := game.NewRegistry()
+	reg.SetMaxGames(1)
+	ts := httptest.NewServer(BuildMux(reg, cfg))
+	defer ts.Close()
+
+	post := func(path, body string) (*http.Response, string) {
+		r, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil { t.Fatalf("%s: %v", path, err) }
+		data, _ := io.ReadAll(r.Body)
+		r.Body.Close()
+		return r, string(data)
+	}
+	for _, path := range []string{"/api/v1/games?grid=13", "/api/v1/games?width=6&height=20"} {
+		if r, body := post(path, ""); r.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, "invalid_board") {
+			t.Fatalf("%s: expected invalid_board, got %d %s", path, r.StatusCode, body)
+		}
+	}
+	if r, body := post("/api/v1/games", `{"width": 13, "height": 2}`); r.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("custom board over the limit: got %d %s", r.StatusCode, body)
+	}
+	r, body := post("/api/v1/games", "")
+	if r.StatusCode != http.StatusCreated { t.Fatalf("create: %d %s", r.StatusCode, body) }
+	var cr createResp
+	_ = json.Unmarshal([]byte(body), &cr)
+	g, _ := reg.Get(cr.ID)
+	if st := g.State(); st.GridSize != 6 { t.Fatalf("expected the configured default grid 6, got %d", st.GridSize) }
+	if r, body := post("/api/v1/games", ""); r.StatusCode != http.StatusConflict || !strings.Contains(body, "too_many_games") {
+		t.Fatalf("expected too_many_games, got %d %s", r.StatusCode, body)
+	}
+	if r, body := post("/api/v1/games/"+cr.ID+"/fork", ""); r.StatusCode != http.StatusConflict || !strings.Contains(body, "too_many_games") {
+		t.Fatalf("fork: expected too_many_games, got %d %s", r.StatusCode, body)
+	}
+	for _, name := range []string{"Arun", "Megha"} { post("/api/v1/games/"+cr.ID+"/players", `{"name":"`+name+`"}`) }
+	if r, body := post("/api/v1/games/"+cr.ID+"/players", `{"name":"Ravi"}`); r.StatusCode != http.StatusConflict || !strings.Contains(body, "game_full") {
+		t.Fatalf("expected game_full, got %d %s", r.StatusCode, body)
+	}
+}
+
+// TestBoardLimits holds path boards, imports and forks to max_grid, as new
+// grids are.
+func TestBoardLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxGrid = 12
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, cfg))
+	defer ts.Close()
+	post := func(url, body string) (*http.Response, string) {
+		r, err := http.Post(url, "application/json", strings.NewReader(body))
+		if err != nil { t.Fatalf("%s: %v", url, err) }
+		data, _ := io.ReadAll(r.Body)
+		r.Body.Close()
+		return r, string(data)
+	}
+	big := game.New(20)
+	snap, _ := json.Marshal(big.Snapshot())
+	bigID := reg.Add(big)
+	for _, tc := range []struct{ path, body, code string }{
+		{"/api/v1/games", `{"path": [[0,0],[0,1],[3000,3000]]}`, "invalid_board"},
+		{"/api/v1/games/import", string(snap), "invalid_board"},
+		{"/api/v1/games/import", `{"version": 1, "board": {"width": 4, "height": 4}, "dice": {"seed": 1, "draws": 100000000}}`, "invalid_snapshot"},
+		{"/api/v1/games/" + bigID + "/fork", "", "invalid_board"},
+	} {
+		if r, body := post(ts.URL+tc.path, tc.body); r.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, tc.code) {
+			t.Fatalf("%s: expected %s, got %d %s", tc.path, tc.code, r.StatusCode, body)
+		}
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	cfg := config.Default()
+	cfg.CORSOrigins = []string{"https://play.example"}
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, cfg))
+	defer ts.Close()
+	for origin, want := range map[string]string{"https://play.example": "https://play.example", "https://evil.example": "", "": ""} {
+		req, _ := http.NewRequest(http.MethodOptions, ts.URL+"/api/v1/games", nil)
+		if origin != "" { req.Header.Set("Origin", origin) }
+		r, err := http.DefaultClient.Do(req)
+		if err != nil { t.Fatal(err) }
+		r.Body.Close()
+		if got := r.Header.Get("Access-Control-Allow-Origin"); got != want { t.Errorf("origin %q: allowed %q, want %q", origin, got, want) }
+		if r.Header.Get("Vary") != "Origin" { t.Errorf("origin %q: expected Vary: Origin", origin) }
+	}
+	// event streams follow the same origins
+	id := reg.Add(game.New(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/games/"+id+"/stream", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil { t.Fatal(err) }
+	r.Body.Close()
+	if got := r.Header.Get("Access-Control-Allow-Origin"); got != "" { t.Errorf("stream allowed origin %q", got) }
+}
+
+func TestInputLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxBody, cfg.MaxName = 2048, 8
+	cfg.GameRate, cfg.GameBurst = 0.001, 5
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, cfg))
+	defer ts.Close()
+	id := reg.Add(game.New(10))
+	other := reg.Add(game.New(10))
+
+	for _, tc := range []struct {
+		path, body string
+		status     int
+		code       string
+	}{
+		{"/api/v1/games/" + id + "/players", `{"name":"Alexandria"}`, http.StatusUnprocessableEntity, "invalid_name"},
+		{"/api/v1/games/" + id + "/players", `{"name":"Arun\u0007"}`, http.StatusUnprocessableEntity, "invalid_name"},
+		{"/api/v1/games/" + id + "/players", `{"name":"Arun"}`, http.StatusCreated, ""},
+		{"/api/v1/games/" + id + "/players", `{"name":"Megha"}`, http.StatusCreated, ""},
+		{"/api/v1/games/" + id + "/roll", ``, http.StatusOK, ""},
+		{"/api/v1/games/" + id + "/roll", ``, http.StatusTooManyRequests, "rate_limited"},
+		{"/api/v1/games/" + id + "/undo", `{"player":"Arun"}`, http.StatusTooManyRequests, "rate_limited"},
+		{"/api/v1/games/" + other + "/players", `{"name":"Ravi"}`, http.StatusCreated, ""},
+		{"/api/v1/games/import", `{"version":1,"history":[` + strings.Repeat(`{},`, 1000) + `{}]}`, http.StatusRequestEntityTooLarge, "body_too_large"},
+	} {
+		r, err := http.Post(ts.URL+tc.path, "application/json", strings.NewReader(tc.body))
+		if err != nil { t.Fatalf("%s: %v", tc.path, err) }
+		var body struct{ Error struct{ Code string `json:"code"` } `json:"error"` }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		r.Body.Close()
+		if r.StatusCode != tc.status || body.Error.Code != tc.code {
+			t.Fatalf("%s %s: got %d %q, want %d %q", tc.path, tc.body, r.StatusCode, body.Error.Code, tc.status, tc.code)
+		}
+		if r.StatusCode == http.StatusTooManyRequests && r.Header.Get("Retry-After") == "" { t.Fatalf("%s: 429 without Retry-After", tc.path) }
+	}
+}
+
+func TestIPRateLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.IPRate, cfg.IPBurst = 0.001, 2
+	ts := httptest.NewServer(BuildMux(game.NewRegistry(), cfg))
+	defer ts.Close()
+	for i, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+		r, err := http.Post(ts.URL+"/api/v1/games", "application/json", nil)
+		if err != nil { t.Fatal(err) }
+		r.Body.Close()
+		if r.StatusCode != want { t.Fatalf("create %d: got %d, want %d", i+1, r.StatusCode, want) }
+	}
+	// reading is not limited
+	r, err := http.Get(ts.URL + "/api/v1/games/1")
+	if err != nil { t.Fatal(err) }
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK { t.Fatalf("get state: %d", r.StatusCode) }
+}
+
+func TestBoardPictures(t *testing.T) {
+	cfg := config.Default()
+	cfg.IPRate, cfg.IPBurst = 0.001, 3
+	reg := game.NewRegistry()
+	srv := newApp(reg, cfg, slog.Default())
+	ts := httptest.NewServer(srv.handler)
+	defer ts.Close()
+	g := game.New(10)
+	_ = g.AddPlayer("Arun")
+	_ = g.AddPlayer("Megha")
+	id := reg.Add(g)
+	fetch := func() (int, []byte) {
+		r, err := http.Get(ts.URL + "/api/v1/games/" + id + "/board.png")
+		if err != nil { t.Fatal(err) }
+		defer r.Body.Close()
+		body, _ := io.ReadAll(r.Body)
+		return r.StatusCode, body
+	}
+	_, first := fetch()
+	if code, again := fetch(); code != http.StatusOK || !bytes.Equal(first, again) || len(srv.api.pictures.entries) != 1 { t.Fatalf("unchanged board not drawn once: %d, %d kept", code, len(srv.api.pictures.entries)) }
+	if _, _, err := g.Roll(); err != nil { t.Fatal(err) }
+	if code, moved := fetch(); code != http.StatusOK || bytes.Equal(first, moved) || len(srv.api.pictures.entries) != 1 { t.Fatalf("board not drawn again after a move: %d, %d kept", code, len(srv.api.pictures.entries)) }
+	if code, _ := fetch(); code != http.StatusTooManyRequests { t.Fatalf("expected the drawing to be rate limited, got %d", code) }
+}
+
+func TestStreamOutlivesWriteTimeout(t *testing.T) {
+	cfg := config.Default()
+	cfg.WriteTimeout = 200 * time.Millisecond
+	reg := game.NewRegistry()
+	g := game.New(10)
+	id := reg.Add(g)
+	ts := httptest.NewUnstartedServer(BuildMux(reg, cfg))
+	ts.Config = newServer(cfg, ts.Config.Handler)
+	ts.Start()
+	defer ts.Close()
+
+	r, err := http.Get(ts.URL + "/api/v1/games/" + id + "/stream")
+	if err != nil { t.Fatal(err) }
+	defer r.Body.Close()
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() { lines <- sc.Text() }
+		close(lines)
+	}()
+	time.Sleep(400 * time.Millisecond)
+	_ = g.AddPlayer("Arun")
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case l, ok := <-lines:
+			if !ok { t.Fatal("stream closed by the write timeout") }
+			if strings.Contains(l, "Arun") { return }
+		case <-deadline:
+			t.Fatal("no event after the write timeout passed")
+		}
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	reg := game.NewRegistry()
+	a := newApp(reg, config.Default(), slog.Default())
+	a.health.ready.Store(true)
+	srv := newServer(config.Default(), a.handler)
+	active := make(chan struct{}, 2)
+	srv.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateActive { active <- struct{}{} }
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil { t.Fatal(err) }
+	go func() { _ = srv.Serve(ln) }()
+	base := "http://" + ln.Addr().String()
+	g := game.New(10)
+	id := reg.Add(g)
+
+	stream, err := http.Get(base + "/api/v1/games/" + id + "/stream")
+	if err != nil { t.Fatal(err) }
+	defer stream.Body.Close()
+	sc := bufio.NewScanner(stream.Body)
+	if !sc.Scan() { t.Fatal("no initial state") }
+	<-active
+
+	// a request still sending its body when the shutdown starts
+	body, send := io.Pipe()
+	answered := make(chan int)
+	go func() {
+		r, err := http.Post(base+"/api/v1/games/"+id+"/players", "application/json", body)
+		if err != nil {
+			t.Error(err)
+			close(answered)
+			return
+		}
+		r.Body.Close()
+		answered <- r.StatusCode
+	}()
+	_, _ = send.Write([]byte(`{"name":`))
+	<-active
+
+	done := make(chan error)
+	go func() { done <- a.shutdown(context.Background(), srv) }()
+	var lines []string
+	for sc.Scan() { lines = append(lines, sc.Text()) }
+	if tail := strings.TrimSpace(strings.Join(lines, "\n")); !strings.HasSuffix(tail, "event: restarting\ndata: {\"message\":\"server restarting\"}") {
+		t.Fatalf("stream did not end with a restart event:\n%s", tail)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("shutdown returned with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	_, _ = send.Write([]byte(`"Arun"}`))
+	send.Close()
+	if code := <-answered; code != http.StatusCreated { t.Fatalf("request in flight got %d", code) }
+	if err := <-done; err != nil { t.Fatalf("shutdown: %v", err) }
+	if len(g.State().Players) != 1 { t.Fatal("the player added during shutdown is missing") }
+
+	for _, c := range []struct {
+		method, path string
+		code         int
+	}{
+		{"POST", "/api/v1/games", http.StatusServiceUnavailable},
+		{"POST", "/api/v1/games/" + id + "/fork", http.StatusServiceUnavailable},
+		{"GET", "/readyz", http.StatusServiceUnavailable},
+		{"GET", "/healthz", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		a.handler.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.code { t.Errorf("%s %s after shutdown: got %d, want %d", c.method, c.path, w.Code, c.code) }
+	}
+}
+
+func TestHealthAndMetrics(t *testing.T) {
+	reg := game.NewRegistry()
+	a := newApp(reg, config.Default(), slog.Default())
+	ts := httptest.NewServer(a.handler)
+	defer ts.Close()
+	get := func(path string) (int, string) {
+		r, err := http.Get(ts.URL + path)
+		if err != nil { t.Fatalf("%s: %v", path, err) }
+		defer r.Body.Close()
+		data, _ := io.ReadAll(r.Body)
+		return r.StatusCode, string(data)
+	}
+	if code, _ := get("/healthz"); code != http.StatusOK { t.Fatalf("healthz: %d", code) }
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable { t.Fatalf("readyz before ready: %d", code) }
+	a.health.ready.Store(true)
+	if code, _ := get("/readyz"); code != http.StatusOK { t.Fatalf("readyz: %d", code) }
+
+	c := client.New(ts.URL)
+	ctx := context.Background()
+	id, err := c.CreateGame(ctx, client.GameOptions{Seed: new(int64)})
+	if err != nil { t.Fatal(err) }
+	_, _ = c.AddPlayer(ctx, id, "Arun")
+	_, _ = c.AddPlayer(ctx, id, "Megha")
+	if _, err := c.Roll(ctx, id); err != nil { t.Fatal(err) }
+	_, _ = c.CreateGame(ctx, client.GameOptions{})
+	get("/api/v1/games/nope")
+
+	code, body := get("/metrics")
+	if code != http.StatusOK { t.Fatalf("metrics: %d", code) }
+	for _, want := range []string{
+		`snakeandladder_games{state="playing"} 1`,
+		`snakeandladder_games{state="waiting"} 1`,
+		`snakeandladder_games{state="finished"} 0`,
+		`snakeandladder_players_active 2`,
+		`snakeandladder_stream_subscribers 0`,
+		`# TYPE snakeandladder_broadcast_dropped_total counter`,
+		`snakeandladder_roll_duration_seconds_count 1`,
+		`snakeandladder_http_requests_total{route="/api/v1/games",method="POST",status="201"} 2`,
+		`snakeandladder_http_requests_total{route="/api/v1/games/{id}/players",method="POST",status="201"} 2`,
+		`snakeandladder_http_requests_total{route="/api/v1/games/{id}",method="GET",status="404"} 1`,
+		`snakeandladder_http_request_duration_seconds_count{route="/api/v1/games/{id}/roll",status="200"} 1`,
+		`snakeandladder_http_requests_total{route="/readyz",method="GET",status="503"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") { t.Errorf("metrics lack %s", want) }
+	}
+	if t.Failed() { t.Log(body) }
+}
+
+func TestSharedGames(t *testing.T) {
+	b := broker.NewLocal()
+	servers := make([]*httptest.Server, 2)
+	for i := range servers {
+		reg := game.NewRegistry()
+		reg.Share(b, "server"+strconv.Itoa(i), time.Hour)
+		servers[i] = httptest.NewServer(BuildMux(reg, config.Default()))
+		defer servers[i].Close()
+	}
+	one, two := servers[0].URL+"/api/v1/games", servers[1].URL+"/api/v1/games"
+
+	resp, err := http.Post(one+"?grid=10", "application/json", nil)
+	if err != nil { t.Fatal(err) }
+	var cr createResp
+	json.NewDecoder(resp.Body).Decode(&cr)
+	resp.Body.Close()
+	stream, err := http.Get(one + "/" + cr.ID + "/stream")
+	if err != nil { t.Fatal(err) }
+	defer stream.Body.Close()
+	sc := bufio.NewScanner(stream.Body)
+	if !sc.Scan() { t.Fatal("no initial state") }
+
+	// a player joins through the other server
+	r, err := http.Post(two+"/"+cr.ID+"/players", "application/json", strings.NewReader(`{"name":"Arun"}`))
+	if err != nil { t.Fatal(err) }
+	r.Body.Close()
+	if r.StatusCode != http.StatusCreated { t.Fatalf("join on the other server: %d", r.StatusCode) }
+	seen := make(chan bool)
+	go func() {
+		for sc.Scan() {
+			if strings.HasPrefix(sc.Text(), "data: ") && strings.Contains(sc.Text(), "Arun") {
+				seen <- true
+				return
+			}
+		}
+		close(seen)
+	}()
+	select {
+	case ok := <-seen:
+		if !ok { t.Fatal("stream ended without the join") }
+	case <-time.After(2 * time.Second):
+		t.Fatal("the join did not reach the stream on the first server")
+	}
+
+	for _, base := range []string{one, two} {
+		r, err := http.Get(base + "/" + cr.ID)
+		if err != nil { t.Fatal(err) }
+		var st game.State
+		json.NewDecoder(r.Body).Decode(&st)
+		r.Body.Close()
+		if len(st.Players) != 1 || st.Players[0].Name != "Arun" { t.Fatalf("%s sees players %+v", base, st.Players) }
+	}
+	// the second server's games are numbered after the first's
+	resp, _ = http.Post(two, "application/json", nil)
+	var next createResp
+	json.NewDecoder(resp.Body).Decode(&next)
+	resp.Body.Close()
+	if next.ID == "" || next.ID == cr.ID { t.Fatalf("second game got id %q after %q", next.ID, cr.ID) }
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+	"github.com/arsulegai/snakeandladder/internal/metrics"
+)
+
+// serverMetrics are the metrics served on /metrics.
+type serverMetrics struct {
+	reg      *metrics.Registry
+	requests *metrics.Counter
+	duration *metrics.Histogram
+	rolls    *metrics.Histogram
+}
+
+func newServerMetrics(games *game.Registry) *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		reg:      r,
+		requests: r.NewCounter("snakeandladder_http_requests_total", "HTTP requests by route, method and status.", "route", "method", "status"),
+		duration: r.NewHistogram("snakeandladder_http_request_duration_seconds", "Time to answer HTTP requests, by route and status. Event streams count until they close.", metrics.DefaultBuckets, "route", "status"),
+		rolls:    r.NewHistogram("snakeandladder_roll_duration_seconds", "Time taken to play a roll.", metrics.DefaultBuckets),
+	}
+	r.NewGaugeFunc("snakeandladder_games", "Games held, by state.", func() map[string]float64 {
+		st := games.Stats()
+		return map[string]float64{
+			metrics.Join("waiting"):  float64(st.Waiting),
+			metrics.Join("playing"):  float64(st.Playing),
+			metrics.Join("finished"): float64(st.Finished),
+		}
+	}, "state")
+	r.NewGaugeFunc("snakeandladder_players_active", "Players in games not finished yet.", func() map[string]float64 {
+		return map[string]float64{"": float64(games.Stats().Players)}
+	})
+	r.NewGaugeFunc("snakeandladder_stream_subscribers", "Open event streams.", func() map[string]float64 {
+		return map[string]float64{"": float64(games.Stats().Subscribers)}
+	})
+	r.NewCounterFunc("snakeandladder_broadcast_dropped_total", "Events dropped because a stream could not keep up.", func() map[string]float64 {
+		return map[string]float64{"": float64(game.DroppedEvents())}
+	})
+	return m
+}
+
+// instrument counts and times the requests h answers under route.
+func (m *serverMetrics) instrument(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r)
+		status := strconv.Itoa(rec.status())
+		m.requests.Inc(route, r.Method, status)
+		m.duration.Observe(time.Since(start).Seconds(), route, status)
+	})
+}
+
+func (m *serverMetrics) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	_ = m.reg.Write(w)
+}
+
+// statusRecorder remembers the status written through it. It passes
+// flushing on, so event streams keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 { s.code = code }
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.code == 0 { s.code = http.StatusOK }
+	return s.ResponseWriter.Write(p)
+}
+
+func (s *statusRecorder) Flush() {
+	if s.code == 0 { s.code = http.StatusOK }
+	if f, ok := s.ResponseWriter.(http.Flusher); ok { f.Flush() }
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok { return nil, nil, errors.New("hijacking not supported") }
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+func (s *statusRecorder) status() int {
+	if s.code == 0 { return http.StatusOK }
+	return s.code
+}
+
+// health answers the liveness and readiness probes. The server is live as
+// long as it answers, and ready while it takes new work.
+type health struct {
+	ready atomic.Bool
+}
+
+func (h *health) live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *health) readiness(w http.ResponseWriter, r *http.Request) {
+	if !h.ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/config"
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// spec is a minimal OpenAPI 3 reader: enough to find operations and check
+// JSON values against the schema subset openapi.json uses.
+type spec struct {
+	doc map[string]interface{}
+	hit map[string]bool // operationIds exercised
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil { t.Fatalf("openapi.json: %v", err) }
+	return &spec{doc: doc, hit: map[string]bool{}}
+}
+
+func obj(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// resolve follows a local $ref.
+func (s *spec) resolve(v map[string]interface{}) map[string]interface{} {
+	for v != nil {
+		ref, ok := v["$ref"].(string)
+		if !ok { return v }
+		var cur interface{} = s.doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") { cur = obj(cur)[part] }
+		v = obj(cur)
+	}
+	return v
+}
+
+// operation finds the operation for a request path, preferring literal path
+// segments over templated ones.
+func (s *spec) operation(method, path string) (map[string]interface{}, string) {
+	best, bestScore := "", -1
+	for tmpl := range obj(s.doc["paths"]) {
+		want, got := strings.Split(tmpl, "/"), strings.Split(path, "/")
+		if len(want) != len(got) { continue }
+		score := 0
+		for i := range want {
+			if strings.HasPrefix(want[i], "{") {
+				continue
+			} else if want[i] != got[i] {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > bestScore { best, bestScore = tmpl, score }
+	}
+	if best == "" { return nil, "" }
+	return obj(obj(obj(s.doc["paths"])[best])[strings.ToLower(method)]), best
+}
+
+// methods lists the methods documented for a path, as an Allow header would.
+func (s *spec) methods(tmpl string) string {
+	var m []string
+	for k, v := range obj(obj(s.doc["paths"])[tmpl]) {
+		if obj(v)["operationId"] != nil { m = append(m, strings.ToUpper(k)) }
+	}
+	sort.Strings(m)
+	return strings.Join(m, ", ")
+}
+
+// validate reports where value breaks schema, if anywhere.
+func (s *spec) validate(schema map[string]interface{}, value interface{}, at string) []string {
+	schema = s.resolve(schema)
+	if schema == nil { return nil }
+	var errs []string
+	fail := func(format string, args ...interface{}) { errs = append(errs, at+": "+fmt.Sprintf(format, args...)) }
+	if parts := asList(schema["allOf"]); parts != nil { schema = s.merge(parts) }
+	if enum := asList(schema["enum"]); enum != nil {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) { found = true }
+		}
+		if !found { fail("%v is not one of %v", value, enum) }
+	}
+	switch schema["type"] {
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected an object, got %T", value)
+			break
+		}
+		props := obj(schema["properties"])
+		for _, r := range asList(schema["required"]) {
+			if _, ok := m[r.(string)]; !ok { fail("missing %q", r) }
+		}
+		for k, v := range m {
+			if p, ok := props[k]; ok {
+				errs = append(errs, s.validate(obj(p), v, at+"."+k)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra { fail("unexpected property %q", k) }
+			case map[string]interface{}:
+				errs = append(errs, s.validate(extra, v, at+"."+k)...)
+			default:
+				// catch handlers growing undocumented fields
+				if props != nil { fail("undocumented property %q", k) }
+			}
+		}
+	case "array":
+		a, ok := value.([]interface{})
+		if !ok {
+			fail("expected an array, got %T", value)
+			break
+		}
+		if n, ok := schema["minItems"].(float64); ok && float64(len(a)) < n { fail("fewer than %v items", n) }
+		if n, ok := schema["maxItems"].(float64); ok && float64(len(a)) > n { fail("more than %v items", n) }
+		for i, v := range a { errs = append(errs, s.validate(obj(schema["items"]), v, fmt.Sprintf("%s[%d]", at, i))...) }
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			fail("expected a number, got %T", value)
+			break
+		}
+		if schema["type"] == "integer" && n != math.Trunc(n) { fail("%v is not an integer", n) }
+		if min, ok := schema["minimum"].(float64); ok && n < min { fail("%v is below %v", n, min) }
+		if max, ok := schema["maximum"].(float64); ok && n > max { fail("%v is above %v", n, max) }
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected a string, got %T", value)
+			break
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil { fail("%q is not a date-time", str) }
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok { fail("expected a boolean, got %T", value) }
+	}
+	return errs
+}
+
+// merge combines the object schemas of an allOf into one.
+func (s *spec) merge(parts []interface{}) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []interface{}
+	for _, p := range parts {
+		sub := s.resolve(obj(p))
+		for k, v := range obj(sub["properties"]) { props[k] = v }
+		required = append(required, asList(sub["required"])...)
+	}
+	return map[string]interface{}{"type": "object", "properties": props, "required": required}
+}
+
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// call sends a request, checks it and its response against the spec and
+// returns the decoded response body.
+func (s *spec) call(t *testing.T, base, method, path string, body interface{}, want int) interface{} {
+	t.Helper()
+	u, _ := url.Parse(path)
+	op, tmpl := s.operation(method, u.Path)
+	if tmpl == "" { t.Fatalf("%s is not in the spec", u.Path) }
+	if op == nil {
+		// methods the spec leaves out must be refused
+		if want != http.StatusMethodNotAllowed { t.Fatalf("%s %s is not in the spec", method, tmpl) }
+		op = map[string]interface{}{"responses": map[string]interface{}{"405": map[string]interface{}{"$ref": "#/components/responses/Error"}}}
+	} else {
+		s.hit[op["operationId"].(string)] = true
+	}
+	var r io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		// bad requests are sent on purpose; check the good ones
+		if rb := obj(op["requestBody"]); rb != nil && want < 400 {
+			var v interface{}
+			_ = json.Unmarshal(data, &v)
+			schema := obj(obj(obj(s.resolve(rb)["content"])["application/json"])["schema"])
+			if errs := s.validate(schema, v, "request"); len(errs) > 0 { t.Fatalf("%s %s request: %v", method, tmpl, errs) }
+		}
+		r = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, base+path, r)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { t.Fatalf("%s %s: %v", method, path, err) }
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != want { t.Fatalf("%s %s: expected %d, got %d: %s", method, path, want, resp.StatusCode, data) }
+	if want == http.StatusMethodNotAllowed {
+		if allow, documented := resp.Header.Get("Allow"), s.methods(tmpl); allow != documented {
+			t.Fatalf("%s %s: Allow is %q, the spec has %q", method, tmpl, allow, documented)
+		}
+	}
+	doc := s.resolve(obj(obj(op["responses"])[fmt.Sprint(resp.StatusCode)]))
+	if doc == nil { t.Fatalf("%s %s: status %d is not documented", method, tmpl, resp.StatusCode) }
+	for h := range obj(doc["headers"]) {
+		if resp.Header.Get(h) == "" { t.Fatalf("%s %s: %d is missing the %s header", method, tmpl, resp.StatusCode, h) }
+	}
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	media := obj(obj(doc["content"])[ct])
+	if media == nil { t.Fatalf("%s %s: %d has no %q body in the spec", method, tmpl, resp.StatusCode, ct) }
+	// pictures and other bodies that are not JSON come back as they are
+	if ct != "application/json" { return data }
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil { t.Fatalf("%s %s: %v", method, tmpl, err) }
+	if errs := s.validate(obj(media["schema"]), v, "response"); len(errs) > 0 {
+		t.Fatalf("%s %s %d does not match the spec:\n%s", method, tmpl, resp.StatusCode, strings.Join(errs, "\n"))
+	}
+	return v
+}
+
+func TestAPIMatchesOpenAPI(t *testing.T) {
+	s := loadSpec(t)
+	reg := game.NewRegistry()
+	ts := httptest.NewServer(BuildMux(reg, config.Default()))
+	defer ts.Close()
+	base := ts.URL
+
+	s.call(t, base, "GET", "/api/v1/openapi.json", nil, 200)
+	s.call(t, base, "GET", "/api/v1/games", nil, 405)
+	s.call(t, base, "POST", "/api/v1/games?layout=spiral", nil, 422)
+	s.call(t, base, "POST", "/api/v1/games?pawns=two", nil, 400)
+	s.call(t, base, "POST", "/api/v1/games?grid=101", nil, 422)
+	track := map[string]interface{}{
+		"width": 4, "height": 4, "layout": "row-major",
+		"snakes":  []map[string]int{{"from": 14, "to": 3}},
+		"ladders": []map[string]int{{"from": 2, "to": 9}},
+		"tiles":   []map[string]interface{}{{"square": 5, "effect": "shield"}, {"square": 7, "effect": "teleport", "target": 12}},
+	}
+	s.call(t, base, "POST", "/api/v1/games", track, 201)
+	id := obj(s.call(t, base, "POST", "/api/v1/games?grid=10&pawns=2&undo=vote&collision=capture&seed=4", nil, 201))["id"].(string)
+	g := "/api/v1/games/" + id
+	s.call(t, base, "GET", "/api/v1/games/nope", nil, 404)
+	s.call(t, base, "POST", "/api/v1/games/nope/roll", nil, 404)
+	s.call(t, base, "GET", g+"/roll", nil, 405)
+	s.call(t, base, "DELETE", g, nil, 405)
+	s.call(t, base, "POST", g+"/stream", nil, 405)
+
+	s.call(t, base, "POST", g+"/roll", nil, 409)
+	s.call(t, base, "POST", g+"/players", map[string]string{"name": "A"}, 201)
+	s.call(t, base, "POST", g+"/players", map[string]string{"name": "B"}, 201)
+	s.call(t, base, "POST", g+"/players", map[string]string{"name": "b"}, 409)
+	s.call(t, base, "POST", g+"/players", map[string]string{"name": " "}, 422)
+	s.call(t, base, "POST", g+"/players", "A", 400)
+	s.call(t, base, "POST", g+"/move", map[string]int{"pawn": 0}, 409)
+	for i := 0; i < 30; i++ {
+		res := obj(s.call(t, base, "POST", g+"/roll", nil, 200))
+		if choices := asList(obj(res["move"])["choices"]); len(choices) > 0 {
+			s.call(t, base, "POST", g+"/move", map[string]interface{}{"pawn": choices[len(choices)-1]}, 200)
+		}
+		if res["winner"] != nil { break }
+	}
+	s.call(t, base, "GET", g, nil, 200)
+	s.call(t, base, "GET", g+"/state", nil, 200)
+	s.call(t, base, "GET", g+"/events?since=2", nil, 200)
+	s.call(t, base, "GET", g+"/events?since=-1", nil, 400)
+
+	// follow the stream through an undo
+	op, _ := s.operation("GET", g+"/stream")
+	s.hit[op["operationId"].(string)] = true
+	events := obj(obj(obj(op["responses"])["200"])["x-sse-events"])
+	resp, err := http.Get(base + g + "/stream")
+	if err != nil { t.Fatalf("stream: %v", err) }
+	defer resp.Body.Close()
+	seen := make(chan string, 64)
+	go func() {
+		defer close(seen)
+		sc := bufio.NewScanner(resp.Body)
+		sc.Buffer(nil, 1<<20)
+		name := "message"
+		for sc.Scan() {
+			line := sc.Text()
+			if strings.HasPrefix(line, "event: ") { name = strings.TrimPrefix(line, "event: ") }
+			if !strings.HasPrefix(line, "data: ") { continue }
+			var v interface{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &v)
+			ev := obj(events[name])
+			if ev == nil {
+				seen <- name + ": not in x-sse-events"
+			} else if errs := s.validate(obj(ev["schema"]), v, name); len(errs) > 0 {
+				seen <- strings.Join(errs, "\n")
+			} else {
+				seen <- name
+			}
+			name = "message"
+		}
+	}()
+
+	s.call(t, base, "POST", g+"/undo", map[string]string{"player": "A"}, 202)
+	s.call(t, base, "POST", g+"/undo", map[string]string{"player": "B"}, 200)
+	s.call(t, base, "POST", g+"/undo", map[string]string{"player": "Z"}, 422)
+	svg := s.call(t, base, "GET", g+"/board.svg", nil, 200).([]byte)
+	if !bytes.HasPrefix(svg, []byte("<svg ")) { t.Fatalf("expected an SVG, got %.40q", svg) }
+	pic, err := png.DecodeConfig(bytes.NewReader(s.call(t, base, "GET", g+"/board.png?pawns=false&cell=20", nil, 200).([]byte)))
+	if err != nil || pic.Width != 200 || pic.Height != 200 { t.Fatalf("expected a 200x200 PNG, got %+v: %v", pic, err) }
+	s.call(t, base, "GET", g+"/board.png?cell=4", nil, 400)
+	s.call(t, base, "GET", g+"/board.svg?pawns=maybe", nil, 400)
+	s.call(t, base, "GET", "/api/v1/games/nope/board.svg", nil, 404)
+	// a board larger than any server would create is refused, not drawn
+	huge, err := game.NewFromDef(&game.BoardDef{Path: [][2]int{{0, 0}, {0, 1}, {3000, 3000}}})
+	if err != nil { t.Fatal(err) }
+	hugeID := reg.Add(huge)
+	s.call(t, base, "GET", "/api/v1/games/"+hugeID+"/board.svg", nil, 422)
+	s.call(t, base, "GET", "/api/v1/games/"+hugeID+"/board.png", nil, 422)
+	s.call(t, base, "POST", g+"/board.png", nil, 405)
+	snap := s.call(t, base, "GET", g+"/snapshot", nil, 200)
+	s.call(t, base, "POST", g+"/snapshot", nil, 405)
+	s.call(t, base, "POST", "/api/v1/games/import", snap, 201)
+	s.call(t, base, "POST", "/api/v1/games/import", map[string]interface{}{"version": 2, "board": map[string]int{}, "rules": map[string]int{}, "dice": map[string]int{"seed": 0, "draws": 0}, "history": []int{}, "players": []int{}, "turnIndex": 0, "lastRoll": 0, "moves": 0}, 422)
+	s.call(t, base, "POST", g+"/fork", nil, 201)
+
+	kinds := map[string]bool{}
+	timeout := time.After(2 * time.Second)
+	for !kinds["message"] || !kinds["rollback"] {
+		select {
+		case k, ok := <-seen:
+			if !ok { t.Fatal("stream ended early") }
+			if events[k] == nil { t.Fatalf("stream event does not match the spec: %s", k) }
+			kinds[k] = true
+		case <-timeout:
+			t.Fatalf("expected state and rollback events, saw %v", kinds)
+		}
+	}
+
+	// a server with tight limits
+	cfg := config.Default()
+	cfg.MaxBody, cfg.IPRate, cfg.IPBurst = 1024, 0.001, 3
+	limited := httptest.NewServer(BuildMux(game.NewRegistry(), cfg))
+	defer limited.Close()
+	big := map[string]string{"name": strings.Repeat("x", 2000)}
+	s.call(t, limited.URL, "POST", "/api/v1/games", map[string]interface{}{"width": 4, "height": 4, "path": make([]int, 1000)}, 413)
+	lid := obj(s.call(t, limited.URL, "POST", "/api/v1/games", nil, 201))["id"].(string)
+	s.call(t, limited.URL, "POST", "/api/v1/games/"+lid+"/players", big, 413)
+	s.call(t, limited.URL, "POST", "/api/v1/games/"+lid+"/roll", nil, 429)
+	s.call(t, limited.URL, "GET", "/api/v1/games/"+lid+"/board.png", nil, 429)
+	s.call(t, limited.URL, "POST", "/api/v1/games/"+lid+"/undo", map[string]string{"player": "x"}, 429)
+
+	// a server that is shutting down
+	stopping := newApp(game.NewRegistry(), config.Default(), slog.Default())
+	stopping.api.draining.Store(true)
+	sts := httptest.NewServer(stopping.handler)
+	defer sts.Close()
+	s.call(t, sts.URL, "POST", "/api/v1/games", nil, 503)
+
+	var missed []string
+	for _, item := range obj(s.doc["paths"]) {
+		for _, op := range obj(item) {
+			if id, ok := obj(op)["operationId"].(string); ok && !s.hit[id] { missed = append(missed, id) }
+		}
+	}
+	sort.Strings(missed)
+	if len(missed) > 0 { t.Fatalf("operations not exercised: %v", missed) }
+}
@@ -0,0 +1,47 @@
+package main
+
+import "sync"
+
+// maxPictures bounds how many drawn boards the server keeps.
+const maxPictures = 256
+
+// pictureCache keeps the boards drawn lately, so fetching a board that has
+// not changed since costs nothing.
+type pictureCache struct {
+	mu      sync.Mutex
+	entries map[pictureKey][]byte
+}
+
+// pictureKey names one drawing: the game at a revision and the options it
+// was drawn with.
+type pictureKey struct {
+	id    string
+	rev   uint64
+	ctype string
+	pawns bool
+	cell  int
+}
+
+func newPictureCache() *pictureCache { return &pictureCache{entries: map[pictureKey][]byte{}} }
+
+func (c *pictureCache) get(k pictureKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.entries[k]
+	return b, ok
+}
+
+// put keeps b as the drawing for k, dropping the game's drawings of older
+// revisions, and any drawing at all once the cache is full.
+func (c *pictureCache) put(k pictureKey, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for old := range c.entries {
+		if old.id == k.id && old.rev < k.rev { delete(c.entries, old) }
+	}
+	for old := range c.entries {
+		if len(c.entries) < maxPictures { break }
+		delete(c.entries, old)
+	}
+	c.entries[k] = b
+}
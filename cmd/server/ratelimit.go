@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// limiter is a set of token buckets, one per key: each key may spend burst
+// requests at once and earns rate more every second.
+type limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter, or nil when rate is 0. A nil limiter allows
+// everything.
+func newLimiter(rate float64, burst int) *limiter {
+	if rate <= 0 { return nil }
+	return &limiter{rate: rate, burst: float64(burst), buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// allow takes a token for key. When there is none it returns false and how
+// long until there will be.
+func (l *limiter) allow(key string) (bool, time.Duration) {
+	if l == nil { return true, 0 }
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops, about once a minute, the buckets that have filled up again,
+// so keys seen once do not pile up. Called with l.mu held.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute { return }
+	l.swept = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= full { delete(l.buckets, k) }
+	}
+}
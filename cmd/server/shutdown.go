@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// restartEvent is the last event of every stream when the server stops;
+// clients reconnect, to this server or its replacement, after it.
+const restartEvent = "restarting"
+
+// shutdown stops srv without cutting games short: the server stops being
+// ready and refuses new games, every event stream ends with a restartEvent,
+// and requests in flight, such as rolls, are finished. It gives up on them
+// when ctx is done.
+func (a *app) shutdown(ctx context.Context, srv *http.Server) error {
+	a.health.ready.Store(false)
+	a.api.draining.Store(true)
+	a.api.reg.CloseStreams(restartEvent, map[string]string{"message": "server restarting"})
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/web"
+)
+
+// asset is one file of the frontend, ready to serve.
+type asset struct {
+	body  []byte
+	gzip  []byte // nil when compressing does not pay
+	br    []byte // from a precompressed name.br, if any
+	hash  string
+	ctype string
+}
+
+// compressible lists the extensions worth compressing.
+var compressible = map[string]bool{".html": true, ".js": true, ".css": true, ".svg": true, ".json": true, ".txt": true}
+
+// builtIn is the frontend built into the server, loaded once.
+var builtIn = sync.OnceValues(func() (map[string]*asset, error) { return loadAssets(web.Files) })
+
+// loadAssets reads every file in fsys. References to the other files in
+// index.html get their hash appended (/app.js?v=<hash>), so browsers may keep
+// those for good and still pick up a new version.
+func loadAssets(fsys fs.FS) (map[string]*asset, error) {
+	assets := map[string]*asset{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(name, ".br") { return err }
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil { return err }
+		a := &asset{body: body, hash: contentHash(body), ctype: mime.TypeByExtension(path.Ext(name))}
+		if br, err := fs.ReadFile(fsys, name+".br"); err == nil { a.br = br }
+		assets[name] = a
+		return nil
+	})
+	if err != nil { return nil, err }
+	if index := assets["index.html"]; index != nil {
+		for name, a := range assets {
+			if name == "index.html" { continue }
+			ref := `"/` + name + `"`
+			index.body = bytes.ReplaceAll(index.body, []byte(ref), []byte(`"/`+name+`?v=`+a.hash+`"`))
+		}
+		index.hash = contentHash(index.body)
+		index.br = nil // made for the file before the references changed
+	}
+	for name, a := range assets {
+		if compressible[path.Ext(name)] { a.gzip = gzipped(a.body) }
+	}
+	return assets, nil
+}
+
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// gzipped returns b compressed, or nil when that does not make it smaller.
+func gzipped(b []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	_, _ = zw.Write(b)
+	_ = zw.Close()
+	if buf.Len() >= len(b) { return nil }
+	return buf.Bytes()
+}
+
+// spaHandler serves the web frontend: the built-in copy, or the files in
+// staticDir, read again on every request, when it is set. Paths that are not
+// files get index.html so the client can route them.
+//
+// index.html is revalidated on every load; the files it references are
+// cached for a year when asked for by hash, as index.html does. ETags are
+// content hashes. Clients accepting gzip get compressed text files, and
+// brotli when a precompressed name.br sits beside the file.
+func spaHandler(staticDir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assets, err := builtIn()
+		if staticDir != "" { assets, err = loadAssets(os.DirFS(staticDir)) }
+		if err != nil {
+			logFrom(r.Context()).Error("frontend not loaded", "err", err)
+			http.Error(w, "frontend unavailable", http.StatusInternalServerError)
+			return
+		}
+		p := path.Clean(r.URL.Path)
+		name := strings.TrimPrefix(p, "/")
+		a := assets[name]
+		if a == nil {
+			if strings.HasPrefix(p, "/assets/") || strings.HasSuffix(p, ".css") || strings.HasSuffix(p, ".js") || strings.HasSuffix(p, ".png") || strings.HasSuffix(p, ".svg") {
+				http.NotFound(w, r)
+				return
+			}
+			// fallback to index
+			name, a = "index.html", assets["index.html"]
+			if a == nil { http.NotFound(w, r); return }
+		}
+		h := w.Header()
+		switch {
+		case staticDir == "" && name != "index.html" && r.URL.Query().Get("v") == a.hash:
+			h.Set("Cache-Control", "public, max-age=31536000, immutable")
+		default:
+			h.Set("Cache-Control", "no-cache")
+		}
+		if a.ctype != "" { h.Set("Content-Type", a.ctype) }
+		body, tag := a.body, a.hash
+		if a.gzip != nil || a.br != nil { h.Add("Vary", "Accept-Encoding") }
+		switch {
+		case a.br != nil && accepts(r, "br"):
+			body, tag = a.br, a.hash+"-br"
+			h.Set("Content-Encoding", "br")
+		case a.gzip != nil && accepts(r, "gzip"):
+			body, tag = a.gzip, a.hash+"-gzip"
+			h.Set("Content-Encoding", "gzip")
+		}
+		h.Set("ETag", `"`+tag+`"`)
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+	})
+}
+
+// accepts reports whether r's Accept-Encoding allows coding.
+func accepts(r *http.Request, coding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) { continue }
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok { return true }
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
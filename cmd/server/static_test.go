@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/arsulegai/snakeandladder/web"
+)
+
+func get(h http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(header); i += 2 { r.Header.Set(header[i], header[i+1]) }
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestBuiltInFrontend(t *testing.T) {
+	h := spaHandler("")
+	index := get(h, "/")
+	if index.Code != http.StatusOK || index.Header().Get("Cache-Control") != "no-cache" { t.Fatalf("index: %d %q", index.Code, index.Header().Get("Cache-Control")) }
+	script := regexp.MustCompile(`src="(/app\.js\?v=[0-9a-f]+)"`).FindStringSubmatch(index.Body.String())
+	if script == nil { t.Fatalf("index.html does not load app.js by hash:\n%s", index.Body) }
+	if routed := get(h, "/games/42"); routed.Body.String() != index.Body.String() { t.Fatal("client routes do not get index.html") }
+
+	w := get(h, script[1], "Accept-Encoding", "br;q=1.0, gzip")
+	if w.Code != http.StatusOK { t.Fatalf("app.js: %d", w.Code) }
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") { t.Fatalf("app.js by hash cached with %q", cc) }
+	br, _ := web.Files.ReadFile("app.js.br")
+	if w.Header().Get("Content-Encoding") != "br" || w.Header().Get("Vary") != "Accept-Encoding" || !bytes.Equal(w.Body.Bytes(), br) { t.Fatalf("app.js not sent brotli-compressed: %v", w.Header()) }
+
+	w = get(h, script[1], "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" { t.Fatalf("app.js not gzipped: %v", w.Header()) }
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil { t.Fatal(err) }
+	got, _ := io.ReadAll(zr)
+	want, _ := web.Files.ReadFile("app.js")
+	if !bytes.Equal(got, want) { t.Fatal("gzipped app.js differs from the file") }
+
+	etag := w.Header().Get("ETag")
+	if w := get(h, script[1], "Accept-Encoding", "gzip", "If-None-Match", etag); w.Code != http.StatusNotModified { t.Fatalf("revalidating app.js: %d", w.Code) }
+	plain := get(h, "/app.js", "Accept-Encoding", "gzip;q=0")
+	if plain.Header().Get("Content-Encoding") != "" || !bytes.Equal(plain.Body.Bytes(), want) { t.Fatal("app.js compressed for a client refusing gzip") }
+	if plain.Header().Get("Cache-Control") != "no-cache" || plain.Header().Get("ETag") == etag { t.Fatalf("app.js without its hash: %v", plain.Header()) }
+	if w := get(h, "/missing.js"); w.Code != http.StatusNotFound { t.Fatalf("missing script: %d", w.Code) }
+}
+
+// TestBrotliCopies catches a script or style added without running go
+// generate in web.
+func TestBrotliCopies(t *testing.T) {
+	for _, pattern := range []string{"*.js", "*.css"} {
+		names, _ := fs.Glob(web.Files, pattern)
+		for _, name := range names {
+			if _, err := fs.Stat(web.Files, name+".br"); err != nil { t.Errorf("%s has no brotli copy: run go generate ./web", name) }
+		}
+	}
+}
+
+func TestStaticDirOverride(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil { t.Fatal(err) }
+	}
+	write("index.html", `<script src="/app.js"></script>`)
+	write("app.js", "one()")
+	write("app.js.br", "brotli bytes")
+	h := spaHandler(dir)
+
+	if w := get(h, "/app.js", "Accept-Encoding", "gzip, br"); w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "brotli bytes" { t.Fatalf("precompressed app.js not served: %v %q", w.Header(), w.Body) }
+	index := get(h, "/")
+	v := regexp.MustCompile(`\?v=[0-9a-f]+`).FindString(index.Body.String())
+	if v == "" { t.Fatalf("index.html: %q", index.Body) }
+	write("app.js", "two()")
+	if w := get(h, "/app.js"+v); w.Body.String() != "two()" || w.Header().Get("Cache-Control") != "no-cache" { t.Fatalf("edit not picked up: %q %q", w.Body, w.Header().Get("Cache-Control")) }
+	if next := get(h, "/"); next.Body.String() == index.Body.String() { t.Fatal("index.html still references the old app.js") }
+}
@@ -1,3 +1,3 @@
 module github.com/arsulegai/snakeandladder
 
-go 1.21
+go 1.22
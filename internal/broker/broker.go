@@ -0,0 +1,81 @@
+// Package broker connects the servers sharing games. It carries game events
+// to streams on every server and holds the locks, counters and game state
+// that let any server answer for any game. Local keeps all of it in the
+// process; Redis keeps it in a Redis server, or anything speaking its
+// protocol, reached by all of them.
+package broker
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Broker is what servers share games through.
+type Broker interface {
+	// Publish sends msg to every subscription to topic.
+	Publish(topic string, msg []byte) error
+	// Subscribe starts delivering the messages published to topic.
+	Subscribe(topic string) (*Subscription, error)
+	// Lock takes the lock named key for owner, for at most ttl. It returns
+	// false when the lock is held, by owner or anyone else.
+	Lock(key, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases key if owner holds it.
+	Unlock(key, owner string) error
+	// Get returns the value stored under key, or nil when there is none.
+	Get(key string) ([]byte, error)
+	// Set stores value under key, dropping it after ttl; 0 keeps it.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Incr adds one to the counter named key and returns the result.
+	Incr(key string) (int64, error)
+	// Close ends every subscription and releases the broker's connections.
+	Close() error
+}
+
+// Buffer is how many messages a subscription holds for a slow reader
+// before dropping new ones.
+const Buffer = 8
+
+// Subscription receives the messages of one topic on C. C is closed when the
+// subscription ends, through Close or because the broker went away.
+type Subscription struct {
+	C      <-chan []byte
+	cancel func()
+	once   sync.Once
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() { s.once.Do(s.cancel) }
+
+// dropped counts messages not delivered because a subscription was full.
+var dropped atomic.Uint64
+
+// Dropped returns how many messages were dropped, across all brokers,
+// because a subscriber could not keep up.
+func Dropped() uint64 { return dropped.Load() }
+
+// deliver hands msg to every channel in subs without waiting for slow ones.
+func deliver(subs map[chan []byte]struct{}, msg []byte) {
+	for ch := range subs {
+		select {
+		case ch <- msg:
+		default:
+			dropped.Add(1)
+		}
+	}
+}
+
+// Open connects to the broker at rawURL. The only scheme is redis, as in
+// redis://:password@host:6379/0.
+func Open(rawURL string) (Broker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil { return nil, err }
+	switch u.Scheme {
+	case "redis":
+		return DialRedis(u)
+	default:
+		return nil, fmt.Errorf("broker %q: unsupported scheme %q", rawURL, u.Scheme)
+	}
+}
@@ -0,0 +1,289 @@
+package broker
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a stand-in for a Redis server, speaking enough of the
+// protocol for the Redis broker.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	mu       sync.Mutex
+	values   map[string]entry
+	subs     map[string]map[*fakeClient]bool
+	conns    map[net.Conn]bool
+}
+
+type fakeClient struct {
+	mu sync.Mutex
+	c  net.Conn
+	w  *bufio.Writer
+}
+
+func (c *fakeClient) reply(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.w, format, args...)
+	_ = c.w.Flush()
+}
+
+func bulk(s string) string { return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n" }
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil { t.Fatal(err) }
+	f := &fakeRedis{ln: ln, password: password, values: map[string]entry{}, subs: map[string]map[*fakeClient]bool{}, conns: map[net.Conn]bool{}}
+	t.Cleanup(func() { ln.Close(); f.dropConns() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil { return }
+			f.mu.Lock()
+			f.conns[c] = true
+			f.mu.Unlock()
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) url() *url.URL { return &url.URL{Scheme: "redis", Host: f.ln.Addr().String()} }
+
+// dropConns cuts every client off, as a restarting server would.
+func (f *fakeRedis) dropConns() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.conns { c.Close() }
+}
+
+func (f *fakeRedis) serve(nc net.Conn) {
+	c := &fakeClient{c: nc, w: bufio.NewWriter(nc)}
+	in := &respConn{c: nc, r: bufio.NewReader(nc)}
+	authed := f.password == ""
+	defer func() {
+		nc.Close()
+		f.mu.Lock()
+		delete(f.conns, nc)
+		for _, subs := range f.subs { delete(subs, c) }
+		f.mu.Unlock()
+	}()
+	for {
+		v, err := in.read()
+		if err != nil { return }
+		var args []string
+		for _, a := range v.([]interface{}) { args = append(args, string(a.([]byte))) }
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			c.reply("-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		f.mu.Lock()
+		now := time.Now()
+		get := func(key string) (entry, bool) {
+			e, ok := f.values[key]
+			if ok && !e.expires.IsZero() && !now.Before(e.expires) {
+				delete(f.values, key)
+				return entry{}, false
+			}
+			return e, ok
+		}
+		switch cmd {
+		case "AUTH":
+			if args[len(args)-1] != f.password {
+				c.reply("-WRONGPASS invalid username-password pair\r\n")
+				break
+			}
+			authed = true
+			c.reply("+OK\r\n")
+		case "SELECT":
+			c.reply("+OK\r\n")
+		case "PING":
+			c.reply("+PONG\r\n")
+		case "GET":
+			if e, ok := get(args[1]); ok {
+				c.reply(bulk(string(e.value)))
+			} else {
+				c.reply("$-1\r\n")
+			}
+		case "SET":
+			e := entry{value: []byte(args[2])}
+			nx := false
+			for i := 3; i < len(args); i++ {
+				switch strings.ToUpper(args[i]) {
+				case "NX":
+					nx = true
+				case "PX":
+					ms, _ := strconv.Atoi(args[i+1])
+					e.expires = now.Add(time.Duration(ms) * time.Millisecond)
+					i++
+				}
+			}
+			if _, ok := get(args[1]); ok && nx {
+				c.reply("$-1\r\n")
+				break
+			}
+			f.values[args[1]] = e
+			c.reply("+OK\r\n")
+		case "INCR":
+			e, _ := get(args[1])
+			n, _ := strconv.ParseInt(string(e.value), 10, 64)
+			n++
+			f.values[args[1]] = entry{value: []byte(strconv.FormatInt(n, 10))}
+			c.reply(":%d\r\n", n)
+		case "EVAL":
+			if args[1] != unlockScript {
+				c.reply("-ERR unknown script\r\n")
+				break
+			}
+			if e, ok := get(args[3]); ok && string(e.value) == args[4] {
+				delete(f.values, args[3])
+				c.reply(":1\r\n")
+			} else {
+				c.reply(":0\r\n")
+			}
+		case "PUBLISH":
+			for s := range f.subs[args[1]] { s.reply("*3\r\n%s%s%s", bulk("message"), bulk(args[1]), bulk(args[2])) }
+			c.reply(":%d\r\n", len(f.subs[args[1]]))
+		case "SUBSCRIBE":
+			if f.subs[args[1]] == nil { f.subs[args[1]] = map[*fakeClient]bool{} }
+			f.subs[args[1]][c] = true
+			c.reply("*3\r\n%s%s:1\r\n", bulk("subscribe"), bulk(args[1]))
+		case "UNSUBSCRIBE":
+			delete(f.subs[args[1]], c)
+			c.reply("*3\r\n%s%s:0\r\n", bulk("unsubscribe"), bulk(args[1]))
+		default:
+			c.reply("-ERR unknown command '%s'\r\n", args[0])
+		}
+		f.mu.Unlock()
+	}
+}
+
+func brokers(t *testing.T) map[string]Broker {
+	r, err := DialRedis(startFakeRedis(t, "").url())
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { r.Close() })
+	return map[string]Broker{"local": NewLocal(), "redis": r}
+}
+
+func receive(t *testing.T, s *Subscription) string {
+	t.Helper()
+	select {
+	case m, ok := <-s.C:
+		if !ok { t.Fatal("subscription ended") }
+		return string(m)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message")
+	}
+	return ""
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	for name, b := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			a, _ := b.Subscribe("game:1")
+			c, _ := b.Subscribe("game:1")
+			other, _ := b.Subscribe("game:2")
+			if err := b.Publish("game:1", []byte("rolled\n6")); err != nil { t.Fatal(err) }
+			if m := receive(t, a); m != "rolled\n6" { t.Fatalf("got %q", m) }
+			if m := receive(t, c); m != "rolled\n6" { t.Fatalf("got %q", m) }
+			a.Close()
+			a.Close()
+			if _, ok := <-a.C; ok { t.Fatal("closed subscription still open") }
+			_ = b.Publish("game:2", []byte("joined"))
+			if m := receive(t, other); m != "joined" { t.Fatalf("got %q", m) }
+			if len(c.C) != 0 { t.Fatalf("message for another topic: %q", <-c.C) }
+			c.Close()
+			other.Close()
+		})
+	}
+}
+
+func TestSlowSubscribersDrop(t *testing.T) {
+	for name, b := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			s, _ := b.Subscribe("busy")
+			defer s.Close()
+			before := Dropped()
+			for i := 0; i < Buffer+3; i++ { _ = b.Publish("busy", []byte(strconv.Itoa(i))) }
+			// wait for the last message to be handled
+			deadline := time.Now().Add(2 * time.Second)
+			for Dropped()-before < 3 && time.Now().Before(deadline) { time.Sleep(time.Millisecond) }
+			if d := Dropped() - before; d != 3 { t.Fatalf("dropped %d messages, want 3", d) }
+			if m := receive(t, s); m != "0" { t.Fatalf("expected the oldest message first, got %q", m) }
+		})
+	}
+}
+
+func TestLocks(t *testing.T) {
+	for name, b := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			if ok, err := b.Lock("game:1:lock", "a", time.Minute); !ok || err != nil { t.Fatalf("lock: %v %v", ok, err) }
+			if ok, _ := b.Lock("game:1:lock", "b", time.Minute); ok { t.Fatal("lock taken twice") }
+			if ok, _ := b.Lock("game:1:lock", "a", time.Minute); ok { t.Fatal("lock taken twice by its owner") }
+			_ = b.Unlock("game:1:lock", "b")
+			if ok, _ := b.Lock("game:1:lock", "b", time.Minute); ok { t.Fatal("unlocked by someone else") }
+			if err := b.Unlock("game:1:lock", "a"); err != nil { t.Fatal(err) }
+			if ok, _ := b.Lock("game:1:lock", "b", 30*time.Millisecond); !ok { t.Fatal("lock not released") }
+			time.Sleep(60 * time.Millisecond)
+			if ok, _ := b.Lock("game:1:lock", "a", time.Minute); !ok { t.Fatal("lock did not expire") }
+		})
+	}
+}
+
+func TestValues(t *testing.T) {
+	for name, b := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			if v, err := b.Get("missing"); v != nil || err != nil { t.Fatalf("missing key: %q %v", v, err) }
+			if err := b.Set("game:1", []byte(`{"rev":1}`), 0); err != nil { t.Fatal(err) }
+			if v, _ := b.Get("game:1"); string(v) != `{"rev":1}` { t.Fatalf("got %q", v) }
+			_ = b.Set("short", []byte("x"), 30*time.Millisecond)
+			time.Sleep(60 * time.Millisecond)
+			if v, _ := b.Get("short"); v != nil { t.Fatalf("value did not expire: %q", v) }
+			for want := int64(1); want <= 3; want++ {
+				if n, err := b.Incr("games"); n != want || err != nil { t.Fatalf("incr: %d %v, want %d", n, err, want) }
+			}
+		})
+	}
+}
+
+func TestRedisReconnects(t *testing.T) {
+	f := startFakeRedis(t, "")
+	r, err := DialRedis(f.url())
+	if err != nil { t.Fatal(err) }
+	defer r.Close()
+	s, _ := r.Subscribe("game:1")
+	f.dropConns()
+	select {
+	case _, ok := <-s.C:
+		if ok { t.Fatal("unexpected message") }
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription outlived its connection")
+	}
+	// the first command may find its connection gone
+	_ = r.Set("k", []byte("v"), 0)
+	if err := r.Set("k", []byte("v"), 0); err != nil { t.Fatalf("no reconnect: %v", err) }
+	s, err = r.Subscribe("game:1")
+	if err != nil { t.Fatalf("subscribe again: %v", err) }
+	defer s.Close()
+	_ = r.Publish("game:1", []byte("back"))
+	if m := receive(t, s); m != "back" { t.Fatalf("got %q", m) }
+}
+
+func TestOpenRedisWithPassword(t *testing.T) {
+	f := startFakeRedis(t, "s3cret")
+	addr := f.ln.Addr().String()
+	b, err := Open("redis://:s3cret@" + addr + "/2")
+	if err != nil { t.Fatal(err) }
+	b.Close()
+	if _, err := Open("redis://:wrong@" + addr); err == nil || !strings.Contains(err.Error(), "WRONGPASS") { t.Fatalf("expected an auth error, got %v", err) }
+	if _, err := Open("nats://" + addr); err == nil { t.Fatal("expected an error for an unknown scheme") }
+}
@@ -0,0 +1,111 @@
+package broker
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Local is a broker for the servers of one process.
+type Local struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan []byte]struct{}
+	values map[string]entry
+	now    func() time.Time
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time // zero for values kept forever
+}
+
+// NewLocal returns an empty Local broker.
+func NewLocal() *Local {
+	return &Local{subs: map[string]map[chan []byte]struct{}{}, values: map[string]entry{}, now: time.Now}
+}
+
+func (l *Local) Publish(topic string, msg []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	deliver(l.subs[topic], msg)
+	return nil
+}
+
+func (l *Local) Subscribe(topic string) (*Subscription, error) {
+	ch := make(chan []byte, Buffer)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.subs[topic] == nil { l.subs[topic] = map[chan []byte]struct{}{} }
+	l.subs[topic][ch] = struct{}{}
+	return &Subscription{C: ch, cancel: func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[topic][ch]; !ok { return } // closed with the broker
+		delete(l.subs[topic], ch)
+		if len(l.subs[topic]) == 0 { delete(l.subs, topic) }
+		close(ch)
+	}}, nil
+}
+
+func (l *Local) Lock(key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.get(key); ok { return false, nil }
+	l.values[key] = entry{[]byte(owner), l.now().Add(ttl)}
+	return true, nil
+}
+
+func (l *Local) Unlock(key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.get(key); ok && string(v) == owner { delete(l.values, key) }
+	return nil
+}
+
+func (l *Local) Get(key string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	v, _ := l.get(key)
+	return v, nil
+}
+
+func (l *Local) Set(key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := entry{value: append([]byte(nil), value...)}
+	if ttl > 0 { e.expires = l.now().Add(ttl) }
+	l.values[key] = e
+	return nil
+}
+
+func (l *Local) Incr(key string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	v, _ := l.get(key)
+	n, _ := strconv.ParseInt(string(v), 10, 64)
+	n++
+	l.values[key] = entry{value: []byte(strconv.FormatInt(n, 10))}
+	return n, nil
+}
+
+func (l *Local) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, subs := range l.subs {
+		for ch := range subs { close(ch) }
+	}
+	l.subs = map[string]map[chan []byte]struct{}{}
+	return nil
+}
+
+// get returns the value of key unless it expired, in which case it is
+// dropped. Called with l.mu held.
+func (l *Local) get(key string) ([]byte, bool) {
+	e, ok := l.values[key]
+	if !ok { return nil, false }
+	if !e.expires.IsZero() && !l.now().Before(e.expires) {
+		delete(l.values, key)
+		return nil, false
+	}
+	return e.value, true
+}
@@ -0,0 +1,333 @@
+package broker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dialTimeout    = 5 * time.Second
+	commandTimeout = 5 * time.Second
+	// maxBulk bounds the size of a value read from the server.
+	maxBulk = 64 << 20
+)
+
+// unlockScript deletes a lock only when it still belongs to its owner.
+const unlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`
+
+var errClosed = errors.New("redis: broker closed")
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// Redis is a broker kept in a Redis server. Commands share one connection
+// and subscriptions another, opened with the first of them. A connection
+// that fails is dropped: the next command dials again, while the
+// subscriptions it carried end and have to be made again.
+type Redis struct {
+	addr, user, password string
+	db                   int
+
+	mu     sync.Mutex // guards conn and closed
+	conn   *respConn
+	closed bool
+
+	smu  sync.Mutex // guards sub and subs
+	sub  *respConn
+	subs map[string]*topic
+}
+
+// topic is the local side of a Redis subscription: the channels it feeds,
+// and ready, closed once the server confirmed it.
+type topic struct {
+	chans map[chan []byte]struct{}
+	ready chan struct{}
+}
+
+func (t *topic) confirm() {
+	select {
+	case <-t.ready:
+	default:
+		close(t.ready)
+	}
+}
+
+// DialRedis connects to the Redis server at u, such as
+// redis://:password@host:6379/0, and checks that it answers.
+func DialRedis(u *url.URL) (*Redis, error) {
+	r := &Redis{addr: u.Host, subs: map[string]*topic{}}
+	if r.addr == "" { return nil, fmt.Errorf("redis: no address in %q", u.Redacted()) }
+	if _, _, err := net.SplitHostPort(r.addr); err != nil { r.addr = net.JoinHostPort(r.addr, "6379") }
+	if u.User != nil {
+		r.user = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 { return nil, fmt.Errorf("redis: invalid database %q", db) }
+		r.db = n
+	}
+	if _, err := r.do("PING"); err != nil { return nil, err }
+	return r, nil
+}
+
+func (r *Redis) Publish(topic string, msg []byte) error {
+	_, err := r.do("PUBLISH", topic, string(msg))
+	return err
+}
+
+// Subscribe returns once the server confirmed the subscription, so nothing
+// published afterwards is missed.
+func (r *Redis) Subscribe(name string) (*Subscription, error) {
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+	if closed { return nil, errClosed }
+	r.smu.Lock()
+	if r.sub == nil {
+		c, err := r.dial()
+		if err != nil {
+			r.smu.Unlock()
+			return nil, err
+		}
+		r.sub = c
+		go r.listen(c)
+	}
+	t := r.subs[name]
+	if t == nil {
+		if err := r.sub.send("SUBSCRIBE", name); err != nil {
+			r.endSubs()
+			r.smu.Unlock()
+			return nil, err
+		}
+		t = &topic{chans: map[chan []byte]struct{}{}, ready: make(chan struct{})}
+		r.subs[name] = t
+	}
+	ch := make(chan []byte, Buffer)
+	t.chans[ch] = struct{}{}
+	r.smu.Unlock()
+	sub := &Subscription{C: ch, cancel: func() { r.unsubscribe(name, ch) }}
+	select {
+	case <-t.ready:
+		return sub, nil
+	case <-time.After(commandTimeout):
+		sub.Close()
+		return nil, fmt.Errorf("redis: SUBSCRIBE %s: no confirmation", name)
+	}
+}
+
+func (r *Redis) unsubscribe(name string, ch chan []byte) {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+	t := r.subs[name]
+	if t == nil { return } // ended with the connection
+	if _, ok := t.chans[ch]; !ok { return }
+	delete(t.chans, ch)
+	close(ch)
+	if len(t.chans) > 0 { return }
+	delete(r.subs, name)
+	if err := r.sub.send("UNSUBSCRIBE", name); err != nil { r.endSubs() }
+}
+
+// listen delivers the messages arriving on c until it fails.
+func (r *Redis) listen(c *respConn) {
+	for {
+		v, err := c.read()
+		if err != nil {
+			r.smu.Lock()
+			if r.sub == c { r.endSubs() }
+			r.smu.Unlock()
+			return
+		}
+		// ["message", topic, payload] or ["subscribe", topic, count]
+		m, ok := v.([]interface{})
+		if !ok || len(m) != 3 { continue }
+		kind, _ := m[0].([]byte)
+		name, _ := m[1].([]byte)
+		r.smu.Lock()
+		if t := r.subs[string(name)]; t != nil {
+			switch string(kind) {
+			case "message":
+				msg, _ := m[2].([]byte)
+				deliver(t.chans, msg)
+			case "subscribe":
+				t.confirm()
+			}
+		}
+		r.smu.Unlock()
+	}
+}
+
+// endSubs closes the subscription connection and ends every subscription.
+// Called with r.smu held.
+func (r *Redis) endSubs() {
+	if r.sub != nil { _ = r.sub.c.Close() }
+	r.sub = nil
+	for _, t := range r.subs {
+		for ch := range t.chans { close(ch) }
+		t.confirm() // nobody waits for a subscription that ended
+	}
+	r.subs = map[string]*topic{}
+}
+
+func (r *Redis) Lock(key, owner string, ttl time.Duration) (bool, error) {
+	v, err := r.do("SET", key, owner, "NX", "PX", millis(ttl))
+	return v != nil, err
+}
+
+func (r *Redis) Unlock(key, owner string) error {
+	_, err := r.do("EVAL", unlockScript, "1", key, owner)
+	return err
+}
+
+func (r *Redis) Get(key string) ([]byte, error) {
+	v, err := r.do("GET", key)
+	if err != nil || v == nil { return nil, err }
+	b, ok := v.([]byte)
+	if !ok { return nil, fmt.Errorf("redis: GET %s: unexpected reply %v", key, v) }
+	return b, nil
+}
+
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 { args = append(args, "PX", millis(ttl)) }
+	_, err := r.do(args...)
+	return err
+}
+
+func (r *Redis) Incr(key string) (int64, error) {
+	v, err := r.do("INCR", key)
+	if err != nil { return 0, err }
+	n, ok := v.(int64)
+	if !ok { return 0, fmt.Errorf("redis: INCR %s: unexpected reply %v", key, v) }
+	return n, nil
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	if r.conn != nil { _ = r.conn.c.Close() }
+	r.conn = nil
+	r.mu.Unlock()
+	r.smu.Lock()
+	r.endSubs()
+	r.smu.Unlock()
+	return nil
+}
+
+// do runs one command on the command connection, dialing it when needed.
+func (r *Redis) do(args ...string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed { return nil, errClosed }
+	if r.conn == nil {
+		c, err := r.dial()
+		if err != nil { return nil, err }
+		r.conn = c
+	}
+	_ = r.conn.c.SetDeadline(time.Now().Add(commandTimeout))
+	v, err := r.conn.call(args...)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		_ = r.conn.c.Close()
+		r.conn = nil
+	}
+	return v, err
+}
+
+// dial opens a connection, logged in and on the configured database.
+func (r *Redis) dial() (*respConn, error) {
+	nc, err := net.DialTimeout("tcp", r.addr, dialTimeout)
+	if err != nil { return nil, err }
+	c := &respConn{c: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	_ = nc.SetDeadline(time.Now().Add(commandTimeout))
+	var setup [][]string
+	switch {
+	case r.user != "" && r.password != "":
+		setup = append(setup, []string{"AUTH", r.user, r.password})
+	case r.password != "":
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 { setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)}) }
+	for _, cmd := range setup {
+		if _, err := c.call(cmd...); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	_ = nc.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func millis(d time.Duration) string {
+	ms := d.Milliseconds()
+	if ms < 1 { ms = 1 }
+	return strconv.FormatInt(ms, 10)
+}
+
+// respConn speaks RESP, the Redis serialization protocol, over one
+// connection.
+type respConn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (c *respConn) call(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil { return nil, err }
+	return c.read()
+}
+
+// send writes a command as an array of bulk strings.
+func (c *respConn) send(args ...string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args { fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a) }
+	return c.w.Flush()
+}
+
+// read reads one reply: a string for simple strings, int64 for integers,
+// []byte for bulk strings, []interface{} for arrays and nil for null.
+// Error replies are returned as a redisError.
+func (c *respConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil { return nil, err }
+	if len(line) < 3 || line[len(line)-2] != '\r' { return nil, fmt.Errorf("redis: malformed reply %q", line) }
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil { return nil, fmt.Errorf("redis: malformed integer %q", body) }
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxBulk { return nil, fmt.Errorf("redis: malformed bulk length %q", body) }
+		if n < 0 { return nil, nil }
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil { return nil, err }
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxBulk { return nil, fmt.Errorf("redis: malformed array length %q", body) }
+		if n < 0 { return nil, nil }
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil { return nil, err }
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,350 @@
+// Package config holds the server settings. They come from, in increasing
+// order of precedence: the defaults, an optional YAML or TOML file, SNL_*
+// environment variables and command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxGridLimit caps MaxGrid, so a configuration cannot allow boards too big
+// to hold in memory.
+const MaxGridLimit = 1000
+
+// Log formats.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// EnvPrefix starts the name of every environment variable read by Load.
+const EnvPrefix = "SNL_"
+
+// Config is the server configuration. Zero limits and TTLs mean no limit.
+type Config struct {
+	// Addr is the address to listen on. TLS is served when both TLSCert
+	// and TLSKey are set.
+	Addr    string
+	TLSCert string
+	TLSKey  string
+	// StaticDir, when set, holds the web frontend to serve instead of the
+	// copy built into the server.
+	StaticDir string
+	// DefaultGrid is the side of boards created without a size; boards
+	// wider or taller than MaxGrid are refused.
+	DefaultGrid int
+	MaxGrid     int
+	// MaxPlayers is the most players in one game, MaxGames the most games
+	// held at once.
+	MaxPlayers int
+	MaxGames   int
+	// GameTTL removes games left unchanged that long, FinishedTTL finished
+	// games left unchanged that long.
+	GameTTL     time.Duration
+	FinishedTTL time.Duration
+	// MaxBody is the largest request body in bytes and MaxName the longest
+	// player or team name in characters.
+	MaxBody int
+	MaxName int
+	// IPRate and GameRate are the requests per second each client address
+	// and each game may make to create, join, roll and move, with bursts of
+	// IPBurst and GameBurst. A zero rate turns that limit off.
+	IPRate    float64
+	IPBurst   int
+	GameRate  float64
+	GameBurst int
+	// Timeouts of the HTTP server. WriteTimeout does not apply to event
+	// streams.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout bounds how long the server waits for requests in
+	// flight when told to stop.
+	ShutdownTimeout time.Duration
+	// Broker is the URL of the Redis server games are shared through with
+	// other servers, such as redis://host:6379; empty keeps them in this
+	// server.
+	Broker string
+	// CORSOrigins lists the origins browsers may call the API from; "*"
+	// allows any.
+	CORSOrigins []string
+	// LogLevel is the least important level logged and LogFormat either
+	// LogText or LogJSON.
+	LogLevel  slog.Level
+	LogFormat string
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() Config {
+	return Config{
+		Addr:              ":8080",
+		DefaultGrid:       10,
+		MaxGrid:           100,
+		MaxPlayers:        8,
+		MaxGames:          1000,
+		GameTTL:           24 * time.Hour,
+		FinishedTTL:       time.Hour,
+		MaxBody:           1 << 20,
+		MaxName:           32,
+		IPRate:            20,
+		IPBurst:           100,
+		GameRate:          20,
+		GameBurst:         100,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   20 * time.Second,
+		CORSOrigins:       []string{"*"},
+		LogLevel:          slog.LevelInfo,
+		LogFormat:         LogText,
+	}
+}
+
+// TLS reports whether the server should serve HTTPS.
+func (c Config) TLS() bool { return c.TLSCert != "" && c.TLSKey != "" }
+
+// setting is one configuration value. name is its key in files; flags use it
+// with dashes and the environment upper-cased after EnvPrefix.
+type setting struct {
+	name  string
+	usage string
+	get   func(c *Config) string
+	set   func(c *Config, v string) error
+}
+
+func (s setting) flag() string { return strings.ReplaceAll(s.name, "_", "-") }
+func (s setting) env() string  { return EnvPrefix + strings.ToUpper(s.name) }
+
+func stringSetting(name, usage string, field func(c *Config) *string) setting {
+	return setting{name, usage,
+		func(c *Config) string { return *field(c) },
+		func(c *Config, v string) error { *field(c) = v; return nil },
+	}
+}
+
+func intSetting(name, usage string, field func(c *Config) *int) setting {
+	return setting{name, usage,
+		func(c *Config) string { return strconv.Itoa(*field(c)) },
+		func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil { return fmt.Errorf("%s: %q is not a number", name, v) }
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func durationSetting(name, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{name, usage,
+		func(c *Config) string { return field(c).String() },
+		func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil { return fmt.Errorf("%s: %q is not a duration such as 30m or 24h", name, v) }
+			*field(c) = d
+			return nil
+		},
+	}
+}
+
+func floatSetting(name, usage string, field func(c *Config) *float64) setting {
+	return setting{name, usage,
+		func(c *Config) string { return strconv.FormatFloat(*field(c), 'g', -1, 64) },
+		func(c *Config, v string) error {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil { return fmt.Errorf("%s: %q is not a number", name, v) }
+			*field(c) = f
+			return nil
+		},
+	}
+}
+
+// settings lists every setting in the order --print-config writes them.
+var settings = []setting{
+	stringSetting("addr", "address to listen on", func(c *Config) *string { return &c.Addr }),
+	stringSetting("tls_cert", "TLS certificate file; serves HTTPS together with tls-key", func(c *Config) *string { return &c.TLSCert }),
+	stringSetting("tls_key", "TLS private key file", func(c *Config) *string { return &c.TLSKey }),
+	stringSetting("static_dir", "serve the web frontend from this directory instead of the built-in copy, for front-end development", func(c *Config) *string { return &c.StaticDir }),
+	intSetting("default_grid", "side of boards created without a size", func(c *Config) *int { return &c.DefaultGrid }),
+	intSetting("max_grid", "largest board width or height allowed", func(c *Config) *int { return &c.MaxGrid }),
+	intSetting("max_players", "most players in a game, 0 for no limit", func(c *Config) *int { return &c.MaxPlayers }),
+	intSetting("max_games", "most games held at once, 0 for no limit", func(c *Config) *int { return &c.MaxGames }),
+	durationSetting("game_ttl", "remove games unchanged for this long, 0 to keep them", func(c *Config) *time.Duration { return &c.GameTTL }),
+	durationSetting("finished_ttl", "remove finished games unchanged for this long, 0 to keep them", func(c *Config) *time.Duration { return &c.FinishedTTL }),
+	intSetting("max_body", "largest request body in bytes", func(c *Config) *int { return &c.MaxBody }),
+	intSetting("max_name", "longest player or team name in characters", func(c *Config) *int { return &c.MaxName }),
+	floatSetting("ip_rate", "creates, joins, rolls and moves per second from one address, 0 for no limit", func(c *Config) *float64 { return &c.IPRate }),
+	intSetting("ip_burst", "requests one address may make at once before ip_rate applies", func(c *Config) *int { return &c.IPBurst }),
+	floatSetting("game_rate", "joins, rolls and moves per second in one game, 0 for no limit", func(c *Config) *float64 { return &c.GameRate }),
+	intSetting("game_burst", "requests one game may take at once before game_rate applies", func(c *Config) *int { return &c.GameBurst }),
+	durationSetting("read_header_timeout", "time allowed to read request headers", func(c *Config) *time.Duration { return &c.ReadHeaderTimeout }),
+	durationSetting("read_timeout", "time allowed to read a whole request", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationSetting("write_timeout", "time allowed to write a response, except event streams", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationSetting("idle_timeout", "time an idle keep-alive connection stays open", func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationSetting("shutdown_timeout", "time allowed to finish requests when stopping", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	stringSetting("broker", "redis:// URL of the server games are shared through with other servers; empty keeps them in this one", func(c *Config) *string { return &c.Broker }),
+	{"cors_origins", "comma separated origins allowed to call the API, * for any",
+		func(c *Config) string { return strings.Join(c.CORSOrigins, ",") },
+		func(c *Config, v string) error { c.CORSOrigins = splitList(v); return nil },
+	},
+	{"log_level", "debug, info, warn or error",
+		func(c *Config) string { return strings.ToLower(c.LogLevel.String()) },
+		func(c *Config, v string) error {
+			if err := c.LogLevel.UnmarshalText([]byte(v)); err != nil { return fmt.Errorf("log_level: %q is not debug, info, warn or error", v) }
+			return nil
+		},
+	},
+	stringSetting("log_format", "text or json", func(c *Config) *string { return &c.LogFormat }),
+}
+
+func lookup(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name { return s, true }
+	}
+	return setting{}, false
+}
+
+func splitList(v string) []string {
+	var l []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" { l = append(l, item) }
+	}
+	return l
+}
+
+// flagValue records a flag so Load can apply it after the file and the
+// environment.
+type flagValue struct {
+	s   setting
+	set *[][2]string
+}
+
+func (f flagValue) String() string {
+	if f.s.get == nil { return "" }
+	c := Default()
+	return f.s.get(&c)
+}
+
+func (f flagValue) Set(v string) error {
+	c := Default()
+	if err := f.s.set(&c, v); err != nil { return err }
+	*f.set = append(*f.set, [2]string{f.s.name, v})
+	return nil
+}
+
+// Load builds the configuration from the command line arguments (without the
+// program name) and the environment. --config, or SNL_CONFIG, names the file
+// to read. print reports whether --print-config was given. Load returns
+// flag.ErrHelp when help was asked for.
+func Load(args []string, getenv func(string) string) (c Config, print bool, err error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", "", "YAML (.yaml, .yml) or TOML (.toml) file to read settings from")
+	fs.BoolVar(&print, "print-config", false, "print the configuration in effect and exit")
+	var set [][2]string
+	for _, s := range settings {
+		fs.Var(flagValue{s, &set}, s.flag(), s.usage+" (env "+s.env()+")")
+	}
+	if err := fs.Parse(args); err != nil { return c, false, err }
+	if fs.NArg() > 0 { return c, false, fmt.Errorf("unexpected argument %q", fs.Arg(0)) }
+
+	c = Default()
+	if *path == "" { *path = getenv(EnvPrefix + "CONFIG") }
+	if *path != "" {
+		data, err := os.ReadFile(*path)
+		if err != nil { return c, print, err }
+		values, err := parseFile(*path, data)
+		if err != nil { return c, print, err }
+		for _, kv := range values {
+			s, _ := lookup(kv[0])
+			if err := s.set(&c, kv[1]); err != nil { return c, print, fmt.Errorf("%s: %v", *path, err) }
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env()); v != "" {
+			if err := s.set(&c, v); err != nil { return c, print, fmt.Errorf("%s: %v", s.env(), err) }
+		}
+	}
+	for _, kv := range set {
+		s, _ := lookup(kv[0])
+		if err := s.set(&c, kv[1]); err != nil { return c, print, err }
+	}
+	return c, print, c.Validate()
+}
+
+// Validate reports every problem with c.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) { errs = append(errs, fmt.Errorf(format, args...)) }
+	if c.Addr == "" { fail("addr is required") }
+	if (c.TLSCert == "") != (c.TLSKey == "") { fail("tls_cert and tls_key must be set together") }
+	if c.DefaultGrid < 2 { fail("default_grid %d is below 2", c.DefaultGrid) }
+	if c.MaxGrid < c.DefaultGrid { fail("max_grid %d is below default_grid %d", c.MaxGrid, c.DefaultGrid) }
+	if c.MaxGrid > MaxGridLimit { fail("max_grid %d is above %d", c.MaxGrid, MaxGridLimit) }
+	if c.MaxPlayers < 0 || c.MaxPlayers == 1 { fail("max_players %d must be 0 or at least 2", c.MaxPlayers) }
+	if c.MaxGames < 0 { fail("max_games %d is negative", c.MaxGames) }
+	if c.GameTTL < 0 { fail("game_ttl %v is negative", c.GameTTL) }
+	if c.FinishedTTL < 0 { fail("finished_ttl %v is negative", c.FinishedTTL) }
+	if c.MaxBody < 1024 { fail("max_body %d is below 1024 bytes", c.MaxBody) }
+	if c.MaxName < 1 { fail("max_name %d is below 1", c.MaxName) }
+	for _, r := range []struct {
+		name  string
+		rate  float64
+		burst int
+	}{{"ip", c.IPRate, c.IPBurst}, {"game", c.GameRate, c.GameBurst}} {
+		if r.rate < 0 { fail("%s_rate %v is negative", r.name, r.rate) }
+		if r.rate > 0 && r.burst < 1 { fail("%s_burst %d is below 1", r.name, r.burst) }
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{{"read_header_timeout", c.ReadHeaderTimeout}, {"read_timeout", c.ReadTimeout}, {"write_timeout", c.WriteTimeout}, {"idle_timeout", c.IdleTimeout}, {"shutdown_timeout", c.ShutdownTimeout}} {
+		if t.d < 0 { fail("%s %v is negative", t.name, t.d) }
+	}
+	if c.LogFormat != LogText && c.LogFormat != LogJSON { fail("log_format %q is not text or json", c.LogFormat) }
+	if c.Broker != "" {
+		if u, err := url.Parse(c.Broker); err != nil || u.Scheme != "redis" || u.Host == "" {
+			fail("broker: %q is not a URL such as redis://localhost:6379", c.Broker)
+		}
+	}
+	for _, o := range c.CORSOrigins {
+		if o == "*" {
+			if len(c.CORSOrigins) > 1 { fail("cors_origins cannot mix * with other origins") }
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			fail("cors_origins: %q is not an origin such as https://example.com", o)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Write prints c as a YAML file that Load reads back to the same settings.
+func Write(w io.Writer, c Config) error {
+	for _, s := range settings {
+		v := s.get(&c)
+		var line string
+		switch s.name {
+		case "cors_origins":
+			items := make([]string, len(c.CORSOrigins))
+			for i, o := range c.CORSOrigins { items[i] = strconv.Quote(o) }
+			line = "[" + strings.Join(items, ", ") + "]"
+		case "default_grid", "max_grid", "max_players", "max_games", "max_body", "max_name", "ip_rate", "ip_burst", "game_rate", "game_burst":
+			line = v
+		default:
+			line = strconv.Quote(v)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", s.name, line); err != nil { return err }
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil { t.Fatal(err) }
+	return path
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	c, print, err := Load(nil, env(nil))
+	if err != nil || print { t.Fatalf("load defaults: %v, print %v", err, print) }
+	if !reflect.DeepEqual(c, Default()) { t.Fatalf("expected defaults, got %+v", c) }
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeFile(t, "server.yaml", `
+# file sets everything it mentions
+addr: ":9000"
+max_grid: 50
+max_players: 4
+cors_origins:
+  - https://a.example
+  - "https://b.example"  # second
+`)
+	c, _, err := Load([]string{"--config", path, "--max-players", "6"}, env(map[string]string{"SNL_MAX_GRID": "60", "SNL_MAX_PLAYERS": "5"}))
+	if err != nil { t.Fatalf("load: %v", err) }
+	if c.Addr != ":9000" || c.MaxGrid != 60 || c.MaxPlayers != 6 || c.DefaultGrid != 10 {
+		t.Fatalf("expected file < env < flags over defaults, got %+v", c)
+	}
+	if !reflect.DeepEqual(c.CORSOrigins, []string{"https://a.example", "https://b.example"}) { t.Fatalf("origins %v", c.CORSOrigins) }
+
+	c, _, err = Load(nil, env(map[string]string{"SNL_CONFIG": path}))
+	if err != nil || c.Addr != ":9000" { t.Fatalf("SNL_CONFIG should name the file: %v %+v", err, c) }
+}
+
+func TestTOML(t *testing.T) {
+	path := writeFile(t, "server.toml", `
+addr = "127.0.0.1:8443"
+tls_cert = 'cert.pem'
+tls_key = "key.pem"
+game_ttl = "2h"
+finished_ttl = "0s"
+log_level = "debug"
+log_format = "json"
+cors_origins = ["http://localhost:5173", "https://play.example"]
+`)
+	c, _, err := Load([]string{"-config=" + path}, env(nil))
+	if err != nil { t.Fatalf("load: %v", err) }
+	want := Default()
+	want.Addr, want.TLSCert, want.TLSKey = "127.0.0.1:8443", "cert.pem", "key.pem"
+	want.GameTTL, want.FinishedTTL, want.LogLevel, want.LogFormat = 2*time.Hour, 0, slog.LevelDebug, LogJSON
+	want.CORSOrigins = []string{"http://localhost:5173", "https://play.example"}
+	if !reflect.DeepEqual(c, want) { t.Fatalf("got %+v\nwant %+v", c, want) }
+	if !c.TLS() { t.Fatal("expected TLS") }
+}
+
+func TestBadFiles(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown.yaml": "port: 80\n",
+		"twice.yaml":   "addr: :1\naddr: :2\n",
+		"table.toml":   "[server]\naddr = \":1\"\n",
+		"quote.toml":   "addr = \":1\n",
+		"number.yaml":  "max_games: many\n",
+		"list.toml":    "cors_origins = [\"https://a.example\"\n",
+		"server.ini":   "addr=:1\n",
+	} {
+		if _, _, err := Load([]string{"--config", writeFile(t, name, data)}, env(nil)); err == nil { t.Errorf("%s: expected an error", name) }
+	}
+	if _, _, err := Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}, env(nil)); err == nil { t.Error("expected an error for a missing file") }
+}
+
+func TestValidation(t *testing.T) {
+	for _, args := range [][]string{
+		{"--addr", ""},
+		{"--tls-cert", "cert.pem"},
+		{"--default-grid", "1"},
+		{"--default-grid", "20", "--max-grid", "15"},
+		{"--max-grid", "5000"},
+		{"--max-players", "1"},
+		{"--max-games", "-1"},
+		{"--game-ttl", "-1m"},
+		{"--game-ttl", "soon"},
+		{"--cors-origins", "*,https://a.example"},
+		{"--cors-origins", "a.example"},
+		{"--cors-origins", "https://a.example/app"},
+		{"--log-level", "loud"},
+		{"--log-format", "xml"},
+		{"--broker", "localhost:6379"},
+		{"--max-body", "10"},
+		{"--max-name", "0"},
+		{"--ip-rate", "-1"},
+		{"--ip-rate", "fast"},
+		{"--game-rate", "2", "--game-burst", "0"},
+		{"--read-timeout", "-1s"},
+		{"--shutdown-timeout", "-5s"},
+		{"extra"},
+	} {
+		if _, _, err := Load(args, env(nil)); err == nil { t.Errorf("%v: expected an error", args) }
+	}
+	if _, _, err := Load(nil, env(map[string]string{"SNL_MAX_GAMES": "lots"})); err == nil || !strings.Contains(err.Error(), "SNL_MAX_GAMES") {
+		t.Errorf("expected an error naming the variable, got %v", err)
+	}
+	if _, _, err := Load([]string{"-h"}, env(nil)); !errors.Is(err, flag.ErrHelp) { t.Errorf("expected flag.ErrHelp, got %v", err) }
+}
+
+func TestPrintConfigReadsBack(t *testing.T) {
+	c, print, err := Load([]string{"--print-config", "--addr", "localhost:1 # not a comment", "--cors-origins", "https://a.example, https://b.example", "--log-level", "warn", "--game-ttl", "90m", "--ip-rate", "0.5", "--write-timeout", "0", "--shutdown-timeout", "1m", "--broker", "redis://:pw@cache:6379/1"}, env(nil))
+	if err != nil || !print { t.Fatalf("load: %v, print %v", err, print) }
+	var buf bytes.Buffer
+	if err := Write(&buf, c); err != nil { t.Fatal(err) }
+	back, _, err := Load([]string{"--config", writeFile(t, "printed.yaml", buf.String())}, env(nil))
+	if err != nil { t.Fatalf("reading back:\n%s\n%v", buf.String(), err) }
+	if !reflect.DeepEqual(back, c) { t.Fatalf("printed:\n%s\nread back %+v, want %+v", buf.String(), back, c) }
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parseFile reads the settings in a config file. Both formats are flat: one
+// setting per line, written key: value in YAML and key = value in TOML.
+// Values are numbers, quoted or bare strings, or lists in brackets; YAML lists
+// may also be written as "- item" lines under the key. Lists come back comma
+// separated.
+func parseFile(path string, data []byte) ([][2]string, error) {
+	sep := ""
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		sep = ":"
+	case ".toml":
+		sep = "="
+	default:
+		return nil, fmt.Errorf("%s: unknown config format, use .yaml, .yml or .toml", path)
+	}
+	var values [][2]string
+	seen := map[string]bool{}
+	// YAML key whose value is the "- item" lines that follow
+	var list string
+	var items []string
+	flush := func() {
+		if list != "" { values = append(values, [2]string{list, strings.Join(items, ",")}) }
+		list, items = "", nil
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", path, i+1, fmt.Sprintf(format, args...))
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || (sep == ":" && trimmed == "---") { continue }
+		if list != "" && strings.HasPrefix(trimmed, "- ") {
+			v, err := scalar(trimmed[2:])
+			if err != nil { return nil, fail("%v", err) }
+			items = append(items, v)
+			continue
+		}
+		flush()
+		if sep == "=" && strings.HasPrefix(trimmed, "[") { return nil, fail("tables are not supported, settings are top-level keys") }
+		key, raw, ok := strings.Cut(trimmed, sep)
+		if !ok { return nil, fail("expected key %s value", sep) }
+		key = strings.ReplaceAll(strings.TrimSpace(key), "-", "_")
+		if _, known := lookup(key); !known { return nil, fail("unknown setting %q", key) }
+		if seen[key] { return nil, fail("%s is set twice", key) }
+		seen[key] = true
+		raw = strings.TrimSpace(raw)
+		if sep == ":" && (raw == "" || strings.HasPrefix(raw, "#")) {
+			// block list follows
+			list = key
+			continue
+		}
+		var v string
+		var err error
+		if strings.HasPrefix(raw, "[") {
+			v, err = flowList(raw)
+		} else {
+			v, err = scalar(raw)
+		}
+		if err != nil { return nil, fail("%s: %v", key, err) }
+		values = append(values, [2]string{key, v})
+	}
+	flush()
+	return values, nil
+}
+
+// scalar reads one value and drops a trailing comment.
+func scalar(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	var v, rest string
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		q, err := strconv.QuotedPrefix(raw)
+		if err != nil { return "", fmt.Errorf("unterminated string %s", raw) }
+		v, _ = strconv.Unquote(q)
+		rest = raw[len(q):]
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 { return "", fmt.Errorf("unterminated string %s", raw) }
+		v, rest = raw[1:end+1], raw[end+2:]
+	default:
+		v = raw
+		if i := strings.Index(raw, " #"); i >= 0 { v = raw[:i] }
+		return strings.TrimSpace(v), nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after string", rest)
+	}
+	return v, nil
+}
+
+// flowList reads a one-line [a, b] list.
+func flowList(raw string) (string, error) {
+	end := strings.LastIndex(raw, "]")
+	if end < 0 { return "", fmt.Errorf("unterminated list %s", raw) }
+	if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after list", rest)
+	}
+	var items []string
+	for _, item := range strings.Split(raw[1:end], ",") {
+		if strings.TrimSpace(item) == "" { continue }
+		v, err := scalar(item)
+		if err != nil { return "", err }
+		items = append(items, v)
+	}
+	return strings.Join(items, ","), nil
+}
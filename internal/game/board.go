@@ -0,0 +1,227 @@
+package game
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Layout decides how square numbers are laid out on the board.
+type Layout string
+
+const (
+	// LayoutRowMajor numbers every row left to right.
+	LayoutRowMajor Layout = "row-major"
+	// LayoutSerpentine numbers rows alternately left to right and right to
+	// left (boustrophedon), like a printed board.
+	LayoutSerpentine Layout = "serpentine"
+	// LayoutPath follows an explicit, ordered list of cells.
+	LayoutPath Layout = "path"
+)
+
+// DefaultLayout is used when no layout is requested.
+const DefaultLayout = LayoutSerpentine
+
+// ParseLayout converts a user supplied layout name, defaulting to DefaultLayout
+// for an empty string.
+func ParseLayout(s string) (Layout, error) {
+	switch Layout(strings.ToLower(strings.TrimSpace(s))) {
+	case "":
+		return DefaultLayout, nil
+	case LayoutRowMajor:
+		return LayoutRowMajor, nil
+	case LayoutSerpentine:
+		return LayoutSerpentine, nil
+	case LayoutPath:
+		return LayoutPath, nil
+	}
+	return "", invalidBoard("unknown layout %q", s)
+}
+
+// Cell is a board position: X is the row counted from the starting edge and
+// Y the column counted from the left.
+type Cell struct {
+	X, Y int
+}
+
+// Board is the shape of play: a Width x Height area and the ordered path of
+// cells pawns travel along. Square n is the n-th cell of the path.
+type Board struct {
+	width, height int
+	layout        Layout
+	path          []Cell
+	index         map[Cell]int
+}
+
+// NewGridBoard returns a rectangular board covering every cell, numbered
+// according to layout.
+func NewGridBoard(width, height int, layout Layout) (*Board, error) {
+	if width < 1 || height < 1 || width*height < 2 {
+		return nil, invalidBoard("board %dx%d is too small", width, height)
+	}
+	if layout == LayoutPath {
+		return nil, invalidBoard("path layout needs an explicit list of cells")
+	}
+	path := make([]Cell, 0, width*height)
+	for row := 0; row < height; row++ {
+		for c := 0; c < width; c++ {
+			col := c
+			if layout == LayoutSerpentine && row%2 == 1 { col = width - 1 - c }
+			path = append(path, Cell{row, col})
+		}
+	}
+	return newBoard(width, height, layout, path), nil
+}
+
+// NewPathBoard returns a board whose squares are the given cells, in order of
+// play. Cells must lie inside width x height and may not repeat.
+func NewPathBoard(width, height int, cells []Cell) (*Board, error) {
+	if len(cells) < 2 {
+		return nil, invalidBoard("path needs at least 2 cells")
+	}
+	seen := make(map[Cell]struct{}, len(cells))
+	for i, c := range cells {
+		if c.X < 0 || c.Y < 0 || c.X >= height || c.Y >= width {
+			return nil, invalidBoard("path cell %d (%d,%d) is outside the %dx%d board", i+1, c.X, c.Y, width, height)
+		}
+		if _, ok := seen[c]; ok {
+			return nil, invalidBoard("path cell %d (%d,%d) is repeated", i+1, c.X, c.Y)
+		}
+		seen[c] = struct{}{}
+	}
+	return newBoard(width, height, LayoutPath, append([]Cell(nil), cells...)), nil
+}
+
+func newBoard(width, height int, layout Layout, path []Cell) *Board {
+	b := &Board{width: width, height: height, layout: layout, path: path, index: make(map[Cell]int, len(path))}
+	for i, c := range path { b.index[c] = i }
+	return b
+}
+
+func (b *Board) Width() int      { return b.width }
+func (b *Board) Height() int     { return b.height }
+func (b *Board) Layout() Layout  { return b.layout }
+func (b *Board) Squares() int    { return len(b.path) }
+
+// Cells returns the path of play, square 1 first.
+func (b *Board) Cells() []Cell { return append([]Cell(nil), b.path...) }
+
+// isGrid reports whether every cell of the rectangle is on the path, in which
+// case rows also order progress.
+func (b *Board) isGrid() bool { return b.layout != LayoutPath }
+
+// pointAt maps a zero-based path index to its cell; negative indexes are the
+// off-board starting position.
+func (b *Board) pointAt(total int) Point {
+	if total < 0 || total >= len(b.path) { return Point{-1, -1, -1} }
+	c := b.path[total]
+	return Point{c.X, c.Y, total}
+}
+
+// cellAt is the inverse of pointAt. ok is false for cells off the path.
+func (b *Board) cellAt(row, col int) (Point, bool) {
+	i, ok := b.index[Cell{row, col}]
+	if !ok { return Point{-1, -1, -1}, false }
+	return Point{row, col, i}, true
+}
+
+// span is the side length used to size generation for this board.
+func (b *Board) span() int {
+	if b.width == b.height { return b.width }
+	return int(math.Sqrt(float64(len(b.path))))
+}
+
+// Shape describes the board to clients. Cells is only sent for path boards;
+// grid boards can be derived from Width, Height and Layout.
+type Shape struct {
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	Layout  Layout   `json:"layout"`
+	Squares int      `json:"squares"`
+	Cells   [][2]int `json:"cells,omitempty"`
+}
+
+func (b *Board) shape() Shape {
+	s := Shape{Width: b.width, Height: b.height, Layout: b.layout, Squares: len(b.path)}
+	if !b.isGrid() {
+		s.Cells = make([][2]int, len(b.path))
+		for i, c := range b.path { s.Cells[i] = [2]int{c.X, c.Y} }
+	}
+	return s
+}
+
+// BoardDef is the board file format. Either Path (for custom tracks) or Width
+// and Height (for grids) must be set. Snakes and Ladders are optional; when
+// both are omitted they are generated at random. Tiles adds special squares.
+//
+//	{
+//	  "width": 8, "height": 6, "layout": "serpentine",
+//	  "snakes":  [{"from": 37, "to": 12}],
+//	  "ladders": [{"from": 4, "to": 25}],
+//	  "tiles":   [{"square": 20, "effect": "teleport", "target": 30},
+//	              {"square": 9, "effect": "shield"}]
+//	}
+type BoardDef struct {
+	Width   int      `json:"width,omitempty"`
+	Height  int      `json:"height,omitempty"`
+	Layout  Layout   `json:"layout,omitempty"`
+	Path    [][2]int `json:"path,omitempty"`
+	Snakes  []Jump   `json:"snakes,omitempty"`
+	Ladders []Jump   `json:"ladders,omitempty"`
+	Tiles   []Tile   `json:"tiles,omitempty"`
+}
+
+// Jump moves a pawn landing on square From to square To.
+type Jump struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// ParseBoardDef decodes a board definition.
+func ParseBoardDef(r io.Reader) (*BoardDef, error) {
+	var def BoardDef
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return nil, invalidBoard("invalid board definition: %v", err)
+	}
+	return &def, nil
+}
+
+// LoadBoardDef reads a board definition from a file.
+func LoadBoardDef(path string) (*BoardDef, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+	return ParseBoardDef(f)
+}
+
+// Size returns the width and height of the board the definition describes.
+// A path board without them takes the bounding box of its path.
+func (d *BoardDef) Size() (width, height int) {
+	w, h := d.Width, d.Height
+	if len(d.Path) == 0 { return w, h }
+	for _, c := range d.Path {
+		if d.Width == 0 && c[1]+1 > w { w = c[1] + 1 }
+		if d.Height == 0 && c[0]+1 > h { h = c[0] + 1 }
+	}
+	return w, h
+}
+
+// Board builds the board described by the definition.
+func (d *BoardDef) Board() (*Board, error) {
+	if len(d.Path) > 0 {
+		if d.Layout != "" && d.Layout != LayoutPath {
+			return nil, invalidBoard("layout %q cannot be combined with a path", d.Layout)
+		}
+		cells := make([]Cell, len(d.Path))
+		for i, c := range d.Path { cells[i] = Cell{c[0], c[1]} }
+		w, h := d.Size()
+		return NewPathBoard(w, h, cells)
+	}
+	layout, err := ParseLayout(string(d.Layout))
+	if err != nil { return nil, err }
+	return NewGridBoard(d.Width, d.Height, layout)
+}
@@ -0,0 +1,81 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRectangularBoard(t *testing.T) {
+	b, err := NewGridBoard(8, 5, LayoutSerpentine)
+	if err != nil { t.Fatalf("new board: %v", err) }
+	if b.Squares() != 40 { t.Fatalf("expected 40 squares, got %d", b.Squares()) }
+	g := NewWithBoard(b)
+	st := g.State()
+	if st.GridSize != 0 { t.Fatalf("rectangular board should not report a grid size, got %d", st.GridSize) }
+	if st.Board.Width != 8 || st.Board.Height != 5 || st.Board.Squares != 40 { t.Fatalf("unexpected shape %+v", st.Board) }
+	// square 9 starts the second row from the right
+	if p := g.PointAt(9); p.x != 1 || p.y != 7 { t.Fatalf("square 9 at (%d,%d), want (1,7)", p.x, p.y) }
+	for _, l := range st.Ladders {
+		if l.top.x >= 5 || l.top.y >= 8 || l.bottom.x >= 5 || l.bottom.y >= 8 { t.Fatalf("ladder outside board: %+v", l) }
+		if l.top.totalPos <= l.bottom.totalPos { t.Fatalf("ladder must lead up: %+v", l) }
+	}
+	for _, s := range st.Snakes {
+		if s.head.totalPos <= s.tail.totalPos { t.Fatalf("snake must lead down: %+v", s) }
+	}
+}
+
+func TestPathBoardFromDef(t *testing.T) {
+	def, err := ParseBoardDef(strings.NewReader(`{
+		"path": [[0,0],[0,1],[0,2],[1,2],[2,2],[2,1],[2,0]],
+		"snakes": [{"from": 6, "to": 2}],
+		"ladders": [{"from": 3, "to": 5}]
+	}`))
+	if err != nil { t.Fatalf("parse: %v", err) }
+	g, err := NewFromDef(def)
+	if err != nil { t.Fatalf("new from def: %v", err) }
+	st := g.State()
+	if st.Layout != LayoutPath || st.Board.Width != 3 || st.Board.Height != 3 || st.Board.Squares != 7 {
+		t.Fatalf("unexpected shape %+v", st.Board)
+	}
+	if len(st.Board.Cells) != 7 || st.Board.Cells[3] != [2]int{1, 2} { t.Fatalf("cells not reported: %v", st.Board.Cells) }
+	_ = g.AddPlayer("A")
+	_ = g.AddPlayer("B")
+	p := &g.players[0]
+	// roll 3 from the start lands on the ladder at square 3
+	g.rolledDice(p, 3)
+	if p.Position.Square() != 5 || p.Position.x != 2 || p.Position.y != 2 {
+		t.Fatalf("expected ladder to square 5 at (2,2), got square %d at (%d,%d)", p.Position.Square(), p.Position.x, p.Position.y)
+	}
+	// overshooting the 7-square track does not move
+	if g.rolledDice(p, 3) { t.Fatal("expected no move past the final square") }
+	g.rolledDice(p, 2)
+	if p.Position.Square() != 7 { t.Fatalf("expected to reach square 7, got %d", p.Position.Square()) }
+}
+
+func TestBoardDefValidation(t *testing.T) {
+	cases := map[string]string{
+		"repeated cell":   `{"path": [[0,0],[0,1],[0,0]]}`,
+		"cell outside":    `{"width": 2, "height": 1, "path": [[0,0],[0,2]]}`,
+		"snake upwards":   `{"width": 3, "height": 3, "snakes": [{"from": 2, "to": 5}]}`,
+		"ladder down":     `{"width": 3, "height": 3, "ladders": [{"from": 5, "to": 2}]}`,
+		"off board":       `{"width": 3, "height": 3, "ladders": [{"from": 5, "to": 12}]}`,
+		"shared start":    `{"width": 3, "height": 3, "snakes": [{"from": 5, "to": 2}], "ladders": [{"from": 5, "to": 8}]}`,
+		"final square":    `{"width": 3, "height": 3, "snakes": [{"from": 9, "to": 2}]}`,
+		"path and layout": `{"layout": "serpentine", "path": [[0,0],[0,1]]}`,
+		"too small":       `{"width": 1, "height": 1}`,
+		"unknown field":   `{"width": 3, "height": 3, "size": 3}`,
+	}
+	for name, body := range cases {
+		def, err := ParseBoardDef(strings.NewReader(body))
+		if err == nil { _, err = NewFromDef(def) }
+		if err == nil { t.Errorf("%s: expected an error", name) }
+	}
+}
+
+func TestLoadExampleBoard(t *testing.T) {
+	def, err := LoadBoardDef("../../boards/track.json")
+	if err != nil { t.Fatalf("load: %v", err) }
+	g, err := NewFromDef(def)
+	if err != nil { t.Fatalf("new from def: %v", err) }
+	if g.Board().Squares() != 22 { t.Fatalf("expected 22 squares, got %d", g.Board().Squares()) }
+}
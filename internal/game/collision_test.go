@@ -0,0 +1,60 @@
+package game
+
+import "testing"
+
+var collisionBoard = BoardDef{Width: 10, Height: 10, Ladders: []Jump{{From: 3, To: 30}}}
+
+func TestCollisionIgnoreSharesSquare(t *testing.T) {
+	g := newTestGame(t, collisionBoard, Rules{Collision: CollisionIgnore}, "A", "B")
+	a, b := &g.players[0], &g.players[1]
+	a.Position, b.Position = g.PointAt(10), g.PointAt(14)
+	m := g.move(a, 0, 4)
+	if a.Position.Square() != 14 || b.Position.Square() != 14 || len(m.Steps) != 0 { t.Fatalf("pawns should share 14, got %+v", m) }
+}
+
+func TestCollisionCapture(t *testing.T) {
+	g := newTestGame(t, collisionBoard, Rules{Collision: CollisionCapture}, "A", "B")
+	a, b := &g.players[0], &g.players[1]
+	a.Position, b.Position = g.PointAt(10), g.PointAt(14)
+	m := g.move(a, 0, 4)
+	if b.Position.Square() != 0 { t.Fatalf("B should be back at the start, at %d", b.Position.Square()) }
+	if len(m.Steps) != 1 || m.Steps[0].Kind != StepCapture || m.Steps[0].Player != "B" || m.Steps[0].From != 14 {
+		t.Fatalf("unexpected steps %+v", m.Steps)
+	}
+	// the capture is checked where the pawn ends, after the ladder
+	b.Position = g.PointAt(30)
+	a.Position = g.PointAt(1)
+	m = g.move(a, 0, 2)
+	if a.Position.Square() != 30 || b.Position.Square() != 0 || m.Steps[len(m.Steps)-1].Kind != StepCapture {
+		t.Fatalf("expected capture at the top of the ladder, got %+v", m)
+	}
+}
+
+func TestCollisionSwap(t *testing.T) {
+	g := newTestGame(t, collisionBoard, Rules{Collision: CollisionSwap}, "A", "B")
+	a, b := &g.players[0], &g.players[1]
+	a.Position, b.Position = g.PointAt(10), g.PointAt(14)
+	m := g.move(a, 0, 4)
+	if a.Position.Square() != 14 || b.Position.Square() != 10 { t.Fatalf("expected swap, A=%d B=%d", a.Position.Square(), b.Position.Square()) }
+	if s := m.Steps[0]; s.Kind != StepBump || s.To != 10 || s.Player != "B" { t.Fatalf("unexpected step %+v", s) }
+}
+
+func TestFinalSquareIsSafe(t *testing.T) {
+	g := newTestGame(t, collisionBoard, Rules{Collision: CollisionCapture}, "A", "B")
+	a, b := &g.players[0], &g.players[1]
+	a.Position, b.Position = g.PointAt(98), g.PointAt(100)
+	g.move(a, 0, 2)
+	if b.Position.Square() != 100 { t.Fatal("pawn on the final square should not be captured") }
+}
+
+func TestCollisionInHistory(t *testing.T) {
+	g := newTestGame(t, collisionBoard, Rules{Collision: CollisionCapture}, "A", "B")
+	g.players[1].Position = g.PointAt(14)
+	g.players[0].Position = g.PointAt(10)
+	g.mu.Lock()
+	g.play(0, 4)
+	g.mu.Unlock()
+	h := g.History()
+	if len(h) != 1 || len(h[0].Steps) == 0 || h[0].Steps[0].Kind != StepCapture { t.Fatalf("capture missing from history: %+v", h) }
+	if err := New(10).SetRules(Rules{Collision: "bounce"}); err == nil { t.Fatal("expected error for unknown rule") }
+}
@@ -0,0 +1,48 @@
+package game
+
+import (
+	"math/rand"
+	"time"
+)
+
+// dice is a game's source of randomness, used for board generation and
+// rolls. It counts the values drawn so its state can be saved as a seed and
+// a draw count, and replayed exactly.
+type dice struct {
+	seed  int64
+	draws uint64
+	src   rand.Source
+	r     *rand.Rand
+}
+
+// newSeed picks a seed for games created without one.
+func newSeed() int64 { return time.Now().UnixNano() }
+
+func newDice(seed int64) *dice {
+	d := &dice{seed: seed, src: rand.NewSource(seed)}
+	d.r = rand.New(d)
+	return d
+}
+
+// restoreDice returns dice seeded with seed that already drew draws values.
+func restoreDice(seed int64, draws uint64) *dice {
+	d := newDice(seed)
+	for d.draws < draws { d.Int63() }
+	return d
+}
+
+// Int63 and Seed make dice a rand.Source counting its draws.
+func (d *dice) Int63() int64 {
+	d.draws++
+	return d.src.Int63()
+}
+
+func (d *dice) Seed(seed int64) {
+	d.seed, d.draws = seed, 0
+	d.src.Seed(seed)
+}
+
+func (d *dice) Intn(n int) int { return d.r.Intn(n) }
+
+// roll throws a six-sided die.
+func (d *dice) roll() int { return d.r.Intn(6) + 1 }
@@ -0,0 +1,80 @@
+package game
+
+import "fmt"
+
+// Kind says what sort of problem an Error reports, so servers can pick a
+// status code.
+type Kind int
+
+const (
+	// KindInvalid means the request breaks a rule of the game or of the
+	// board or snapshot format.
+	KindInvalid Kind = iota + 1
+	// KindConflict means the game is not in a state that allows the request.
+	KindConflict
+	// KindForbidden means the player may not do this.
+	KindForbidden
+)
+
+// Error is an error from the game. Code is stable and machine readable;
+// Details holds the values the message was built from.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Is matches errors with the same code, so errors.Is(err, ErrNotHost) holds
+// for every variant of that error.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// with returns e with a specific message and details.
+func (e *Error) with(details map[string]interface{}, format string, args ...interface{}) *Error {
+	return &Error{Kind: e.Kind, Code: e.Code, Message: fmt.Sprintf(format, args...), Details: details}
+}
+
+var (
+	ErrInvalidBoard    = &Error{Kind: KindInvalid, Code: "invalid_board", Message: "invalid board"}
+	ErrInvalidRules    = &Error{Kind: KindInvalid, Code: "invalid_rules", Message: "invalid rules"}
+	ErrInvalidSnapshot = &Error{Kind: KindInvalid, Code: "invalid_snapshot", Message: "invalid snapshot"}
+	ErrRulesLocked     = &Error{Kind: KindConflict, Code: "rules_locked", Message: "rules can only be changed before players join"}
+
+	ErrGameFinished   = &Error{Kind: KindConflict, Code: "game_finished", Message: "game already finished"}
+	ErrDuplicateName  = &Error{Kind: KindConflict, Code: "duplicate_name", Message: "duplicate player name"}
+	ErrInvalidName    = &Error{Kind: KindInvalid, Code: "invalid_name", Message: "invalid name"}
+	ErrGameFull       = &Error{Kind: KindConflict, Code: "game_full", Message: "game is full"}
+	ErrTeamRequired   = &Error{Kind: KindInvalid, Code: "team_required", Message: "this game is played in teams, pick a team"}
+	ErrTeamNotAllowed = &Error{Kind: KindInvalid, Code: "team_not_allowed", Message: "this game is not played in teams"}
+	ErrUnknownPlayer  = &Error{Kind: KindInvalid, Code: "unknown_player", Message: "unknown player"}
+
+	ErrNotEnoughPlayers  = &Error{Kind: KindConflict, Code: "not_enough_players", Message: "need at least 2 players"}
+	ErrNotEnoughTeams    = &Error{Kind: KindConflict, Code: "not_enough_teams", Message: "need at least 2 teams"}
+	ErrPawnChoicePending = &Error{Kind: KindConflict, Code: "pawn_choice_pending", Message: "waiting for a pawn choice"}
+	ErrNoPawnChoice      = &Error{Kind: KindConflict, Code: "no_pawn_choice", Message: "no roll is waiting for a pawn choice"}
+	ErrPawnCannotMove    = &Error{Kind: KindInvalid, Code: "pawn_cannot_move", Message: "pawn cannot move"}
+
+	ErrUndoDisabled  = &Error{Kind: KindConflict, Code: "undo_disabled", Message: "undo is turned off for this game"}
+	ErrNothingToUndo = &Error{Kind: KindConflict, Code: "nothing_to_undo", Message: "nothing to undo"}
+	ErrNotHost       = &Error{Kind: KindForbidden, Code: "not_host", Message: "only the host can undo"}
+
+	ErrTooManyGames = &Error{Kind: KindConflict, Code: "too_many_games", Message: "server is full"}
+	ErrGameBusy     = &Error{Kind: KindConflict, Code: "game_busy", Message: "game is busy, try again"}
+)
+
+func invalidBoard(format string, args ...interface{}) error {
+	return ErrInvalidBoard.with(nil, format, args...)
+}
+
+func invalidRules(format string, args ...interface{}) error {
+	return ErrInvalidRules.with(nil, format, args...)
+}
+
+func invalidSnapshot(format string, args ...interface{}) error {
+	return ErrInvalidSnapshot.with(nil, format, args...)
+}
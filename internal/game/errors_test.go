@@ -0,0 +1,23 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorsMatchSentinels(t *testing.T) {
+	g := New(10)
+	if _, _, err := g.Roll(); !errors.Is(err, ErrNotEnoughPlayers) { t.Fatalf("expected ErrNotEnoughPlayers, got %v", err) }
+	_ = g.AddPlayer("A")
+	err := g.AddPlayer("a")
+	var ge *Error
+	if !errors.Is(err, ErrDuplicateName) || !errors.As(err, &ge) || ge.Kind != KindConflict || ge.Details["name"] != "a" {
+		t.Fatalf("unexpected duplicate name error %#v", err)
+	}
+	_ = g.AddPlayer("B")
+	if _, err := g.Undo("B"); !errors.Is(err, ErrNothingToUndo) { t.Fatalf("expected ErrNothingToUndo, got %v", err) }
+	_, _, _ = g.Roll()
+	if _, err := g.Undo("B"); !errors.Is(err, ErrNotHost) || errors.Is(err, ErrNothingToUndo) { t.Fatalf("expected ErrNotHost, got %v", err) }
+	if err := g.SetRules(Rules{Pawns: 9}); !errors.Is(err, ErrInvalidRules) { t.Fatalf("expected ErrInvalidRules, got %v", err) }
+	if _, err := NewFromDef(&BoardDef{Width: 1, Height: 1}); !errors.Is(err, ErrInvalidBoard) { t.Fatalf("expected ErrInvalidBoard, got %v", err) }
+}
@@ -1,136 +1,395 @@
 package game
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/arsulegai/snakeandladder/internal/broker"
 )
 
 // Core types (adapted from terminal version, without fmt prints on gameplay path)
 
+// Point is a board cell. x is the row counted from the starting edge of the
+// board and y the column counted from the left, so renderers can place it
+// directly. totalPos is the zero-based index of the square along the path of
+// play; use Square for the 1-based number shown to players.
 type Point struct {
 	x, y      int
 	totalPos  int
 }
 
+// Square returns the canonical 1-based square number, or 0 while the pawn is
+// still off the board.
+func (p Point) Square() int {
+	if p.totalPos < 0 { return 0 }
+	return p.totalPos + 1
+}
+
+// X returns the row of the cell, counted from the starting edge of the board.
+func (p Point) X() int { return p.x }
+
+// Y returns the column of the cell, counted from the left.
+func (p Point) Y() int { return p.y }
+
 type Snake struct {
 	head, tail Point
 }
 
+// Head returns where the snake bites and Tail where it drops the pawn.
+func (s Snake) Head() Point { return s.head }
+func (s Snake) Tail() Point { return s.tail }
+
 type Ladder struct {
 	top, bottom Point
 }
 
+// Bottom returns where the ladder is climbed and Top where it leads.
+func (l Ladder) Bottom() Point { return l.bottom }
+func (l Ladder) Top() Point    { return l.top }
+
+// Player is one seat at the table. Position is the player's most advanced
+// pawn; Pawns lists every pawn when the rules give players more than one.
 type Player struct {
-	Position Point  `json:"position"`
-	Name     string `json:"name"`
+	Position  Point   `json:"position"`
+	Pawns     []Point `json:"pawns,omitempty"`
+	Name      string  `json:"name"`
+	Team      string  `json:"team,omitempty"`
+	SkipTurns int    `json:"skipTurns,omitempty"`
+	Shield    bool   `json:"shield,omitempty"`
 }
 
 type State struct {
-	GridSize    int       `json:"gridSize"`
+	// GridSize is the side of square grid boards and 0 for any other shape.
+	GridSize    int       `json:"gridSize,omitempty"`
+	Layout      Layout    `json:"layout"`
+	Board       Shape     `json:"board"`
 	Players     []Player  `json:"players"`
 	Snakes      []Snake   `json:"snakes"`
 	Ladders     []Ladder  `json:"ladders"`
+	Tiles       []Tile    `json:"tiles,omitempty"`
+	Rules       Rules     `json:"rules"`
 	TurnIndex   int       `json:"turnIndex"`
+	Pending     *PendingMove `json:"pending,omitempty"`
+	Teams       []Team    `json:"teams,omitempty"`
 	Winner      *string   `json:"winner,omitempty"`
+	// WinningTeam is set when a team game is won; Winner is then the player
+	// whose finish decided it.
+	WinningTeam *string   `json:"winningTeam,omitempty"`
 	LastRoll    int       `json:"lastRoll"`
+	LastMove    *Move     `json:"lastMove,omitempty"`
+	// Moves counts the moves played, so clients can tell a new move apart
+	// from a repeated state.
+	Moves       int       `json:"moves"`
+	// Undoable is how many moves can be taken back; UndoVotes lists who
+	// asked so far when undo needs a vote.
+	Undoable    int       `json:"undoable"`
+	UndoVotes   []string  `json:"undoVotes,omitempty"`
 }
 
 type Game struct {
 	mu        sync.Mutex
-	gridSize  int
+	board     *Board
 	players   []Player
 	snakes    []Snake
 	ladders   []Ladder
+	tiles     map[int]Tile // keyed by zero-based square index
+	rules     Rules
+	dice      *dice
 	turnIndex int
+	// roll waiting for a pawn choice, and the timer that auto-picks
+	pending      *PendingMove
+	pendingTimer *time.Timer
 	winner    *string
+	winningTeam *string
+	// last player to take a turn for each team, keyed by lower-cased name
+	lastPlayed map[string]int
 	lastRoll  int
-	// SSE subscribers
-	subscribers map[chan []byte]struct{}
+	lastMove  *Move
+	history   []Move
+	// states before each move, newest last, and pending undo votes
+	undo      []snapshot
+	undoVotes []string
+	// event streams: the broker and topic events are published on, how
+	// many streams this server holds, and closed, which ends them with
+	// farewell
+	bus      broker.Broker
+	topic    string
+	streams  int
+	closed   chan struct{}
+	farewell []byte
+	// shared is set while a shared registry holds the game; rev counts the
+	// changes made to it
+	shared *sharedGame
+	rev    uint64
+	// most players allowed, 0 for no limit, and when the game last changed
+	maxPlayers int
+	updated    time.Time
+}
+
+// New creates a grid x grid game using DefaultLayout.
+func New(grid int) *Game { return NewWithLayout(grid, DefaultLayout) }
+
+// NewWithLayout creates a grid x grid game numbered according to layout.
+// grid must be at least 2 and layout must not be LayoutPath.
+func NewWithLayout(grid int, layout Layout) *Game {
+	b, err := NewGridBoard(grid, grid, layout)
+	if err != nil { panic("game: " + err.Error()) }
+	return NewWithBoard(b)
+}
+
+// NewWithBoard creates a game on b with randomly placed snakes and ladders.
+func NewWithBoard(b *Board) *Game {
+	g := newGame(b, newSeed())
+	g.generateEntities()
+	return g
+}
+
+// NewFromDef creates a game from a board definition, generating snakes and
+// ladders when the definition has none.
+func NewFromDef(def *BoardDef) (*Game, error) { return NewSeeded(def, newSeed()) }
+
+// NewSeeded is NewFromDef with a fixed seed for board generation and dice, so
+// the same seed and moves replay the same game.
+func NewSeeded(def *BoardDef, seed int64) (*Game, error) {
+	b, err := def.Board()
+	if err != nil { return nil, err }
+	g := newGame(b, seed)
+	if len(def.Snakes) == 0 && len(def.Ladders) == 0 {
+		g.generateEntities()
+		g.dropJumpsOn(def.Tiles)
+	} else {
+		if err := g.placeJumps(def.Snakes, def.Ladders); err != nil { return nil, err }
+	}
+	if err := g.placeTiles(def.Tiles); err != nil { return nil, err }
+	return g, nil
 }
 
-func New(grid int) *Game {
-	g := &Game{
-		gridSize:   grid,
+func newGame(b *Board, seed int64) *Game {
+	return &Game{
+		board:      b,
+		dice:       newDice(seed),
 		players:    []Player{},
+		tiles:      map[int]Tile{},
+		rules:      Rules{Pawns: 1, Collision: CollisionIgnore, TeamWin: TeamWinAny, Undo: UndoHost},
+		lastPlayed: map[string]int{},
 		turnIndex:  0,
 		winner:     nil,
 		lastRoll:   0,
-		subscribers: make(map[chan []byte]struct{}),
+		bus:        broker.NewLocal(),
+		topic:      "events",
+		closed:     make(chan struct{}),
+		updated:    time.Now(),
 	}
-	g.generateEntities(grid)
-	return g
 }
 
 // Public helpers
-func (g *Game) GridSize() int { return g.gridSize }
 
-func (g *Game) AddPlayer(name string) error {
+// GridSize returns the side of a square grid board, or 0 for other shapes.
+func (g *Game) GridSize() int {
+	if g.board.isGrid() && g.board.width == g.board.height { return g.board.width }
+	return 0
+}
+func (g *Game) Layout() Layout { return g.board.layout }
+func (g *Game) Board() *Board  { return g.board }
+
+// PointAt returns the cell holding the given 1-based square number.
+func (g *Game) PointAt(square int) Point { return g.pointAt(square - 1) }
+
+func (g *Game) pointAt(total int) Point { return g.board.pointAt(total) }
+
+// lastPos is the zero-based index of the winning square.
+func (g *Game) lastPos() int { return g.board.Squares() - 1 }
+
+func (g *Game) AddPlayer(name string) error { return g.addPlayer(name, "") }
+
+func (g *Game) addPlayer(name, team string) error {
+	if err := checkName("name", name); err != nil { return err }
+	if team != "" {
+		if err := checkName("team", team); err != nil { return err }
+	}
 	g.mu.Lock()
 	if g.winner != nil {
 		g.mu.Unlock()
-		return errors.New("game already finished")
+		return ErrGameFinished
+	}
+	if len(g.players) > 0 && g.teamPlay() != (team != "") {
+		g.mu.Unlock()
+		if team == "" { return ErrTeamRequired }
+		return ErrTeamNotAllowed
+	}
+	if g.maxPlayers > 0 && len(g.players) >= g.maxPlayers {
+		g.mu.Unlock()
+		return ErrGameFull.with(map[string]interface{}{"max": g.maxPlayers}, "game is full (%d players)", g.maxPlayers)
 	}
 	for _, p := range g.players {
 		if strings.EqualFold(p.Name, name) {
 			g.mu.Unlock()
-			return errors.New("duplicate player name")
+			return ErrDuplicateName.with(map[string]interface{}{"name": name}, "duplicate player name %q", name)
 		}
 	}
-	g.players = append(g.players, Player{Position: Point{-1, -1, -1}, Name: name})
+	p := Player{Position: Point{-1, -1, -1}, Name: name, Team: team}
+	if t := g.teamIndex(team); t >= 0 { p.Team = g.teamNames()[t] } // keep the first spelling
+	if g.rules.Pawns > 1 {
+		p.Pawns = make([]Point, g.rules.Pawns)
+		for i := range p.Pawns { p.Pawns[i] = Point{-1, -1, -1} }
+	}
+	g.players = append(g.players, p)
 	g.mu.Unlock()
 	g.broadcast()
 	return nil
 }
 
+// SetMaxPlayers limits how many players can join; 0 removes the limit.
+func (g *Game) SetMaxPlayers(n int) {
+	g.mu.Lock(); defer g.mu.Unlock()
+	g.maxPlayers = n
+}
+
+// checkName refuses names that are blank or not printable text, such as ones
+// holding control characters or invalid UTF-8.
+func checkName(field, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrInvalidName.with(map[string]interface{}{"field": field}, "%s is blank", field)
+	}
+	if !utf8.ValidString(name) {
+		return ErrInvalidName.with(map[string]interface{}{"field": field}, "%s is not valid UTF-8", field)
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return ErrInvalidName.with(map[string]interface{}{"field": field}, "%s may only hold printable characters", field)
+		}
+	}
+	return nil
+}
+
 func (g *Game) RollDice() (int, *string, error) {
+	m, winner, err := g.Roll()
+	return m.Roll, winner, err
+}
+
+// Roll plays the current player's turn and returns what happened.
+func (g *Game) Roll() (Move, *string, error) {
 	g.mu.Lock()
 	if len(g.players) < 2 {
 		g.mu.Unlock()
-		return 0, nil, errors.New("need at least 2 players")
+		return Move{}, nil, ErrNotEnoughPlayers
+	}
+	if g.teamPlay() && len(g.teamNames()) < 2 {
+		g.mu.Unlock()
+		return Move{}, nil, ErrNotEnoughTeams
 	}
 	if g.winner != nil {
 		w := g.winner
 		g.mu.Unlock()
-		return 0, w, nil
+		return Move{}, w, nil
+	}
+	if g.pending != nil {
+		who := g.pending.Player
+		g.mu.Unlock()
+		return Move{}, nil, ErrPawnChoicePending.with(map[string]interface{}{"player": who}, "waiting for %s to choose a pawn", who)
 	}
-	n := rand.Intn(6) + 1
+	g.save()
+	n := g.dice.roll()
 	g.lastRoll = n
 	p := &g.players[g.turnIndex]
-	moved := g.rolledDice(p, n)
-	if !moved {
-		// no move if overflow, but still advance turn
+	choices := g.movablePawns(p, n)
+	if len(choices) > 1 {
+		m := g.awaitChoice(p, n, choices)
+		g.mu.Unlock()
+		g.broadcast()
+		return m, nil, nil
 	}
+	// no move if overflow, but still advance turn
+	pawn := 0
+	if len(choices) == 1 { pawn = choices[0] }
+	m := g.play(pawn, n)
+	winner := g.winner
+	g.mu.Unlock()
+	g.broadcast()
+	return m, winner, nil
+}
+
+// play moves pawn of the current player by n, settles the winner and the
+// turn, and records the move. The roll saved the state undo goes back to.
+// Called with g.mu held.
+func (g *Game) play(pawn, n int) Move {
+	if g.pendingTimer != nil { g.pendingTimer.Stop() }
+	g.pending, g.pendingTimer = nil, nil
+	p := &g.players[g.turnIndex]
+	m := g.move(p, pawn, n)
+	g.markPlayed(g.turnIndex)
 	// check winner
-	if p.Position.totalPos == g.gridSize*g.gridSize-1 {
+	if g.finished(p) && (!g.teamPlay() || g.teamWon(p)) {
 		w := p.Name
 		g.winner = &w
+		if p.Team != "" {
+			t := p.Team
+			g.winningTeam = &t
+		}
+	}
+	// advance turn, unless a tile granted another roll
+	if g.winner == nil && !m.extraRoll() {
+		g.advanceTurn(&m)
+	}
+	g.history = append(g.history, m)
+	last := m
+	g.lastMove = &last
+	return m
+}
+
+// advanceTurn passes the turn on, skipping players who lost their turn and
+// recording each skip on m.
+func (g *Game) advanceTurn(m *Move) {
+	g.turnIndex = g.nextPlayer()
+	for g.players[g.turnIndex].SkipTurns > 0 {
+		q := &g.players[g.turnIndex]
+		q.SkipTurns--
+		m.Steps = append(m.Steps, Step{Kind: StepSkipped, Player: q.Name})
+		g.markPlayed(g.turnIndex)
+		g.turnIndex = g.nextPlayer()
 	}
-	// advance turn
-	g.turnIndex = (g.turnIndex + 1) % len(g.players)
-	winner := g.winner
-	g.mu.Unlock()
-	g.broadcast()
-	return n, winner, nil
+}
+
+// History returns every move played so far, oldest first.
+func (g *Game) History() []Move {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Move{}, g.history...)
 }
 
 func (g *Game) State() State {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	players := make([]Player, len(g.players))
+	for i, p := range g.players { players[i] = p.clone() }
 	return State{
-		GridSize:  g.gridSize,
-		Players:   append([]Player(nil), g.players...),
-		Snakes:    append([]Snake(nil), g.snakes...),
-		Ladders:   append([]Ladder(nil), g.ladders...),
+		GridSize:  g.GridSize(),
+		Layout:    g.board.layout,
+		Board:     g.board.shape(),
+		Players:   players,
+		Snakes:    append([]Snake{}, g.snakes...),
+		Ladders:   append([]Ladder{}, g.ladders...),
+		Tiles:     g.tileList(),
+		Rules:     g.rules,
 		TurnIndex: g.turnIndex,
+		Pending:   g.pending,
+		Teams:     g.teams(),
 		Winner:    g.winner,
+		WinningTeam: g.winningTeam,
 		LastRoll:  g.lastRoll,
+		LastMove:  g.lastMove,
+		Moves:     len(g.history),
+		Undoable:  len(g.undo),
+		UndoVotes: append([]string(nil), g.undoVotes...),
 	}
 }
 
@@ -142,75 +401,135 @@ func (g *Game) Subscribe(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("streaming unsupported"))
 		return
 	}
+	g.mu.Lock()
+	bus, topic := g.bus, g.topic
+	g.mu.Unlock()
+	// subscribe before reading the state, so no change falls in between
+	sub, err := bus.Subscribe(topic)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("streaming unavailable: " + err.Error()))
+		return
+	}
+	defer sub.Close()
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	ch := make(chan []byte, 8)
 	g.mu.Lock()
-	g.subscribers[ch] = struct{}{}
+	g.streams++
 	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.streams--
+		g.mu.Unlock()
+	}()
 
 	// send initial state
-	g.sendOn(ch)
+	payload, _ := json.Marshal(g.State())
+	_, _ = w.Write(event{data: payload}.frame())
 	flusher.Flush()
 
 	ctx := r.Context()
 	for {
 		select {
 		case <-ctx.Done():
-			g.mu.Lock()
-			delete(g.subscribers, ch)
-			g.mu.Unlock()
-			close(ch)
 			return
-		case msg := <-ch:
-			_, _ = fmt.Fprintf(w, "data: %s\n\n", string(msg))
+		case msg, ok := <-sub.C:
+			if !ok { return } // the broker went away; the client reconnects
+			_, _ = w.Write(msg)
 			flusher.Flush()
+		case <-g.closed:
+			// deliver what is queued, then say goodbye
+			for len(sub.C) > 0 { _, _ = w.Write(<-sub.C) }
+			_, _ = w.Write(g.farewell)
+			flusher.Flush()
+			return
 		}
 	}
 }
 
+// CloseStreams ends the game's event streams, open ones and any opened
+// later, with a last event named name carrying v. Only the first call has an
+// effect.
+func (g *Game) CloseStreams(name string, v interface{}) {
+	payload, _ := json.Marshal(v)
+	g.mu.Lock(); defer g.mu.Unlock()
+	select {
+	case <-g.closed:
+		return
+	default:
+	}
+	g.farewell = event{name: name, data: payload}.frame()
+	close(g.closed)
+}
+
+// event is one SSE message. Unnamed events carry the game State; named ones
+// (such as "rollback") carry their own payload.
+type event struct {
+	name string
+	data []byte
+}
+
+// frame formats ev as sent on the wire, which is also how it travels
+// through the broker.
+func (ev event) frame() []byte {
+	var b bytes.Buffer
+	if ev.name != "" {
+		fmt.Fprintf(&b, "event: %s\n", ev.name)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", ev.data)
+	return b.Bytes()
+}
+
 func (g *Game) broadcast() {
-	// Snapshot state and subscribers under lock
 	state := g.State() // obtains and releases lock internally
 	payload, _ := json.Marshal(state)
+	g.publish(event{data: payload})
+}
+
+// broadcastEvent sends a named event to every subscriber.
+func (g *Game) broadcastEvent(name string, v interface{}) {
+	payload, _ := json.Marshal(v)
+	g.publish(event{name: name, data: payload})
+}
+
+// publish records a change and sends ev to the game's streams, on every
+// server when the game is shared.
+func (g *Game) publish(ev event) {
 	g.mu.Lock()
-	subs := make([]chan []byte, 0, len(g.subscribers))
-	for ch := range g.subscribers {
-		subs = append(subs, ch)
-	}
+	g.updated = time.Now()
+	g.rev++
+	bus, topic, sh := g.bus, g.topic, g.shared
 	g.mu.Unlock()
-	for _, ch := range subs {
-		select {
-		case ch <- payload:
-		default:
-			// drop if slow
-		}
-	}
+	if sh != nil { sh.save(g) }
+	_ = bus.Publish(topic, ev.frame())
 }
 
-func (g *Game) sendOn(ch chan []byte) {
-	payload, _ := json.Marshal(g.State())
-	select {
-	case ch <- payload:
-	default:
-	}
+// Revision counts the changes published for the game, so a copy of
+// anything derived from it can tell when it went stale.
+func (g *Game) Revision() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.rev
 }
 
+// DroppedEvents returns how many events were dropped because a subscriber
+// could not keep up.
+func DroppedEvents() uint64 { return broker.Dropped() }
+
 // Internal logic adapted from original
-func (g *Game) generateEntities(num int) {
-	rand.Seed(time.Now().UnixNano())
+func (g *Game) generateEntities() {
+	num := g.board.span()
 	// Choose counts with sensible minimums and near-equal distribution
 	minCount := 3
 	maxCount := num/2
 	if maxCount < minCount { maxCount = minCount }
-	base := rand.Intn(maxCount-minCount+1) + minCount
+	base := g.dice.Intn(maxCount-minCount+1) + minCount
 	// vary by at most 1 between snakes and ladders
 	if base < minCount { base = minCount }
 	nSnakes := base
-	nLadders := base + (rand.Intn(3)-1) // -1,0,+1
+	nLadders := base + (g.dice.Intn(3)-1) // -1,0,+1
 	if nLadders < minCount { nLadders = minCount }
 	if nLadders > maxCount { nLadders = maxCount }
 	// Generate ladders first, enforcing uniqueness of bottoms (and tops for sanity)
@@ -220,7 +539,7 @@ func (g *Game) generateEntities(num int) {
 	attempts := 0
 	for len(g.ladders) < nLadders && attempts < nLadders*200 {
 		attempts++
-		lad := g.genLadder(num)
+		lad := g.genLadder()
 		bKey := key{lad.bottom.x, lad.bottom.y}
 		tKey := key{lad.top.x, lad.top.y}
 		if _, ok := ladderBottoms[bKey]; ok { continue }
@@ -234,7 +553,7 @@ func (g *Game) generateEntities(num int) {
 	attempts = 0
 	for len(g.snakes) < nSnakes && attempts < nSnakes*200 {
 		attempts++
-		sn := g.genSnake(num)
+		sn := g.genSnake()
 		hKey := key{sn.head.x, sn.head.y}
 		tKey := key{sn.tail.x, sn.tail.y}
 		if _, ok := snakeHeads[hKey]; ok { continue }
@@ -245,7 +564,7 @@ func (g *Game) generateEntities(num int) {
 		snakeHeads[hKey] = struct{}{}
 	}
 	// remove snakes with head at end
-	end := Point{num-1, num-1, 0}
+	end := g.pointAt(g.lastPos())
 	sClean := []Snake{}
 	for _, s := range g.snakes {
 		if s.head.canExistWhen(&end) { sClean = append(sClean, s) }
@@ -253,32 +572,75 @@ func (g *Game) generateEntities(num int) {
 	g.snakes = sClean
 }
 
-func (g *Game) genSnake(num int) Snake {
-	first, second := g.generateEndPoints(num, "snake")
+// placeJumps installs snakes and ladders given as square numbers.
+func (g *Game) placeJumps(snakes, ladders []Jump) error {
+	starts := make(map[int]string)
+	check := func(kind string, j Jump) (Point, Point, error) {
+		if j.From < 1 || j.From > g.board.Squares() || j.To < 1 || j.To > g.board.Squares() {
+			return Point{}, Point{}, invalidBoard("%s %d->%d is outside squares 1..%d", kind, j.From, j.To, g.board.Squares())
+		}
+		if j.From == g.board.Squares() {
+			return Point{}, Point{}, invalidBoard("%s %d->%d starts on the final square", kind, j.From, j.To)
+		}
+		if other, ok := starts[j.From]; ok {
+			return Point{}, Point{}, invalidBoard("%s %d->%d starts on the same square as a %s", kind, j.From, j.To, other)
+		}
+		starts[j.From] = kind
+		return g.PointAt(j.From), g.PointAt(j.To), nil
+	}
+	for _, j := range snakes {
+		if j.To >= j.From { return invalidBoard("snake %d->%d must lead down the board", j.From, j.To) }
+		head, tail, err := check("snake", j)
+		if err != nil { return err }
+		g.snakes = append(g.snakes, Snake{head: head, tail: tail})
+	}
+	for _, j := range ladders {
+		if j.To <= j.From { return invalidBoard("ladder %d->%d must lead up the board", j.From, j.To) }
+		bottom, top, err := check("ladder", j)
+		if err != nil { return err }
+		g.ladders = append(g.ladders, Ladder{top: top, bottom: bottom})
+	}
+	return nil
+}
+
+func (g *Game) genSnake() Snake {
+	first, second := g.generateEndPoints("snake")
 	return Snake{head: *first, tail: *second}
 }
 
-func (g *Game) genLadder(num int) Ladder {
-	first, second := g.generateEndPoints(num, "ladder")
+func (g *Game) genLadder() Ladder {
+	first, second := g.generateEndPoints("ladder")
 	return Ladder{top: *first, bottom: *second}
 }
 
-func (g *Game) generateEndPoints(num int, entityType string) (*Point, *Point) {
-	first := g.getPoint(num)
-	second := g.getPoint(num)
-	for !(second.canExistWhen(first) && first.canBeOnTop(second) && g.entityTypeCanHave(first, second, entityType)) {
-		first = g.getPoint(num)
-		second = g.getPoint(num)
+func (g *Game) generateEndPoints(entityType string) (*Point, *Point) {
+	first := g.getPoint()
+	second := g.getPoint()
+	for !(second.canExistWhen(first) && g.canBeOnTop(first, second) && g.entityTypeCanHave(first, second, entityType)) {
+		first = g.getPoint()
+		second = g.getPoint()
 	}
 	return first, second
 }
 
-func (g *Game) getPoint(num int) *Point {
-	rand.Seed(time.Now().UnixNano())
-	x := rand.Intn(num)
-	y := rand.Intn(num)
-	for y == x { y = rand.Intn(num) }
-	return &Point{x, y, x*g.gridSize + y}
+func (g *Game) getPoint() *Point {
+	b := g.board
+	if !b.isGrid() {
+		p := b.pointAt(g.dice.Intn(b.Squares()))
+		return &p
+	}
+	x := g.dice.Intn(b.height)
+	y := g.dice.Intn(b.width)
+	for b.width == b.height && y == x { y = g.dice.Intn(b.width) }
+	p, _ := b.cellAt(x, y)
+	return &p
+}
+
+// canBeOnTop reports whether p is further along the board than another; on
+// grids it must also be on a higher row.
+func (g *Game) canBeOnTop(p, another *Point) bool {
+	if g.board.isGrid() && g.board.height > 1 { return p.canBeOnTop(another) }
+	return p.totalPos > another.totalPos
 }
 
 func (g *Game) entityTypeCanHave(first *Point, second *Point, entityType string) bool {
@@ -322,41 +684,86 @@ func (g *Game) gotElevated(num int) (bool, func() int) {
 }
 
 func (g *Game) rolledDice(p *Player, n int) bool {
+	return g.move(p, 0, n).Moved
+}
+
+// JSON helpers for client
+// pointJSON is the wire form of a Point. Total is the zero-based index along
+// the path and Square the 1-based number, 0 (and Total -1) being the start.
+type pointJSON struct {
+	X      *int `json:"x"`
+	Y      *int `json:"y"`
+	Total  *int `json:"total"`
+	Square *int `json:"square"`
+}
+
+func (p Point) MarshalJSON() ([]byte, error) {
+	sq := p.Square()
+	return json.Marshal(pointJSON{X: &p.x, Y: &p.y, Total: &p.totalPos, Square: &sq})
+}
+
+// UnmarshalJSON accepts the form written by MarshalJSON. Either total or
+// square may be left out; when both are given they must agree. Points on the
+// board need x and y.
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var v pointJSON
+	if err := json.Unmarshal(data, &v); err != nil { return err }
 	var total int
-	if p.Position.x != -1 && p.Position.y != -1 {
-		total = p.Position.x*g.gridSize + p.Position.y + n
-	} else {
-		total = n - 1
-	}
-	if total >= g.gridSize*g.gridSize { return false }
-	// Apply chained effects: ladder->ladder, snake->snake, or mixed sequences
-	// Continue until no more transitions apply, capped by a safe guard.
-	for guard := 0; guard < g.gridSize*g.gridSize; guard++ {
-		if ok, f := g.hitBySnake(total); ok {
-			total = f()
-			continue
-		}
-		if ok, f := g.gotElevated(total); ok {
-			total = f()
-			continue
+	switch {
+	case v.Total != nil && v.Square != nil:
+		total = *v.Total
+		if want := (Point{totalPos: total}).Square(); *v.Square != want {
+			return fmt.Errorf("point total %d does not match square %d", total, *v.Square)
 		}
-		break
+	case v.Total != nil:
+		total = *v.Total
+	case v.Square != nil:
+		total = *v.Square - 1
+	default:
+		return errors.New("point needs a total or a square")
 	}
-	p.Position.totalPos = total
-	p.Position.x = total / g.gridSize
-	p.Position.y = total % g.gridSize
-	return true
+	if total < -1 { return fmt.Errorf("invalid point total %d", total) }
+	if total == -1 {
+		*p = Point{-1, -1, -1}
+		return nil
+	}
+	if v.X == nil || v.Y == nil { return fmt.Errorf("point on square %d needs x and y", total+1) }
+	*p = Point{*v.X, *v.Y, total}
+	return nil
 }
 
-// JSON helpers for client
-func (p Point) MarshalJSON() ([]byte, error) {
-	return []byte(fmt.Sprintf("{\"x\":%d,\"y\":%d,\"total\":%d}", p.x, p.y, p.totalPos)), nil
+type snakeJSON struct {
+	Head Point `json:"head"`
+	Tail Point `json:"tail"`
+}
+
+func (s Snake) MarshalJSON() ([]byte, error) { return json.Marshal(snakeJSON{s.head, s.tail}) }
+
+func (s *Snake) UnmarshalJSON(data []byte) error {
+	var v snakeJSON
+	if err := json.Unmarshal(data, &v); err != nil { return err }
+	if v.Head.totalPos <= v.Tail.totalPos || v.Tail.totalPos < 0 {
+		return fmt.Errorf("snake %d->%d must lead down the board", v.Head.Square(), v.Tail.Square())
+	}
+	s.head, s.tail = v.Head, v.Tail
+	return nil
 }
-func (s Snake) MarshalJSON() ([]byte, error) {
-	return []byte(fmt.Sprintf("{\"head\":{\"x\":%d,\"y\":%d},\"tail\":{\"x\":%d,\"y\":%d}}", s.head.x, s.head.y, s.tail.x, s.tail.y)), nil
+
+type ladderJSON struct {
+	Top    Point `json:"top"`
+	Bottom Point `json:"bottom"`
 }
-func (l Ladder) MarshalJSON() ([]byte, error) {
-	return []byte(fmt.Sprintf("{\"top\":{\"x\":%d,\"y\":%d},\"bottom\":{\"x\":%d,\"y\":%d}}", l.top.x, l.top.y, l.bottom.x, l.bottom.y)), nil
+
+func (l Ladder) MarshalJSON() ([]byte, error) { return json.Marshal(ladderJSON{l.top, l.bottom}) }
+
+func (l *Ladder) UnmarshalJSON(data []byte) error {
+	var v ladderJSON
+	if err := json.Unmarshal(data, &v); err != nil { return err }
+	if v.Top.totalPos <= v.Bottom.totalPos || v.Bottom.totalPos < 0 {
+		return fmt.Errorf("ladder %d->%d must lead up the board", v.Bottom.Square(), v.Top.Square())
+	}
+	l.top, l.bottom = v.Top, v.Bottom
+	return nil
 }
 
 // In-memory registry of games
@@ -365,20 +772,164 @@ type Registry struct {
 	mu    sync.Mutex
 	seq   int
 	games map[string]*Game
+	max   int // most games held by Register, 0 for no limit
+	// bus carries the events of the games held; shared is set by Share
+	bus    broker.Broker
+	shared *sharing
 }
 
-func NewRegistry() *Registry { return &Registry{games: make(map[string]*Game)} }
+func NewRegistry() *Registry { return &Registry{games: make(map[string]*Game), bus: broker.NewLocal()} }
 
 func (r *Registry) Create(grid int) (string, *Game) {
-	r.mu.Lock(); defer r.mu.Unlock()
-	r.seq++
-	id := strconv.Itoa(r.seq)
-	g := New(grid)
+	return r.CreateWithLayout(grid, DefaultLayout)
+}
+func (r *Registry) CreateWithLayout(grid int, layout Layout) (string, *Game) {
+	g := NewWithLayout(grid, layout)
+	return r.Add(g), g
+}
+
+// CreateFromDef creates and registers a game from a board definition.
+func (r *Registry) CreateFromDef(def *BoardDef) (string, *Game, error) {
+	g, err := NewFromDef(def)
+	if err != nil { return "", nil, err }
+	return r.Add(g), g, nil
+}
+
+// Add registers an existing game and returns its id. It ignores the limit
+// set with SetMaxGames. A shared registry that cannot reach its broker
+// returns ""; Register reports why.
+func (r *Registry) Add(g *Game) string {
+	id, _ := r.add(g, false)
+	return id
+}
+
+// Register is Add that fails with ErrTooManyGames when the registry is full.
+func (r *Registry) Register(g *Game) (string, error) { return r.add(g, true) }
+
+// add registers g under a new id, applying the limit when limited is set.
+// Shared registries take the id from the broker and store the game there.
+func (r *Registry) add(g *Game, limited bool) (string, error) {
+	sh := r.sharing()
+	var id string
+	if sh != nil {
+		n, err := sh.b.Incr(seqKey)
+		if err != nil { return "", err }
+		id = strconv.FormatInt(n, 10)
+	}
+	r.mu.Lock()
+	if limited && r.max > 0 && len(r.games) >= r.max {
+		r.mu.Unlock()
+		return "", ErrTooManyGames.with(map[string]interface{}{"max": r.max}, "server is full (%d games)", r.max)
+	}
+	if sh == nil {
+		r.seq++
+		id = strconv.Itoa(r.seq)
+	}
+	r.attach(id, g)
 	r.games[id] = g
-	return id, g
+	r.mu.Unlock()
+	if sh != nil {
+		if err := r.store(id, g); err != nil {
+			r.mu.Lock()
+			delete(r.games, id)
+			r.mu.Unlock()
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// attach connects g to the registry's broker under id. Called with r.mu
+// held.
+func (r *Registry) attach(id string, g *Game) {
+	g.mu.Lock(); defer g.mu.Unlock()
+	g.bus, g.topic = r.bus, eventsTopic(id)
+	if r.shared != nil { g.shared = &sharedGame{reg: r, id: id} }
 }
+
+// SetMaxGames limits how many games Register accepts; 0 removes the limit.
+func (r *Registry) SetMaxGames(n int) {
+	r.mu.Lock(); defer r.mu.Unlock()
+	r.max = n
+}
+
 func (r *Registry) Get(id string) (*Game, bool) {
 	r.mu.Lock(); defer r.mu.Unlock()
 	g, ok := r.games[id]
 	return g, ok
 }
+
+// Stats summarizes the games held, for monitoring.
+type Stats struct {
+	// Games by state: waiting for players, being played and finished.
+	Waiting, Playing, Finished int
+	// Players in games not finished yet, and open event streams.
+	Players, Subscribers int
+}
+
+// Stats counts the games held by state, with their players and streams.
+func (r *Registry) Stats() Stats {
+	r.mu.Lock()
+	games := make([]*Game, 0, len(r.games))
+	for _, g := range r.games { games = append(games, g) }
+	r.mu.Unlock()
+	var st Stats
+	for _, g := range games {
+		g.mu.Lock()
+		switch {
+		case g.winner != nil:
+			st.Finished++
+		case len(g.history) == 0:
+			st.Waiting++
+			st.Players += len(g.players)
+		default:
+			st.Playing++
+			st.Players += len(g.players)
+		}
+		st.Subscribers += g.streams
+		g.mu.Unlock()
+	}
+	return st
+}
+
+// CloseStreams calls CloseStreams on every game held, for example before
+// the server stops.
+func (r *Registry) CloseStreams(name string, v interface{}) {
+	r.mu.Lock()
+	games := make([]*Game, 0, len(r.games))
+	for _, g := range r.games { games = append(games, g) }
+	r.mu.Unlock()
+	for _, g := range games { g.CloseStreams(name, v) }
+}
+
+// Len returns the number of games held.
+func (r *Registry) Len() int {
+	r.mu.Lock(); defer r.mu.Unlock()
+	return len(r.games)
+}
+
+// ExpiredEvent is the last event on the streams of a game Expire removed.
+const ExpiredEvent = "expired"
+
+// Expire removes games unchanged for longer than idle, or finished ones
+// unchanged for longer than finished, as of now, ending their streams with
+// an ExpiredEvent. A zero duration keeps those games forever. It returns the
+// ids removed, in no particular order.
+func (r *Registry) Expire(now time.Time, idle, finished time.Duration) []string {
+	r.mu.Lock()
+	var gone []string
+	var closing []*Game
+	for id, g := range r.games {
+		g.mu.Lock()
+		age, done := now.Sub(g.updated), g.winner != nil
+		g.mu.Unlock()
+		if (idle > 0 && age > idle) || (done && finished > 0 && age > finished) {
+			delete(r.games, id)
+			gone = append(gone, id)
+			closing = append(closing, g)
+		}
+	}
+	r.mu.Unlock()
+	for _, g := range closing { g.CloseStreams(ExpiredEvent, map[string]string{"message": "game expired"}) }
+	return gone
+}
@@ -1,6 +1,12 @@
 package game
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -41,3 +47,151 @@ func TestRollDiceAdvancesTurn(t *testing.T) {
 	if st.TurnIndex != 1 { t.Fatalf("expected turn index 1, got %d", st.TurnIndex) }
 	if st.LastRoll != roll { t.Fatalf("last roll mismatch: %d vs %d", st.LastRoll, roll) }
 }
+
+func TestMaxPlayers(t *testing.T) {
+	g := New(10)
+	g.SetMaxPlayers(2)
+	_ = g.AddPlayer("Arun")
+	_ = g.AddPlayer("Megha")
+	if err := g.AddPlayer("Ravi"); !errors.Is(err, ErrGameFull) { t.Fatalf("expected ErrGameFull, got %v", err) }
+	g.SetMaxPlayers(0)
+	if err := g.AddPlayer("Ravi"); err != nil { t.Fatalf("add player without limit: %v", err) }
+}
+
+func TestRegistryLimitAndExpiry(t *testing.T) {
+	reg := NewRegistry()
+	reg.SetMaxGames(2)
+	idle, _ := reg.Register(New(10))
+	finished := New(10)
+	_ = finished.AddPlayer("Arun")
+	_ = finished.AddPlayer("Megha")
+	for finished.State().Winner == nil {
+		if _, _, err := finished.Roll(); err != nil { t.Fatalf("roll: %v", err) }
+	}
+	done, _ := reg.Register(finished)
+	if _, err := reg.Register(New(10)); !errors.Is(err, ErrTooManyGames) { t.Fatalf("expected ErrTooManyGames, got %v", err) }
+
+	now := time.Now()
+	if gone := reg.Expire(now, time.Hour, 0); len(gone) != 0 { t.Fatalf("nothing should expire yet, removed %v", gone) }
+	if gone := reg.Expire(now.Add(2*time.Minute), time.Hour, time.Minute); len(gone) != 1 || gone[0] != done {
+		t.Fatalf("expected the finished game %s to expire, removed %v", done, gone)
+	}
+	if gone := reg.Expire(now.Add(2*time.Hour), 0, time.Minute); len(gone) != 0 { t.Fatalf("idle expiry is off, removed %v", gone) }
+	if gone := reg.Expire(now.Add(2*time.Hour), time.Hour, 0); len(gone) != 1 || gone[0] != idle {
+		t.Fatalf("expected the idle game %s to expire, removed %v", idle, gone)
+	}
+	if reg.Len() != 0 { t.Fatalf("expected an empty registry, have %d games", reg.Len()) }
+	if _, err := reg.Register(New(10)); err != nil { t.Fatalf("register after expiry: %v", err) }
+}
+
+func TestExpiryEndsStreams(t *testing.T) {
+	reg := NewRegistry()
+	g := New(10)
+	id := reg.Add(g)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		g.Subscribe(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+	for subscribed := false; !subscribed; {
+		g.mu.Lock()
+		subscribed = g.streams == 1
+		g.mu.Unlock()
+	}
+	if gone := reg.Expire(time.Now().Add(2*time.Hour), time.Hour, 0); len(gone) != 1 || gone[0] != id { t.Fatalf("expected %s to expire, removed %v", id, gone) }
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after its game expired")
+	}
+	if !strings.HasSuffix(w.Body.String(), "event: expired\ndata: {\"message\":\"game expired\"}\n\n") { t.Fatalf("stream did not end with the expiry:\n%s", w.Body.String()) }
+	if st := (&Registry{games: map[string]*Game{id: g}}).Stats(); st.Subscribers != 0 { t.Fatalf("expected no subscribers, got %d", st.Subscribers) }
+}
+
+func TestPlayerNamesMustBePrintable(t *testing.T) {
+	g := New(10)
+	for _, name := range []string{"", "  ", "Arun\n", "Me\x00gha", "\xff", "Ravi‮"} {
+		if err := g.AddPlayer(name); !errors.Is(err, ErrInvalidName) { t.Errorf("%q: expected ErrInvalidName, got %v", name, err) }
+	}
+	if err := g.AddPlayerToTeam("Arun", "Red\tTeam"); !errors.Is(err, ErrInvalidName) { t.Errorf("team: expected ErrInvalidName, got %v", err) }
+	if err := g.AddPlayer("Anaïs Nair"); err != nil { t.Errorf("printable name refused: %v", err) }
+}
+
+func TestRegistryStats(t *testing.T) {
+	reg := NewRegistry()
+	reg.Add(New(10))
+	playing := New(10)
+	_ = playing.AddPlayer("Arun")
+	_ = playing.AddPlayer("Megha")
+	_, _, _ = playing.Roll()
+	reg.Add(playing)
+	finished := New(10)
+	_ = finished.AddPlayer("Ravi")
+	_ = finished.AddPlayer("Anu")
+	for finished.State().Winner == nil { _, _, _ = finished.Roll() }
+	reg.Add(finished)
+	want := Stats{Waiting: 1, Playing: 1, Finished: 1, Players: 2}
+	if st := reg.Stats(); st != want { t.Fatalf("got %+v, want %+v", st, want) }
+}
+
+// stuckWriter is an event stream client that stops reading after the first
+// write.
+type stuckWriter struct {
+	header http.Header
+	wrote  chan struct{}
+	once   sync.Once
+}
+
+func (w *stuckWriter) Header() http.Header { return w.header }
+func (w *stuckWriter) WriteHeader(int)     {}
+func (w *stuckWriter) Flush()              {}
+func (w *stuckWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.wrote) })
+	select {} // never returns, like a client that stopped reading
+}
+
+func TestSlowSubscribersDropEvents(t *testing.T) {
+	g := New(10)
+	w := &stuckWriter{header: http.Header{}, wrote: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.Subscribe(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	<-w.wrote
+	before := DroppedEvents()
+	for i := 0; i < 20; i++ { g.broadcast() }
+	// the stream holds one event and buffers 8
+	if d := DroppedEvents() - before; d < 11 { t.Fatalf("expected at least 11 dropped events, got %d", d) }
+	if st := (&Registry{games: map[string]*Game{"1": g}}).Stats(); st.Subscribers != 1 { t.Fatalf("expected 1 subscriber, got %d", st.Subscribers) }
+}
+
+func TestCloseStreams(t *testing.T) {
+	g := New(10)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		g.Subscribe(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+	for subscribed := false; !subscribed; {
+		g.mu.Lock()
+		subscribed = g.streams == 1
+		g.mu.Unlock()
+	}
+	g.CloseStreams("restarting", map[string]string{"message": "bye"})
+	g.CloseStreams("ignored", nil)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after CloseStreams")
+	}
+	if !strings.HasSuffix(w.Body.String(), "event: restarting\ndata: {\"message\":\"bye\"}\n\n") { t.Fatalf("stream did not end with the farewell:\n%s", w.Body.String()) }
+	if st := (&Registry{games: map[string]*Game{"1": g}}).Stats(); st.Subscribers != 0 { t.Fatalf("expected no subscribers, got %d", st.Subscribers) }
+
+	// streams opened later end at once
+	late := httptest.NewRecorder()
+	g.Subscribe(late, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.HasPrefix(late.Body.String(), "data: {") || !strings.HasSuffix(late.Body.String(), "event: restarting\ndata: {\"message\":\"bye\"}\n\n") {
+		t.Fatalf("late stream got:\n%s", late.Body.String())
+	}
+}
@@ -0,0 +1,31 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestGame sets up a game on def under rules and seats players, each
+// given as "Name" or "Name:Team".
+func newTestGame(t *testing.T, def BoardDef, rules Rules, players ...string) *Game {
+	t.Helper()
+	g, err := NewFromDef(&def)
+	if err != nil { t.Fatalf("new from def: %v", err) }
+	if err := g.SetRules(rules); err != nil { t.Fatalf("set rules: %v", err) }
+	for _, p := range players {
+		name, team, ok := strings.Cut(p, ":")
+		add := g.AddPlayer
+		if ok { add = func(name string) error { return g.AddPlayerToTeam(name, team) } }
+		if err := add(name); err != nil { t.Fatalf("add %s: %v", name, err) }
+	}
+	return g
+}
+
+// playFixed plays the current player's turn with a roll of n, saving the
+// state first as a roll does.
+func playFixed(g *Game, n int) {
+	g.mu.Lock()
+	g.save()
+	g.play(0, n)
+	g.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	g, _ := NewSeeded(&BoardDef{Width: 10, Height: 10}, 5)
+	_ = g.AddPlayer("A")
+	_ = g.AddPlayer("B")
+	for i := 0; i < 4; i++ { _, _, _ = g.Roll() }
+	want := g.State()
+	data, err := json.Marshal(want)
+	if err != nil { t.Fatalf("marshal: %v", err) }
+	var got State
+	if err := json.Unmarshal(data, &got); err != nil { t.Fatalf("unmarshal: %v", err) }
+	if !reflect.DeepEqual(want.Players, got.Players) || !reflect.DeepEqual(want.Snakes, got.Snakes) || !reflect.DeepEqual(want.Ladders, got.Ladders) {
+		t.Fatalf("positions changed in transit:\n%+v\n%+v", want, got)
+	}
+	again, _ := json.Marshal(got)
+	if string(again) != string(data) { t.Fatalf("state changed in transit:\n%s\n%s", data, again) }
+}
+
+func TestSnakeAndLadderJSON(t *testing.T) {
+	g := New(10)
+	s := Snake{head: g.PointAt(40), tail: g.PointAt(12)}
+	data, _ := json.Marshal(s)
+	if !strings.Contains(string(data), `"total":39,"square":40`) || !strings.Contains(string(data), `"square":12`) {
+		t.Fatalf("unexpected snake JSON %s", data)
+	}
+	var back Snake
+	if err := json.Unmarshal(data, &back); err != nil || back != s { t.Fatalf("snake round trip: %+v %v", back, err) }
+	l := Ladder{top: g.PointAt(70), bottom: g.PointAt(8)}
+	data, _ = json.Marshal(l)
+	var lb Ladder
+	if err := json.Unmarshal(data, &lb); err != nil || lb != l { t.Fatalf("ladder round trip: %+v %v", lb, err) }
+}
+
+func TestPointUnmarshal(t *testing.T) {
+	for in, want := range map[string]Point{
+		`{"x":0,"y":3,"total":3,"square":4}`: {0, 3, 3},
+		`{"x":1,"y":9,"square":11}`:          {1, 9, 10},
+		`{"square":0}`:                       {-1, -1, -1},
+		`{"x":-1,"y":-1,"total":-1}`:         {-1, -1, -1},
+	} {
+		var p Point
+		if err := json.Unmarshal([]byte(in), &p); err != nil || p != want { t.Errorf("%s: got %+v, %v", in, p, err) }
+	}
+	for _, in := range []string{
+		`{"x":0,"y":0}`,
+		`{"x":0,"y":3,"total":3,"square":5}`,
+		`{"square":4}`,
+		`{"total":-2}`,
+	} {
+		var p Point
+		if err := json.Unmarshal([]byte(in), &p); err == nil { t.Errorf("%s: expected an error", in) }
+	}
+	var s Snake
+	if err := json.Unmarshal([]byte(`{"head":{"square":0},"tail":{"x":0,"y":1,"square":2}}`), &s); err == nil {
+		t.Error("a snake must lead down the board")
+	}
+}
@@ -0,0 +1,52 @@
+package game
+
+import "testing"
+
+func TestSerpentineSquares(t *testing.T) {
+	g := NewWithLayout(10, LayoutSerpentine)
+	cases := []struct{ square, x, y int }{
+		{1, 0, 0}, {10, 0, 9}, {11, 1, 9}, {20, 1, 0}, {21, 2, 0}, {37, 3, 3}, {100, 9, 0},
+	}
+	for _, c := range cases {
+		p := g.PointAt(c.square)
+		if p.x != c.x || p.y != c.y || p.Square() != c.square {
+			t.Fatalf("square %d: got x=%d y=%d square=%d, want x=%d y=%d", c.square, p.x, p.y, p.Square(), c.x, c.y)
+		}
+		if back, _ := g.board.cellAt(p.x, p.y); back.Square() != c.square {
+			t.Fatalf("cellAt(%d,%d) = square %d, want %d", p.x, p.y, back.Square(), c.square)
+		}
+	}
+}
+
+func TestRowMajorSquares(t *testing.T) {
+	g := NewWithLayout(10, LayoutRowMajor)
+	cases := []struct{ square, x, y int }{
+		{1, 0, 0}, {10, 0, 9}, {11, 1, 0}, {37, 3, 6}, {100, 9, 9},
+	}
+	for _, c := range cases {
+		p := g.PointAt(c.square)
+		if p.x != c.x || p.y != c.y || p.Square() != c.square {
+			t.Fatalf("square %d: got x=%d y=%d square=%d, want x=%d y=%d", c.square, p.x, p.y, p.Square(), c.x, c.y)
+		}
+	}
+}
+
+func TestMoveFollowsLayout(t *testing.T) {
+	g := NewWithLayout(10, LayoutSerpentine)
+	g.snakes, g.ladders = nil, nil
+	_ = g.AddPlayer("A")
+	_ = g.AddPlayer("B")
+	p := &g.players[0]
+	p.Position = g.PointAt(9)
+	if !g.rolledDice(p, 3) { t.Fatal("expected move") }
+	// square 12 is on the second row, counted from the right
+	if p.Position.Square() != 12 || p.Position.x != 1 || p.Position.y != 8 {
+		t.Fatalf("expected square 12 at (1,8), got square %d at (%d,%d)", p.Position.Square(), p.Position.x, p.Position.y)
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	if l, err := ParseLayout(""); err != nil || l != DefaultLayout { t.Fatalf("empty layout: %v %v", l, err) }
+	if l, err := ParseLayout("Row-Major"); err != nil || l != LayoutRowMajor { t.Fatalf("row-major: %v %v", l, err) }
+	if _, err := ParseLayout("spiral"); err == nil { t.Fatal("expected error for unknown layout") }
+}
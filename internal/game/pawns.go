@@ -0,0 +1,133 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+// PendingMove is a roll waiting for its player to pick which pawn to move.
+// After Deadline the server moves the most advanced pawn.
+type PendingMove struct {
+	Player   string    `json:"player"`
+	Roll     int       `json:"roll"`
+	Pawns    []int     `json:"pawns"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// pawnCount is the number of pawns p moves.
+func (p *Player) pawnCount() int {
+	if len(p.Pawns) == 0 { return 1 }
+	return len(p.Pawns)
+}
+
+// pawn returns pawn i. Single pawn players keep their pawn in Position.
+func (p *Player) pawn(i int) *Point {
+	if len(p.Pawns) == 0 { return &p.Position }
+	return &p.Pawns[i]
+}
+
+// lead returns the index of the most advanced pawn.
+func (p *Player) lead() int {
+	best := 0
+	for i := 1; i < p.pawnCount(); i++ {
+		if p.pawn(i).totalPos > p.pawn(best).totalPos { best = i }
+	}
+	return best
+}
+
+// sync points Position at the most advanced pawn.
+func (p *Player) sync() {
+	if len(p.Pawns) > 0 { p.Position = p.Pawns[p.lead()] }
+}
+
+// clone copies p without sharing the pawn slice.
+func (p Player) clone() Player {
+	p.Pawns = append([]Point(nil), p.Pawns...)
+	return p
+}
+
+// finished reports whether all of p's pawns reached the final square.
+func (g *Game) finished(p *Player) bool {
+	for i := 0; i < p.pawnCount(); i++ {
+		if p.pawn(i).totalPos != g.lastPos() { return false }
+	}
+	return true
+}
+
+// movablePawns lists the pawns that can move n squares. Pawns sharing a
+// square are interchangeable, so only the first of them is offered.
+func (g *Game) movablePawns(p *Player, n int) []int {
+	var out []int
+	seen := make(map[int]struct{})
+	for i := 0; i < p.pawnCount(); i++ {
+		pos := p.pawn(i).totalPos
+		if pos+n > g.lastPos() { continue }
+		if _, dup := seen[pos]; dup { continue }
+		seen[pos] = struct{}{}
+		out = append(out, i)
+	}
+	return out
+}
+
+// MovePawn moves the chosen pawn for the roll waiting on a choice.
+func (g *Game) MovePawn(pawn int) (Move, *string, error) {
+	g.mu.Lock()
+	pm := g.pending
+	if pm == nil {
+		g.mu.Unlock()
+		return Move{}, nil, ErrNoPawnChoice
+	}
+	ok := false
+	for _, i := range pm.Pawns {
+		if i == pawn { ok = true }
+	}
+	if !ok {
+		g.mu.Unlock()
+		return Move{}, nil, ErrPawnCannotMove.with(map[string]interface{}{"pawn": pawn, "roll": pm.Roll}, "pawn %d cannot move %d", pawn, pm.Roll)
+	}
+	m := g.play(pawn, pm.Roll)
+	winner := g.winner
+	g.mu.Unlock()
+	g.broadcast()
+	return m, winner, nil
+}
+
+// awaitChoice parks the roll until the player picks a pawn, arming the
+// deadline. Called with g.mu held.
+func (g *Game) awaitChoice(p *Player, n int, choices []int) Move {
+	wait := time.Duration(g.rules.MoveSeconds) * time.Second
+	pm := &PendingMove{Player: p.Name, Roll: n, Pawns: choices, Deadline: time.Now().Add(wait)}
+	g.pending = pm
+	g.pendingTimer = time.AfterFunc(wait, func() { g.autoMove(pm) })
+	return Move{Player: p.Name, Roll: n, From: p.Position.Square(), Choices: choices}
+}
+
+// autoMove plays the most advanced movable pawn once a choice times out. A
+// shared game is locked first, which also brings it up to date; while
+// another server holds the lock, it tries again a second later.
+func (g *Game) autoMove(pm *PendingMove) {
+	g.mu.Lock()
+	sh := g.shared
+	g.mu.Unlock()
+	if sh != nil {
+		unlock, err := sh.reg.Lock(sh.id)
+		if err != nil {
+			if errors.Is(err, ErrGameBusy) { time.AfterFunc(time.Second, func() { g.autoMove(pm) }) }
+			return
+		}
+		defer func() { _ = unlock() }()
+	}
+	g.mu.Lock()
+	if g.pending != pm {
+		g.mu.Unlock()
+		return
+	}
+	p := &g.players[g.turnIndex]
+	pick := pm.Pawns[0]
+	for _, i := range pm.Pawns {
+		if p.pawn(i).totalPos > p.pawn(pick).totalPos { pick = i }
+	}
+	g.play(pick, pm.Roll)
+	g.mu.Unlock()
+	g.broadcast()
+}
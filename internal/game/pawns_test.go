@@ -0,0 +1,74 @@
+package game
+
+import "testing"
+
+var pawnBoard = BoardDef{Width: 10, Height: 10, Snakes: []Jump{{From: 99, To: 2}}}
+
+func TestPlayersGetPawns(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 3}, "A", "B")
+	st := g.State()
+	if st.Rules.Pawns != 3 || st.Rules.MoveSeconds != defaultMoveSeconds { t.Fatalf("unexpected rules %+v", st.Rules) }
+	for _, p := range st.Players {
+		if len(p.Pawns) != 3 { t.Fatalf("expected 3 pawns, got %d", len(p.Pawns)) }
+	}
+	if err := g.SetRules(Rules{Pawns: 2}); err == nil { t.Fatal("rules should be fixed once players joined") }
+	if _, err := New(10).Rules().normalize(); err != nil { t.Fatalf("default rules: %v", err) }
+	if err := New(10).SetRules(Rules{Pawns: 5}); err == nil { t.Fatal("expected error for 5 pawns") }
+}
+
+func TestRollWaitsForPawnChoice(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 2}, "A", "B")
+	a := &g.players[0]
+	a.Pawns[1] = g.PointAt(20)
+	m, _, err := g.Roll()
+	if err != nil { t.Fatalf("roll: %v", err) }
+	if len(m.Choices) != 2 { t.Fatalf("expected a choice between 2 pawns, got %+v", m) }
+	st := g.State()
+	if st.Pending == nil || st.Pending.Player != "A" || st.TurnIndex != 0 { t.Fatalf("expected pending choice for A, got %+v", st.Pending) }
+	if _, _, err := g.Roll(); err == nil { t.Fatal("rolling again before choosing should fail") }
+	if _, _, err := g.MovePawn(3); err == nil { t.Fatal("expected error for unknown pawn") }
+	done, _, err := g.MovePawn(1)
+	if err != nil { t.Fatalf("move pawn: %v", err) }
+	if done.Pawn != 1 || done.From != 20 || done.Landed != 20+m.Roll { t.Fatalf("unexpected move %+v", done) }
+	if a.Pawns[0].Square() != 0 || a.Position.Square() != a.Pawns[1].Square() { t.Fatalf("position should follow the lead pawn") }
+	st = g.State()
+	if st.Pending != nil || st.TurnIndex != 1 { t.Fatalf("choice should clear and pass the turn, pending=%+v turn=%d", st.Pending, st.TurnIndex) }
+	if _, _, err := g.MovePawn(0); err == nil { t.Fatal("expected error without a pending roll") }
+}
+
+func TestPawnChoiceDeadlinePicksLeader(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 2}, "A", "B")
+	a := &g.players[0]
+	a.Pawns[1] = g.PointAt(40)
+	g.mu.Lock()
+	g.awaitChoice(a, 3, []int{0, 1})
+	pm := g.pending
+	g.mu.Unlock()
+	g.autoMove(pm)
+	if a.Pawns[1].Square() != 43 || a.Pawns[0].Square() != 0 { t.Fatalf("expected the lead pawn to move, got %d and %d", a.Pawns[0].Square(), a.Pawns[1].Square()) }
+	if g.State().Pending != nil { t.Fatal("pending roll should be cleared") }
+	// a stale timer must not play again
+	g.autoMove(pm)
+	if a.Pawns[1].Square() != 43 || len(g.History()) != 1 { t.Fatal("stale deadline moved a pawn") }
+}
+
+func TestSinglePawnChoiceMovesStraightAway(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 2}, "A", "B")
+	// both pawns at the start are interchangeable
+	m, _, err := g.Roll()
+	if err != nil { t.Fatalf("roll: %v", err) }
+	if len(m.Choices) != 0 || !m.Moved || g.State().Pending != nil { t.Fatalf("expected an immediate move, got %+v", m) }
+}
+
+func TestWinNeedsAllPawnsHome(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 2}, "A", "B")
+	a := &g.players[0]
+	a.Pawns[0] = g.PointAt(100)
+	a.Pawns[1] = g.PointAt(97)
+	a.sync()
+	if g.finished(a) { t.Fatal("one pawn home is not a win") }
+	g.mu.Lock()
+	g.play(1, 3)
+	g.mu.Unlock()
+	if w := g.State().Winner; w == nil || *w != "A" { t.Fatalf("expected A to win, got %v", w) }
+}
@@ -0,0 +1,90 @@
+package game
+
+// CollisionRule decides what happens when a pawn ends its move on a square
+// held by an opponent.
+type CollisionRule string
+
+const (
+	// CollisionIgnore lets pawns share squares.
+	CollisionIgnore CollisionRule = "ignore"
+	// CollisionCapture sends the opponent's pawn back to the start.
+	CollisionCapture CollisionRule = "capture"
+	// CollisionSwap sends the opponent's pawn to the square the mover left.
+	CollisionSwap CollisionRule = "swap"
+)
+
+// Rules are the optional variations a game is played with. The zero value
+// plays the classic game.
+type Rules struct {
+	// Pawns is the number of pawns each player moves, 1 (classic) to 4.
+	Pawns int `json:"pawns,omitempty"`
+	// MoveSeconds is how long a player has to pick a pawn before the server
+	// picks one for them. Only used with more than one pawn.
+	MoveSeconds int `json:"moveSeconds,omitempty"`
+	// Collision is the rule for landing on an opponent, CollisionIgnore by
+	// default.
+	Collision CollisionRule `json:"collision,omitempty"`
+	// TeamWin decides when a team wins, TeamWinAny by default.
+	TeamWin TeamWinRule `json:"teamWin,omitempty"`
+	// Undo decides who may take back a move, UndoHost by default.
+	Undo UndoRule `json:"undo,omitempty"`
+}
+
+const (
+	maxPawns           = 4
+	defaultMoveSeconds = 30
+)
+
+// normalize fills in defaults and checks the rules are playable.
+func (r Rules) normalize() (Rules, error) {
+	if r.Pawns == 0 { r.Pawns = 1 }
+	if r.Pawns < 1 || r.Pawns > maxPawns {
+		return r, invalidRules("pawns must be between 1 and %d", maxPawns)
+	}
+	if r.MoveSeconds < 0 { return r, invalidRules("moveSeconds must not be negative") }
+	if r.Pawns > 1 && r.MoveSeconds == 0 { r.MoveSeconds = defaultMoveSeconds }
+	switch r.Collision {
+	case "":
+		r.Collision = CollisionIgnore
+	case CollisionIgnore, CollisionCapture, CollisionSwap:
+	default:
+		return r, invalidRules("unknown collision rule %q", r.Collision)
+	}
+	switch r.TeamWin {
+	case "":
+		r.TeamWin = TeamWinAny
+	case TeamWinAny, TeamWinAll:
+	default:
+		return r, invalidRules("unknown team win rule %q", r.TeamWin)
+	}
+	switch r.Undo {
+	case "":
+		r.Undo = UndoHost
+	case UndoHost, UndoVote, UndoOff:
+	default:
+		return r, invalidRules("unknown undo rule %q", r.Undo)
+	}
+	return r, nil
+}
+
+// SetRules changes the rules. It is only allowed before any player joins.
+func (g *Game) SetRules(r Rules) error {
+	r, err := r.normalize()
+	if err != nil { return err }
+	g.mu.Lock()
+	if len(g.players) > 0 {
+		g.mu.Unlock()
+		return ErrRulesLocked
+	}
+	g.rules = r
+	g.mu.Unlock()
+	g.broadcast()
+	return nil
+}
+
+// Rules returns the rules in effect.
+func (g *Game) Rules() Rules {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.rules
+}
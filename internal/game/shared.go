@@ -0,0 +1,218 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/broker"
+)
+
+// Shared registries
+//
+// Servers behind one load balancer share their games through a broker. Any
+// of them may answer for any game: it keeps a copy, brought up to date from
+// the copy stored in the broker before it is read (Load) or changed (Lock).
+// Changes are made under the game's lock in the broker, so one server at a
+// time changes a game, then stored and published to the streams of every
+// server.
+
+const (
+	keyPrefix = "snakeandladder:"
+	seqKey    = keyPrefix + "games"
+	// lockTTL bounds how long a server that died holding a game's lock keeps
+	// the others out; Lock waits for up to lockWait.
+	lockTTL  = 10 * time.Second
+	lockWait = 5 * time.Second
+)
+
+func gameKey(id string) string     { return keyPrefix + "game:" + id }
+func eventsTopic(id string) string { return gameKey(id) + ":events" }
+func lockKey(id string) string     { return gameKey(id) + ":lock" }
+
+// sharing is how a registry shares its games.
+type sharing struct {
+	b     broker.Broker
+	owner string
+	keep  time.Duration
+	locks atomic.Uint64 // numbers each lock taken, so a late unlock cannot free a newer one
+}
+
+// sharedGame ties a game to the shared registry holding it.
+type sharedGame struct {
+	reg *Registry
+	id  string
+	mu  sync.Mutex
+	err error // first failure to store the game since it was locked
+}
+
+// record is a game as stored in the broker.
+type record struct {
+	Rev        uint64   `json:"rev"`
+	MaxPlayers int      `json:"maxPlayers,omitempty"`
+	Game       Snapshot `json:"game"`
+}
+
+// Share makes r keep its games in b, shared with every server using b: new
+// games take their ids from b, every change is stored in b and sent to the
+// streams of all servers, Load picks up games and changes made elsewhere and
+// Lock lets one server at a time change a game. owner names this server in
+// locks. Games stored in b are dropped after keep without a change, or kept
+// when keep is 0. Call Share before adding games. The game limit, Expire
+// and Stats still count the games this server holds.
+func (r *Registry) Share(b broker.Broker, owner string, keep time.Duration) {
+	r.mu.Lock(); defer r.mu.Unlock()
+	r.bus = b
+	r.shared = &sharing{b: b, owner: owner, keep: keep}
+}
+
+func (r *Registry) sharing() *sharing {
+	r.mu.Lock(); defer r.mu.Unlock()
+	return r.shared
+}
+
+// store saves g as game id in the broker.
+func (r *Registry) store(id string, g *Game) error {
+	g.mu.Lock()
+	rec := record{Rev: g.rev, MaxPlayers: g.maxPlayers}
+	g.mu.Unlock()
+	rec.Game = g.Snapshot()
+	data, err := json.Marshal(rec)
+	if err != nil { return err }
+	sh := r.sharing()
+	return sh.b.Set(gameKey(id), data, sh.keep)
+}
+
+// Load is Get for registries that may be shared: it brings game id up to
+// date with the broker first, fetching it when this server does not hold it
+// yet. On a registry that is not shared it is Get.
+func (r *Registry) Load(id string) (*Game, bool, error) {
+	g, ok := r.Get(id)
+	sh := r.sharing()
+	if sh == nil { return g, ok, nil }
+	data, err := sh.b.Get(gameKey(id))
+	if err != nil { return nil, false, err }
+	if data == nil { return g, ok, nil }
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil { return nil, false, fmt.Errorf("stored game %s: %w", id, err) }
+	if !ok {
+		n, err := fromRecord(rec)
+		if err != nil { return nil, false, fmt.Errorf("stored game %s: %w", id, err) }
+		r.mu.Lock()
+		if g, ok = r.games[id]; !ok {
+			r.attach(id, n)
+			r.games[id] = n
+			r.mu.Unlock()
+			return n, true, nil
+		}
+		r.mu.Unlock()
+		n.stopTimer() // loaded twice at once; keep the first
+	}
+	if err := g.refresh(rec); err != nil { return nil, false, fmt.Errorf("stored game %s: %w", id, err) }
+	return g, true, nil
+}
+
+// Lock waits, up to lockWait, until this server holds the lock of game id,
+// then brings the game up to date as Load does. Call unlock once the change
+// is made; it also reports a failure to store the change. It fails with
+// ErrGameBusy when the lock stays taken. On a registry that is not shared it
+// does nothing.
+func (r *Registry) Lock(id string) (unlock func() error, err error) {
+	sh := r.sharing()
+	if sh == nil { return func() error { return nil }, nil }
+	key, token := lockKey(id), sh.owner+"/"+strconv.FormatUint(sh.locks.Add(1), 10)
+	deadline := time.Now().Add(lockWait)
+	for wait := time.Millisecond; ; wait = min(2*wait, 100*time.Millisecond) {
+		ok, err := sh.b.Lock(key, token, lockTTL)
+		if err != nil { return nil, err }
+		if ok { break }
+		if time.Now().After(deadline) {
+			return nil, ErrGameBusy.with(map[string]interface{}{"id": id}, "game %s is busy, try again", id)
+		}
+		time.Sleep(wait)
+	}
+	release := func() error { return sh.b.Unlock(key, token) }
+	g, ok, err := r.Load(id)
+	if err != nil { return nil, errors.Join(err, release()) }
+	if !ok { return release, nil }
+	g.mu.Lock()
+	s := g.shared
+	g.mu.Unlock()
+	if s == nil { return release, nil }
+	s.takeErr()
+	return func() error { return errors.Join(s.takeErr(), release()) }, nil
+}
+
+// save stores g after a change, keeping a failure for unlock to report.
+func (s *sharedGame) save(g *Game) {
+	if err := s.reg.store(s.id, g); err != nil {
+		s.mu.Lock()
+		if s.err == nil { s.err = err }
+		s.mu.Unlock()
+	}
+}
+
+func (s *sharedGame) takeErr() error {
+	s.mu.Lock(); defer s.mu.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// fromRecord creates the game stored in rec. A pending pawn choice keeps
+// its deadline.
+func fromRecord(rec record) (*Game, error) {
+	g, err := FromSnapshot(rec.Game)
+	if err != nil { return nil, err }
+	g.mu.Lock(); defer g.mu.Unlock()
+	g.rev, g.maxPlayers = rec.Rev, rec.MaxPlayers
+	if g.pending != nil && rec.Game.Pending != nil { g.armTimer(rec.Game.Pending.Deadline) }
+	return g, nil
+}
+
+// refresh takes over the stored game in rec when it is newer than g.
+func (g *Game) refresh(rec record) error {
+	g.mu.Lock()
+	current := rec.Rev <= g.rev
+	g.mu.Unlock()
+	if current { return nil }
+	n, err := fromRecord(rec)
+	if err != nil { return err }
+	n.stopTimer()
+	g.adopt(n)
+	return nil
+}
+
+// adopt replaces the state of g with that of n, a newer copy of the same
+// game. g keeps its streams and its place in the registry.
+func (g *Game) adopt(n *Game) {
+	g.mu.Lock(); defer g.mu.Unlock()
+	if n.rev <= g.rev { return } // refreshed meanwhile
+	if g.pendingTimer != nil { g.pendingTimer.Stop() }
+	g.board, g.snakes, g.ladders, g.tiles = n.board, n.snakes, n.ladders, n.tiles
+	g.rules, g.dice, g.maxPlayers = n.rules, n.dice, n.maxPlayers
+	g.players, g.turnIndex, g.winner, g.winningTeam = n.players, n.turnIndex, n.winner, n.winningTeam
+	g.lastPlayed, g.lastRoll, g.lastMove = n.lastPlayed, n.lastRoll, n.lastMove
+	g.history, g.undo, g.undoVotes = n.history, n.undo, n.undoVotes
+	g.rev, g.updated = n.rev, time.Now()
+	g.pending, g.pendingTimer = n.pending, nil
+	if g.pending != nil { g.armTimer(g.pending.Deadline) }
+}
+
+// armTimer (re)starts the timer moving the pending pawn at deadline.
+// Called with g.mu held.
+func (g *Game) armTimer(deadline time.Time) {
+	if g.pendingTimer != nil { g.pendingTimer.Stop() }
+	pm := g.pending
+	pm.Deadline = deadline
+	g.pendingTimer = time.AfterFunc(time.Until(deadline), func() { g.autoMove(pm) })
+}
+
+func (g *Game) stopTimer() {
+	g.mu.Lock(); defer g.mu.Unlock()
+	if g.pendingTimer != nil { g.pendingTimer.Stop() }
+}
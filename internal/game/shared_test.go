@@ -0,0 +1,118 @@
+package game
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/broker"
+)
+
+// lockAnd changes game id on reg under its lock.
+func lockAnd(t *testing.T, reg *Registry, id string, change func(g *Game)) {
+	t.Helper()
+	unlock, err := reg.Lock(id)
+	if err != nil { t.Fatalf("lock: %v", err) }
+	g, ok, err := reg.Load(id)
+	if !ok || err != nil { t.Fatalf("load %s: %v %v", id, ok, err) }
+	change(g)
+	if err := unlock(); err != nil { t.Fatalf("unlock: %v", err) }
+}
+
+func TestSharedRegistries(t *testing.T) {
+	b := broker.NewLocal()
+	a, c := NewRegistry(), NewRegistry()
+	a.Share(b, "a", 0)
+	c.Share(b, "c", 0)
+	id, err := a.Register(New(10))
+	if err != nil { t.Fatal(err) }
+	if other, _ := c.Register(New(10)); other == id { t.Fatalf("both servers created game %s", id) }
+
+	// c picks the game up and streams it
+	gc, ok, err := c.Load(id)
+	if !ok || err != nil { t.Fatalf("load on the other server: %v %v", ok, err) }
+	if len(gc.State().Snakes) == 0 { t.Fatal("board not shared") }
+	sub, _ := gc.bus.Subscribe(gc.topic)
+	defer sub.Close()
+
+	lockAnd(t, a, id, func(g *Game) { _ = g.AddPlayer("Arun") })
+	select {
+	case m := <-sub.C:
+		if !strings.Contains(string(m), "Arun") { t.Fatalf("unexpected event %q", m) }
+	case <-time.After(2 * time.Second):
+		t.Fatal("change on one server not streamed by the other")
+	}
+	lockAnd(t, c, id, func(g *Game) {
+		if len(g.State().Players) != 1 { t.Fatal("lock did not bring the game up to date") }
+		_ = g.AddPlayer("Megha")
+	})
+	var first, second Move
+	lockAnd(t, a, id, func(g *Game) { first, _, _ = g.Roll() })
+	lockAnd(t, c, id, func(g *Game) { second, _, _ = g.Roll() })
+	ga, _, _ := a.Load(id)
+	st := ga.State()
+	if len(st.Players) != 2 || st.Moves != 2 || st.LastRoll != second.Roll { t.Fatalf("changes lost: %+v", st) }
+	if first.Player != "Arun" || second.Player != "Megha" { t.Fatalf("turns not shared: %s then %s", first.Player, second.Player) }
+
+	// the dice carry on from the same draws on either server
+	replay, _ := FromSnapshot(ga.Snapshot())
+	next, _, _ := replay.Roll()
+	lockAnd(t, c, id, func(g *Game) {
+		if m, _, _ := g.Roll(); m.Roll != next.Roll { t.Fatalf("dice diverged: %d, want %d", m.Roll, next.Roll) }
+	})
+}
+
+func TestSharedLockIsExclusive(t *testing.T) {
+	b := broker.NewLocal()
+	a, c := NewRegistry(), NewRegistry()
+	a.Share(b, "a", 0)
+	c.Share(b, "c", 0)
+	id, _ := a.Register(New(10))
+	unlock, err := a.Lock(id)
+	if err != nil { t.Fatal(err) }
+	locked := make(chan struct{})
+	go func() {
+		release, err := c.Lock(id)
+		if err != nil { t.Error(err) }
+		close(locked)
+		_ = release()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("two servers hold the game")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_ = unlock()
+	select {
+	case <-locked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("lock not handed over")
+	}
+}
+
+func TestSharedPendingChoiceKeepsDeadline(t *testing.T) {
+	b := broker.NewLocal()
+	a, c := NewRegistry(), NewRegistry()
+	a.Share(b, "a", 0)
+	c.Share(b, "c", 0)
+	g, _ := NewSeeded(&BoardDef{Width: 10, Height: 10, Snakes: []Jump{{From: 99, To: 2}}}, 1)
+	_ = g.SetRules(Rules{Pawns: 2, MoveSeconds: 1})
+	_ = g.AddPlayer("Arun")
+	_ = g.AddPlayer("Megha")
+	id, _ := a.Register(g)
+	// roll until a choice is pending: both pawns on the board
+	for g.State().Pending == nil {
+		lockAnd(t, a, id, func(g *Game) {
+			if st := g.State(); st.Pending == nil { _, _, _ = g.Roll() }
+		})
+	}
+	deadline := g.State().Pending.Deadline
+	gc, _, _ := c.Load(id)
+	if got := gc.State().Pending; got == nil || !got.Deadline.Equal(deadline) { t.Fatalf("pending choice not kept: %+v, want deadline %v", got, deadline) }
+	// one of the two timers plays the pawn, once
+	time.Sleep(time.Until(deadline) + 300*time.Millisecond)
+	ga, _, _ := a.Load(id)
+	gc, _, _ = c.Load(id)
+	if ga.State().Pending != nil || gc.State().Pending != nil { t.Fatal("pawn not moved at the deadline") }
+	if ga.State().Moves != gc.State().Moves { t.Fatalf("servers disagree: %d and %d moves", ga.State().Moves, gc.State().Moves) }
+}
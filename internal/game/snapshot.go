@@ -0,0 +1,235 @@
+package game
+
+import (
+	"strings"
+)
+
+// SnapshotVersion is the version of the snapshot format written by Snapshot.
+const SnapshotVersion = 1
+
+// MaxDraws bounds the dice draws replayed when importing a snapshot.
+const MaxDraws = 1 << 24
+
+// Snapshot is a complete copy of a game, enough to continue it elsewhere or
+// fork it. Positions are square numbers, 0 being the start.
+type Snapshot struct {
+	Version int       `json:"version"`
+	Board   BoardDef  `json:"board"`
+	Rules   Rules     `json:"rules"`
+	Dice    DiceState `json:"dice"`
+	SavedTurn
+	// Pending is a roll waiting for a pawn choice. Its deadline starts over
+	// when the snapshot is imported.
+	Pending   *PendingMove `json:"pending,omitempty"`
+	History   []Move       `json:"history"`
+	// Undo holds the states moves can be taken back to, oldest first.
+	Undo      []SavedTurn  `json:"undo,omitempty"`
+	UndoVotes []string     `json:"undoVotes,omitempty"`
+}
+
+// DiceState is the position of a game's dice: the seed and how many values
+// were drawn since.
+type DiceState struct {
+	Seed  int64  `json:"seed"`
+	Draws uint64 `json:"draws"`
+}
+
+// SavedTurn is the part of a game that changes from move to move. Moves is
+// the length of the history at that point and Draws, kept for the states
+// undo goes back to, how many values the dice had drawn.
+type SavedTurn struct {
+	Players     []SavedPlayer  `json:"players"`
+	TurnIndex   int            `json:"turnIndex"`
+	Winner      *string        `json:"winner,omitempty"`
+	WinningTeam *string        `json:"winningTeam,omitempty"`
+	LastPlayed  map[string]int `json:"lastPlayed,omitempty"`
+	LastRoll    int            `json:"lastRoll"`
+	LastMove    *Move          `json:"lastMove,omitempty"`
+	Moves       int            `json:"moves"`
+	Draws       uint64         `json:"draws,omitempty"`
+}
+
+// SavedPlayer is a player with the square of each of their pawns.
+type SavedPlayer struct {
+	Name      string `json:"name"`
+	Team      string `json:"team,omitempty"`
+	Pawns     []int  `json:"pawns"`
+	SkipTurns int    `json:"skipTurns,omitempty"`
+	Shield    bool   `json:"shield,omitempty"`
+}
+
+// Snapshot exports the game.
+func (g *Game) Snapshot() Snapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := Snapshot{
+		Version:   SnapshotVersion,
+		Board:     g.boardDef(),
+		Rules:     g.rules,
+		Dice:      DiceState{Seed: g.dice.seed, Draws: g.dice.draws},
+		SavedTurn: saveTurn(g.capture()),
+		History:   append([]Move{}, g.history...),
+		UndoVotes: append([]string(nil), g.undoVotes...),
+	}
+	if g.pending != nil {
+		pm := *g.pending
+		s.Pending = &pm
+	}
+	s.Draws = 0 // Dice has them
+	for _, u := range g.undo { s.Undo = append(s.Undo, saveTurn(u)) }
+	return s
+}
+
+// FromSnapshot creates a game from a snapshot. The new game shares nothing
+// with the one it was taken from, so importing a snapshot forks the game.
+func FromSnapshot(s Snapshot) (*Game, error) {
+	if s.Version != SnapshotVersion {
+		return nil, invalidSnapshot("unsupported snapshot version %d", s.Version)
+	}
+	if s.Dice.Draws > MaxDraws {
+		return nil, invalidSnapshot("dice draws %d exceed %d", s.Dice.Draws, MaxDraws)
+	}
+	b, err := s.Board.Board()
+	if err != nil { return nil, err }
+	g := newGame(b, s.Dice.Seed)
+	g.dice = restoreDice(s.Dice.Seed, s.Dice.Draws)
+	if err := g.placeJumps(s.Board.Snakes, s.Board.Ladders); err != nil { return nil, err }
+	if err := g.placeTiles(s.Board.Tiles); err != nil { return nil, err }
+	if g.rules, err = s.Rules.normalize(); err != nil { return nil, err }
+	g.history = append([]Move(nil), s.History...)
+	if s.Moves != len(g.history) {
+		return nil, invalidSnapshot("snapshot has %d moves but a history of %d", s.Moves, len(g.history))
+	}
+	cur, err := g.loadTurn(s.SavedTurn)
+	if err != nil { return nil, err }
+	for i, t := range s.Undo {
+		u, err := g.loadTurn(t)
+		if err != nil { return nil, invalidSnapshot("undo %d: %v", i+1, err) }
+		if u.moves > len(g.history) {
+			return nil, invalidSnapshot("undo %d is past the end of the history", i+1)
+		}
+		if u.draws > s.Dice.Draws {
+			return nil, invalidSnapshot("undo %d is past the dice draws", i+1)
+		}
+		g.undo = append(g.undo, u)
+	}
+	if len(g.undo) > maxUndo { g.undo = g.undo[len(g.undo)-maxUndo:] }
+	// undo takes back the last move of each state it leaves, or the roll
+	// waiting for a pawn choice
+	for i := range g.undo {
+		next := cur
+		if i+1 < len(g.undo) { next = g.undo[i+1] }
+		if next.lastMove == nil && !(i+1 == len(g.undo) && s.Pending != nil) {
+			return nil, invalidSnapshot("undo %d has no last move to take back", i+1)
+		}
+	}
+	g.apply(cur)
+	for _, v := range s.UndoVotes {
+		if g.playerIndex(v) < 0 { return nil, invalidSnapshot("undo vote from unknown player %q", v) }
+		g.undoVotes = append(g.undoVotes, v)
+	}
+	if pm := s.Pending; pm != nil {
+		if g.winner != nil || len(g.players) == 0 || !strings.EqualFold(pm.Player, g.players[g.turnIndex].Name) {
+			return nil, invalidSnapshot("pending move by %q is not for the current player", pm.Player)
+		}
+		p := &g.players[g.turnIndex]
+		choices := g.movablePawns(p, pm.Roll)
+		if pm.Roll < 1 || pm.Roll > 6 || len(choices) < 2 {
+			return nil, invalidSnapshot("pending roll of %d needs no pawn choice", pm.Roll)
+		}
+		g.awaitChoice(p, pm.Roll, choices)
+	}
+	return g, nil
+}
+
+// boardDef describes the board, snakes, ladders and tiles in use.
+func (g *Game) boardDef() BoardDef {
+	b := g.board
+	def := BoardDef{Width: b.width, Height: b.height, Layout: b.layout, Tiles: g.tileList()}
+	if !b.isGrid() {
+		def.Path = b.shape().Cells
+	}
+	for _, s := range g.snakes { def.Snakes = append(def.Snakes, Jump{From: s.head.Square(), To: s.tail.Square()}) }
+	for _, l := range g.ladders { def.Ladders = append(def.Ladders, Jump{From: l.bottom.Square(), To: l.top.Square()}) }
+	return def
+}
+
+func saveTurn(s snapshot) SavedTurn {
+	t := SavedTurn{
+		Players:     make([]SavedPlayer, len(s.players)),
+		TurnIndex:   s.turnIndex,
+		Winner:      s.winner,
+		WinningTeam: s.winningTeam,
+		LastPlayed:  s.lastPlayed,
+		LastRoll:    s.lastRoll,
+		LastMove:    s.lastMove,
+		Moves:       s.moves,
+		Draws:       s.draws,
+	}
+	for i, p := range s.players {
+		sp := SavedPlayer{Name: p.Name, Team: p.Team, SkipTurns: p.SkipTurns, Shield: p.Shield}
+		for j := 0; j < p.pawnCount(); j++ { sp.Pawns = append(sp.Pawns, p.pawn(j).Square()) }
+		t.Players[i] = sp
+	}
+	return t
+}
+
+// loadTurn checks a saved turn against the board and rules of g.
+func (g *Game) loadTurn(t SavedTurn) (snapshot, error) {
+	s := snapshot{
+		players:     make([]Player, len(t.Players)),
+		turnIndex:   t.TurnIndex,
+		winner:      t.Winner,
+		winningTeam: t.WinningTeam,
+		lastPlayed:  map[string]int{},
+		lastRoll:    t.LastRoll,
+		lastMove:    t.LastMove,
+		moves:       t.Moves,
+		draws:       t.Draws,
+	}
+	seen := make(map[string]struct{}, len(t.Players))
+	teams := 0
+	for i, sp := range t.Players {
+		key := strings.ToLower(sp.Name)
+		if strings.TrimSpace(sp.Name) == "" { return s, invalidSnapshot("player without a name") }
+		if checkName("name", sp.Name) != nil || (sp.Team != "" && checkName("team", sp.Team) != nil) { return s, invalidSnapshot("player %q has an invalid name or team", sp.Name) }
+		if _, dup := seen[key]; dup { return s, invalidSnapshot("duplicate player name %q", sp.Name) }
+		seen[key] = struct{}{}
+		if len(sp.Pawns) != g.rules.Pawns {
+			return s, invalidSnapshot("player %q has %d pawns, the rules give %d", sp.Name, len(sp.Pawns), g.rules.Pawns)
+		}
+		if sp.SkipTurns < 0 { return s, invalidSnapshot("player %q has negative skipped turns", sp.Name) }
+		if sp.Team != "" { teams++ }
+		p := Player{Name: sp.Name, Team: sp.Team, SkipTurns: sp.SkipTurns, Shield: sp.Shield}
+		if len(sp.Pawns) > 1 { p.Pawns = make([]Point, len(sp.Pawns)) }
+		for j, sq := range sp.Pawns {
+			if sq < 0 || sq > g.board.Squares() {
+				return s, invalidSnapshot("player %q has a pawn on square %d, outside 0..%d", sp.Name, sq, g.board.Squares())
+			}
+			*p.pawn(j) = g.pointAt(sq - 1)
+		}
+		p.sync()
+		s.players[i] = p
+	}
+	if teams != 0 && teams != len(t.Players) { return s, invalidSnapshot("either every player or none has a team") }
+	if t.TurnIndex < 0 || (len(t.Players) > 0 && t.TurnIndex >= len(t.Players)) || (len(t.Players) == 0 && t.TurnIndex != 0) {
+		return s, invalidSnapshot("turn index %d is out of range", t.TurnIndex)
+	}
+	if t.Moves < 0 { return s, invalidSnapshot("negative move count %d", t.Moves) }
+	if t.Winner != nil {
+		if _, ok := seen[strings.ToLower(*t.Winner)]; !ok { return s, invalidSnapshot("winner %q is not a player", *t.Winner) }
+	}
+	for k, v := range t.LastPlayed {
+		if v < 0 || v >= len(t.Players) { return s, invalidSnapshot("last player %d of team %q is out of range", v, k) }
+		s.lastPlayed[k] = v
+	}
+	return s, nil
+}
+
+// playerIndex finds a player by name, ignoring case, or returns -1.
+func (g *Game) playerIndex(name string) int {
+	for i := range g.players {
+		if strings.EqualFold(g.players[i].Name, name) { return i }
+	}
+	return -1
+}
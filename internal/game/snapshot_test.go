@@ -0,0 +1,84 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// roundTrip exports g through JSON and imports it again.
+func roundTrip(t *testing.T, g *Game) *Game {
+	t.Helper()
+	data, err := json.Marshal(g.Snapshot())
+	if err != nil { t.Fatalf("marshal: %v", err) }
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil { t.Fatalf("unmarshal: %v", err) }
+	h, err := FromSnapshot(s)
+	if err != nil { t.Fatalf("import: %v", err) }
+	return h
+}
+
+func TestSeededGamesMatch(t *testing.T) {
+	a, _ := NewSeeded(&BoardDef{Width: 10, Height: 10}, 42)
+	b, _ := NewSeeded(&BoardDef{Width: 10, Height: 10}, 42)
+	if !reflect.DeepEqual(a.boardDef(), b.boardDef()) { t.Fatal("same seed should generate the same board") }
+	if a.dice.roll() != b.dice.roll() { t.Fatal("same seed should roll the same") }
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	g, _ := NewSeeded(&BoardDef{Width: 10, Height: 10, Tiles: []Tile{{Square: 7, Effect: TileShield}}}, 7)
+	_ = g.AddPlayerToTeam("A", "red")
+	_ = g.AddPlayerToTeam("B", "blue")
+	for i := 0; i < 6; i++ {
+		if _, _, err := g.Roll(); err != nil { t.Fatalf("roll: %v", err) }
+	}
+	h := roundTrip(t, g)
+	if !reflect.DeepEqual(g.State(), h.State()) { t.Fatalf("state differs after import:\n%+v\n%+v", g.State(), h.State()) }
+	if !reflect.DeepEqual(g.History(), h.History()) { t.Fatal("history differs after import") }
+	// both copies carry on with the same dice
+	for i := 0; i < 6; i++ {
+		m1, _, _ := g.Roll()
+		m2, _, _ := h.Roll()
+		if !reflect.DeepEqual(m1, m2) { t.Fatalf("move %d differs: %+v vs %+v", i, m1, m2) }
+	}
+	// and undo still reaches back past the import
+	if _, err := h.Undo("A"); err != nil { t.Fatalf("undo after import: %v", err) }
+	if h.State().Moves != 11 { t.Fatalf("expected 11 moves after undo, got %d", h.State().Moves) }
+}
+
+func TestSnapshotOfGeneratedBoards(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		g, err := NewSeeded(&BoardDef{Width: 8, Height: 6, Layout: LayoutRowMajor}, seed)
+		if err != nil { t.Fatalf("seed %d: %v", seed, err) }
+		if _, err := FromSnapshot(g.Snapshot()); err != nil { t.Fatalf("seed %d: %v", seed, err) }
+	}
+}
+
+func TestSnapshotKeepsPendingChoice(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 2}, "A", "B")
+	g.players[0].Pawns[1] = g.PointAt(20)
+	if m, _, _ := g.Roll(); len(m.Choices) != 2 { t.Fatal("expected a pawn choice") }
+	h := roundTrip(t, g)
+	st := h.State()
+	if st.Pending == nil || st.Pending.Player != "A" { t.Fatalf("pending choice lost: %+v", st.Pending) }
+	if _, _, err := h.MovePawn(1); err != nil { t.Fatalf("move pawn after import: %v", err) }
+	if g.State().Pending == nil { t.Fatal("the original game should still wait for its choice") }
+}
+
+func TestFromSnapshotRejectsBadInput(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 1}, "A", "B")
+	for name, spoil := range map[string]func(*Snapshot){
+		"version":   func(s *Snapshot) { s.Version = 99 },
+		"square":    func(s *Snapshot) { s.Players[0].Pawns[0] = 101 },
+		"pawns":     func(s *Snapshot) { s.Players[0].Pawns = []int{0, 0} },
+		"duplicate": func(s *Snapshot) { s.Players[1].Name = "a" },
+		"turn":      func(s *Snapshot) { s.TurnIndex = 2 },
+		"moves":     func(s *Snapshot) { s.Moves = 3 },
+		"snake":     func(s *Snapshot) { s.Board.Snakes[0].To = 100 },
+		"draws":     func(s *Snapshot) { s.Dice.Draws = MaxDraws + 1 },
+	} {
+		s := g.Snapshot()
+		spoil(&s)
+		if _, err := FromSnapshot(s); err == nil { t.Errorf("%s: expected an error", name) }
+	}
+}
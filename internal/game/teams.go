@@ -0,0 +1,118 @@
+package game
+
+import "strings"
+
+// TeamWinRule decides when a team has won.
+type TeamWinRule string
+
+const (
+	// TeamWinAny: the team wins as soon as one member finishes.
+	TeamWinAny TeamWinRule = "any"
+	// TeamWinAll: every member has to finish; finished members sit out.
+	TeamWinAll TeamWinRule = "all"
+)
+
+// Team groups players who win together.
+type Team struct {
+	Name     string   `json:"name"`
+	Members  []string `json:"members"`
+	Finished []string `json:"finished,omitempty"`
+}
+
+// AddPlayerToTeam adds a player to the named team, creating it on first use.
+// A game is either played in teams or not at all, so every player needs a
+// team once the first one has one.
+func (g *Game) AddPlayerToTeam(name, team string) error {
+	team = strings.TrimSpace(team)
+	if team == "" { return ErrTeamRequired.with(nil, "team name required") }
+	return g.addPlayer(name, team)
+}
+
+// teamPlay reports whether players are grouped into teams.
+func (g *Game) teamPlay() bool { return len(g.players) > 0 && g.players[0].Team != "" }
+
+// teamNames lists the teams in the order they were formed.
+func (g *Game) teamNames() []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, p := range g.players {
+		key := strings.ToLower(p.Team)
+		if _, ok := seen[key]; ok { continue }
+		seen[key] = struct{}{}
+		names = append(names, p.Team)
+	}
+	return names
+}
+
+// teamIndex returns the index of the team in teamNames.
+func (g *Game) teamIndex(team string) int {
+	for i, name := range g.teamNames() {
+		if strings.EqualFold(name, team) { return i }
+	}
+	return -1
+}
+
+// teamMembers returns the indexes of the team's players, in joining order.
+func (g *Game) teamMembers(team string) []int {
+	var out []int
+	for i, p := range g.players {
+		if strings.EqualFold(p.Team, team) { out = append(out, i) }
+	}
+	return out
+}
+
+// teammates reports whether p and q play for the same team.
+func teammates(p, q *Player) bool { return p.Team != "" && strings.EqualFold(p.Team, q.Team) }
+
+// nextPlayer picks who plays after the current player. Without teams that is
+// the next seat; with teams the turn goes to the next team, which sends its
+// members up in rotation, skipping members who already finished.
+func (g *Game) nextPlayer() int {
+	if !g.teamPlay() { return (g.turnIndex + 1) % len(g.players) }
+	teams := g.teamNames()
+	cur := g.teamIndex(g.players[g.turnIndex].Team)
+	for k := 1; k <= len(teams); k++ {
+		team := teams[(cur+k)%len(teams)]
+		members := g.teamMembers(team)
+		start := 0
+		if last, ok := g.lastPlayed[strings.ToLower(team)]; ok {
+			for i, idx := range members {
+				if idx == last { start = i + 1 }
+			}
+		}
+		for j := 0; j < len(members); j++ {
+			idx := members[(start+j)%len(members)]
+			if !g.finished(&g.players[idx]) { return idx }
+		}
+	}
+	return g.turnIndex
+}
+
+// markPlayed remembers that the player at idx took their team's turn.
+func (g *Game) markPlayed(idx int) {
+	if team := g.players[idx].Team; team != "" { g.lastPlayed[strings.ToLower(team)] = idx }
+}
+
+// teamWon reports whether p's finish wins the game for their team.
+func (g *Game) teamWon(p *Player) bool {
+	if g.rules.TeamWin != TeamWinAll { return true }
+	for _, idx := range g.teamMembers(p.Team) {
+		if !g.finished(&g.players[idx]) { return false }
+	}
+	return true
+}
+
+// teams describes the teams for State.
+func (g *Game) teams() []Team {
+	var out []Team
+	for _, name := range g.teamNames() {
+		t := Team{Name: name}
+		for _, idx := range g.teamMembers(name) {
+			p := &g.players[idx]
+			t.Members = append(t.Members, p.Name)
+			if g.finished(p) { t.Finished = append(t.Finished, p.Name) }
+		}
+		out = append(out, t)
+	}
+	return out
+}
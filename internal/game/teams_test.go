@@ -0,0 +1,86 @@
+package game
+
+import "testing"
+
+var (
+	teamBoard   = BoardDef{Width: 10, Height: 10, Ladders: []Jump{{From: 2, To: 3}}}
+	teamPlayers = []string{"A1:Red", "A2:red", "B1:Blue", "A3:Red", "B2:Blue"}
+)
+
+func TestTeamsAlternateTurns(t *testing.T) {
+	g := newTestGame(t, teamBoard, Rules{TeamWin: TeamWinAny}, teamPlayers...)
+	var order []string
+	for i := 0; i < 8; i++ {
+		order = append(order, g.players[g.turnIndex].Name)
+		g.mu.Lock()
+		g.play(0, 1)
+		g.mu.Unlock()
+	}
+	want := []string{"A1", "B1", "A2", "B2", "A3", "B1", "A1", "B2"}
+	for i := range want {
+		if order[i] != want[i] { t.Fatalf("turn order %v, want %v", order, want) }
+	}
+	st := g.State()
+	if len(st.Teams) != 2 || st.Teams[0].Name != "Red" || len(st.Teams[0].Members) != 3 || st.Players[1].Team != "Red" {
+		t.Fatalf("unexpected teams %+v", st.Teams)
+	}
+}
+
+func TestTeamWinsWithAnyMember(t *testing.T) {
+	g := newTestGame(t, teamBoard, Rules{TeamWin: TeamWinAny}, teamPlayers...)
+	g.players[0].Position = g.PointAt(99)
+	g.mu.Lock()
+	g.play(0, 1)
+	g.mu.Unlock()
+	st := g.State()
+	if st.Winner == nil || *st.Winner != "A1" || st.WinningTeam == nil || *st.WinningTeam != "Red" {
+		t.Fatalf("expected Red to win through A1, got %v %v", st.Winner, st.WinningTeam)
+	}
+}
+
+func TestTeamNeedsAllMembers(t *testing.T) {
+	g := newTestGame(t, teamBoard, Rules{TeamWin: TeamWinAll}, teamPlayers...)
+	g.players[2].Position = g.PointAt(100) // B1 already home
+	g.players[4].Position = g.PointAt(99)
+	g.turnIndex = 4
+	g.mu.Lock()
+	g.play(0, 1)
+	g.mu.Unlock()
+	st := g.State()
+	if st.WinningTeam == nil || *st.WinningTeam != "Blue" || *st.Winner != "B2" { t.Fatalf("expected Blue to win, got %v", st.WinningTeam) }
+
+	g = newTestGame(t, teamBoard, Rules{TeamWin: TeamWinAll}, teamPlayers...)
+	g.players[2].Position = g.PointAt(99)
+	g.turnIndex = 2
+	g.mu.Lock()
+	g.play(0, 1)
+	g.mu.Unlock()
+	if g.State().Winner != nil { t.Fatal("Blue still has a member on the board") }
+	// finished B1 sits out; B2 plays for Blue from now on
+	g.turnIndex = 0
+	g.mu.Lock()
+	g.play(0, 1)
+	g.mu.Unlock()
+	if g.players[g.turnIndex].Name != "B2" { t.Fatalf("expected B2 to play for Blue, got %s", g.players[g.turnIndex].Name) }
+	if st := g.State(); len(st.Teams[1].Finished) != 1 { t.Fatalf("expected B1 finished, got %+v", st.Teams[1]) }
+}
+
+func TestTeamsAreAllOrNothing(t *testing.T) {
+	g := newTestGame(t, teamBoard, Rules{TeamWin: TeamWinAny}, teamPlayers...)
+	if err := g.AddPlayer("Solo"); err == nil { t.Fatal("expected error adding a player without a team") }
+	g = New(10)
+	_ = g.AddPlayer("Solo")
+	if err := g.AddPlayerToTeam("A", "Red"); err == nil { t.Fatal("expected error adding a team player to a solo game") }
+	g = New(10)
+	_ = g.AddPlayerToTeam("A", "Red")
+	_ = g.AddPlayerToTeam("B", "Red")
+	if _, _, err := g.Roll(); err == nil { t.Fatal("expected error with a single team") }
+}
+
+func TestTeammatesDoNotCollide(t *testing.T) {
+	g := newTestGame(t, teamBoard, Rules{TeamWin: TeamWinAny, Collision: CollisionCapture}, teamPlayers...)
+	g.players[1].Position = g.PointAt(14)
+	g.players[0].Position = g.PointAt(10)
+	g.move(&g.players[0], 0, 4)
+	if g.players[1].Position.Square() != 14 { t.Fatal("teammate should not be captured") }
+}
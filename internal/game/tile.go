@@ -0,0 +1,241 @@
+package game
+
+// TileEffect is what a special square does to the pawn that ends its move on it.
+type TileEffect string
+
+const (
+	// TileTeleport moves the pawn to Tile.Target.
+	TileTeleport TileEffect = "teleport"
+	// TileSkipTurn makes the player lose their next turn.
+	TileSkipTurn TileEffect = "skip-turn"
+	// TileExtraRoll lets the player roll again straight away.
+	TileExtraRoll TileEffect = "extra-roll"
+	// TileSwapLeader swaps the pawn with the player furthest ahead.
+	TileSwapLeader TileEffect = "swap-leader"
+	// TileShield protects the player from the next snake bite.
+	TileShield TileEffect = "shield"
+)
+
+// Tile is a special square. Target is only used by TileTeleport.
+type Tile struct {
+	Square int        `json:"square"`
+	Effect TileEffect `json:"effect"`
+	Target int        `json:"target,omitempty"`
+}
+
+// StepKind names one thing that happened during a move.
+type StepKind string
+
+const (
+	StepSnake      StepKind = "snake"
+	StepLadder     StepKind = "ladder"
+	StepTeleport   StepKind = "teleport"
+	StepShield     StepKind = "shield"      // a shield absorbed a snake bite
+	StepGainShield StepKind = "gain-shield" // the pawn picked up a shield
+	StepSkipTurn   StepKind = "skip-turn"   // the player will lose their next turn
+	StepExtraRoll  StepKind = "extra-roll"
+	StepSwap       StepKind = "swap"
+	StepSkipped    StepKind = "skipped" // Player lost this turn
+	StepCapture    StepKind = "capture" // Player's pawn was sent back to the start
+	StepBump       StepKind = "bump"    // Player's pawn was sent to the mover's old square
+)
+
+// Step is one transition of a move, in order. From and To are square numbers;
+// Player and Pawn name the other player's pawn involved, if any.
+type Step struct {
+	Kind   StepKind `json:"kind"`
+	From   int      `json:"from,omitempty"`
+	To     int      `json:"to,omitempty"`
+	Player string   `json:"player,omitempty"`
+	Pawn   int      `json:"pawn,omitempty"`
+}
+
+// Move is the outcome of one roll. From, Landed and To are square numbers, 0
+// being the start: Landed is where the dice took the pawn and To where it
+// ended after Steps.
+type Move struct {
+	Player string `json:"player"`
+	Pawn   int    `json:"pawn"`
+	Roll   int    `json:"roll"`
+	From   int    `json:"from"`
+	Landed int    `json:"landed"`
+	To     int    `json:"to"`
+	// Moved is false when the roll would overshoot the final square.
+	Moved bool   `json:"moved"`
+	Steps []Step `json:"steps,omitempty"`
+	// Choices lists the pawns the player may move when the roll is waiting
+	// for a choice; such a move has not been played yet.
+	Choices []int `json:"choices,omitempty"`
+}
+
+// placeTiles installs special squares. Tiles may not share a square with each
+// other, a snake head or a ladder bottom, nor sit on the final square.
+func (g *Game) placeTiles(tiles []Tile) error {
+	squares := g.board.Squares()
+	taken := make(map[int]string)
+	for _, s := range g.snakes { taken[s.head.Square()] = "snake" }
+	for _, l := range g.ladders { taken[l.bottom.Square()] = "ladder" }
+	g.tiles = make(map[int]Tile, len(tiles))
+	for _, t := range tiles {
+		if t.Square < 1 || t.Square >= squares {
+			return invalidBoard("%s tile on square %d must be within squares 1..%d", t.Effect, t.Square, squares-1)
+		}
+		if other, ok := taken[t.Square]; ok {
+			return invalidBoard("%s tile on square %d clashes with a %s", t.Effect, t.Square, other)
+		}
+		switch t.Effect {
+		case TileTeleport:
+			if t.Target < 1 || t.Target > squares || t.Target == t.Square {
+				return invalidBoard("teleport on square %d has invalid target %d", t.Square, t.Target)
+			}
+		case TileSkipTurn, TileExtraRoll, TileSwapLeader, TileShield:
+			if t.Target != 0 { return invalidBoard("%s tile on square %d does not take a target", t.Effect, t.Square) }
+		default:
+			return invalidBoard("unknown tile effect %q on square %d", t.Effect, t.Square)
+		}
+		taken[t.Square] = string(t.Effect) + " tile"
+		g.tiles[t.Square-1] = t
+	}
+	return nil
+}
+
+// dropJumpsOn removes generated snakes and ladders that start on a tile.
+func (g *Game) dropJumpsOn(tiles []Tile) {
+	on := make(map[int]struct{}, len(tiles))
+	for _, t := range tiles { on[t.Square] = struct{}{} }
+	snakes := g.snakes[:0]
+	for _, s := range g.snakes {
+		if _, ok := on[s.head.Square()]; !ok { snakes = append(snakes, s) }
+	}
+	g.snakes = snakes
+	ladders := g.ladders[:0]
+	for _, l := range g.ladders {
+		if _, ok := on[l.bottom.Square()]; !ok { ladders = append(ladders, l) }
+	}
+	g.ladders = ladders
+}
+
+// tileList returns the tiles ordered by square.
+func (g *Game) tileList() []Tile {
+	out := make([]Tile, 0, len(g.tiles))
+	for i := 0; i < g.board.Squares(); i++ {
+		if t, ok := g.tiles[i]; ok { out = append(out, t) }
+	}
+	return out
+}
+
+// move rolls n for pawn of p, applying snakes, ladders and tiles, and reports
+// what happened. Turn effects (skip, extra roll) are recorded on the player
+// and in the steps; advancing the turn is left to the caller.
+func (g *Game) move(p *Player, pawn, n int) Move {
+	pos := p.pawn(pawn)
+	defer p.sync()
+	m := Move{Player: p.Name, Pawn: pawn, Roll: n, From: pos.Square()}
+	var total int
+	if pos.totalPos >= 0 {
+		total = pos.totalPos + n
+	} else {
+		total = n - 1
+	}
+	if total > g.lastPos() {
+		m.Landed, m.To = m.From, m.From
+		return m
+	}
+	m.Moved = true
+	m.Landed = total + 1
+	// Apply chained effects: ladder->ladder, snake->snake, teleports or mixed
+	// sequences. Continue until no more transitions apply, capped by a safe guard.
+	for guard := 0; guard < g.board.Squares(); guard++ {
+		if ok, f := g.hitBySnake(total); ok {
+			if p.Shield {
+				p.Shield = false
+				m.Steps = append(m.Steps, Step{Kind: StepShield, From: total + 1, To: total + 1})
+				break
+			}
+			to := f()
+			m.Steps = append(m.Steps, Step{Kind: StepSnake, From: total + 1, To: to + 1})
+			total = to
+			continue
+		}
+		if ok, f := g.gotElevated(total); ok {
+			to := f()
+			m.Steps = append(m.Steps, Step{Kind: StepLadder, From: total + 1, To: to + 1})
+			total = to
+			continue
+		}
+		if t, ok := g.tiles[total]; ok && t.Effect == TileTeleport {
+			m.Steps = append(m.Steps, Step{Kind: StepTeleport, From: total + 1, To: t.Target})
+			total = t.Target - 1
+			continue
+		}
+		break
+	}
+	*pos = g.pointAt(total)
+	if t, ok := g.tiles[total]; ok {
+		switch t.Effect {
+		case TileSkipTurn:
+			p.SkipTurns++
+			m.Steps = append(m.Steps, Step{Kind: StepSkipTurn, From: t.Square, To: t.Square})
+		case TileExtraRoll:
+			m.Steps = append(m.Steps, Step{Kind: StepExtraRoll, From: t.Square, To: t.Square})
+		case TileShield:
+			p.Shield = true
+			m.Steps = append(m.Steps, Step{Kind: StepGainShield, From: t.Square, To: t.Square})
+		case TileSwapLeader:
+			if leader := g.leaderOtherThan(p); leader != nil && leader.Position.totalPos > pos.totalPos {
+				theirs := leader.pawn(leader.lead())
+				m.Steps = append(m.Steps, Step{Kind: StepSwap, From: pos.Square(), To: theirs.Square(), Player: leader.Name})
+				*pos, *theirs = *theirs, *pos
+				leader.sync()
+			}
+		}
+	}
+	m.Steps = append(m.Steps, g.collide(p, pos, m.From)...)
+	m.To = pos.Square()
+	return m
+}
+
+// collide applies the collision rule to opponents' pawns sharing pos. from is
+// the square the mover started on. Pawns that reached the final square are
+// safe.
+func (g *Game) collide(p *Player, pos *Point, from int) []Step {
+	if g.rules.Collision == CollisionIgnore || pos.totalPos < 0 || pos.totalPos == g.lastPos() { return nil }
+	var steps []Step
+	for i := range g.players {
+		q := &g.players[i]
+		if q == p || teammates(p, q) { continue }
+		for j := 0; j < q.pawnCount(); j++ {
+			theirs := q.pawn(j)
+			if theirs.totalPos != pos.totalPos { continue }
+			switch g.rules.Collision {
+			case CollisionCapture:
+				*theirs = g.pointAt(-1)
+				steps = append(steps, Step{Kind: StepCapture, From: pos.Square(), To: 0, Player: q.Name, Pawn: j})
+			case CollisionSwap:
+				*theirs = g.pointAt(from - 1)
+				steps = append(steps, Step{Kind: StepBump, From: pos.Square(), To: from, Player: q.Name, Pawn: j})
+			}
+		}
+		q.sync()
+	}
+	return steps
+}
+
+// leaderOtherThan returns the player furthest along the board, ignoring p.
+func (g *Game) leaderOtherThan(p *Player) *Player {
+	var leader *Player
+	for i := range g.players {
+		q := &g.players[i]
+		if q == p || teammates(p, q) { continue }
+		if leader == nil || q.Position.totalPos > leader.Position.totalPos { leader = q }
+	}
+	return leader
+}
+
+// extraRoll reports whether the move earned another roll.
+func (m Move) extraRoll() bool {
+	for _, s := range m.Steps {
+		if s.Kind == StepExtraRoll { return true }
+	}
+	return false
+}
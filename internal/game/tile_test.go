@@ -0,0 +1,105 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+// tileBoard is a board with one snake and one ladder and the tiles given as
+// JSON.
+func tileBoard(t *testing.T, tiles string) BoardDef {
+	t.Helper()
+	def, err := ParseBoardDef(strings.NewReader(`{"width": 10, "height": 10,
+		"snakes": [{"from": 40, "to": 5}], "ladders": [{"from": 60, "to": 90}],
+		"tiles": ` + tiles + `}`))
+	if err != nil { t.Fatalf("parse: %v", err) }
+	return *def
+}
+
+func TestTeleportChainsIntoLadder(t *testing.T) {
+	g := newTestGame(t, tileBoard(t, `[{"square": 20, "effect": "teleport", "target": 60}]`), Rules{}, "A", "B", "C")
+	p := &g.players[0]
+	p.Position = g.PointAt(17)
+	m := g.move(p, 0, 3)
+	if p.Position.Square() != 90 { t.Fatalf("expected teleport then ladder to 90, got %d", p.Position.Square()) }
+	if len(m.Steps) != 2 || m.Steps[0].Kind != StepTeleport || m.Steps[1].Kind != StepLadder {
+		t.Fatalf("unexpected steps %+v", m.Steps)
+	}
+	if m.From != 17 || m.Landed != 20 || m.To != 90 { t.Fatalf("unexpected move %+v", m) }
+}
+
+func TestShieldBlocksNextSnake(t *testing.T) {
+	g := newTestGame(t, tileBoard(t, `[{"square": 30, "effect": "shield"}]`), Rules{}, "A", "B", "C")
+	p := &g.players[0]
+	p.Position = g.PointAt(28)
+	if m := g.move(p, 0, 2); !p.Shield || m.Steps[0].Kind != StepGainShield { t.Fatalf("expected shield, got %+v", m) }
+	p.Position = g.PointAt(38)
+	m := g.move(p, 0, 2)
+	if p.Position.Square() != 40 || p.Shield { t.Fatalf("shield should block the bite once, at %d shield=%v", p.Position.Square(), p.Shield) }
+	if m.Steps[0].Kind != StepShield { t.Fatalf("expected shield step, got %+v", m.Steps) }
+	p.Position = g.PointAt(38)
+	g.move(p, 0, 2)
+	if p.Position.Square() != 5 { t.Fatalf("second bite should land on 5, got %d", p.Position.Square()) }
+}
+
+func TestSkipTurn(t *testing.T) {
+	g := newTestGame(t, tileBoard(t, `[{"square": 12, "effect": "skip-turn"}]`), Rules{}, "A", "B", "C")
+	b := &g.players[1]
+	b.Position = g.PointAt(10)
+	g.turnIndex = 1
+	m := g.move(b, 0, 2)
+	g.advanceTurn(&m)
+	if g.turnIndex != 2 { t.Fatalf("expected C to play next, got %d", g.turnIndex) }
+	m = g.move(&g.players[2], 0, 1)
+	g.advanceTurn(&m)
+	if g.turnIndex != 0 { t.Fatalf("expected A to play next, got %d", g.turnIndex) }
+	m = g.move(&g.players[0], 0, 1)
+	g.advanceTurn(&m)
+	// B lost this turn
+	if g.turnIndex != 2 { t.Fatalf("expected B to be skipped, got turn %d", g.turnIndex) }
+	if last := m.Steps[len(m.Steps)-1]; last.Kind != StepSkipped || last.Player != "B" { t.Fatalf("expected skipped step, got %+v", m.Steps) }
+	if g.players[1].SkipTurns != 0 { t.Fatalf("skip should be used up") }
+}
+
+func TestExtraRollKeepsTurn(t *testing.T) {
+	g := newTestGame(t, tileBoard(t, `[{"square": 6, "effect": "extra-roll"}]`), Rules{}, "A", "B", "C")
+	p := &g.players[0]
+	if m := g.move(p, 0, 6); !m.extraRoll() { t.Fatalf("expected extra roll, got %+v", m) }
+}
+
+func TestSwapWithLeader(t *testing.T) {
+	g := newTestGame(t, tileBoard(t, `[{"square": 8, "effect": "swap-leader"}]`), Rules{}, "A", "B", "C")
+	g.players[1].Position = g.PointAt(70)
+	g.players[2].Position = g.PointAt(50)
+	p := &g.players[0]
+	p.Position = g.PointAt(5)
+	m := g.move(p, 0, 3)
+	if p.Position.Square() != 70 || g.players[1].Position.Square() != 8 {
+		t.Fatalf("expected swap with B, A=%d B=%d", p.Position.Square(), g.players[1].Position.Square())
+	}
+	if s := m.Steps[0]; s.Kind != StepSwap || s.Player != "B" || s.From != 8 || s.To != 70 { t.Fatalf("unexpected step %+v", s) }
+}
+
+func TestTileValidation(t *testing.T) {
+	cases := map[string]string{
+		"unknown effect":  `[{"square": 3, "effect": "boost"}]`,
+		"teleport target": `[{"square": 3, "effect": "teleport", "target": 101}]`,
+		"on snake":        `[{"square": 40, "effect": "shield"}]`,
+		"final square":    `[{"square": 100, "effect": "shield"}]`,
+		"twice":           `[{"square": 3, "effect": "shield"}, {"square": 3, "effect": "skip-turn"}]`,
+		"stray target":    `[{"square": 3, "effect": "shield", "target": 9}]`,
+	}
+	for name, tiles := range cases {
+		def, _ := ParseBoardDef(strings.NewReader(`{"width": 10, "height": 10, "snakes": [{"from": 40, "to": 5}], "tiles": ` + tiles + `}`))
+		if _, err := NewFromDef(def); err == nil { t.Errorf("%s: expected an error", name) }
+	}
+}
+
+func TestGeneratedBoardKeepsTiles(t *testing.T) {
+	def := &BoardDef{Width: 10, Height: 10, Tiles: []Tile{{Square: 15, Effect: TileExtraRoll}, {Square: 44, Effect: TileTeleport, Target: 2}}}
+	for i := 0; i < 20; i++ {
+		g, err := NewFromDef(def)
+		if err != nil { t.Fatalf("generated board with tiles: %v", err) }
+		if st := g.State(); len(st.Tiles) != 2 { t.Fatalf("expected 2 tiles, got %d", len(st.Tiles)) }
+	}
+}
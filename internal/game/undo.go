@@ -0,0 +1,142 @@
+package game
+
+import "strings"
+
+// UndoRule decides who may take back a move.
+type UndoRule string
+
+const (
+	// UndoHost lets the host, the first player to join, take back moves.
+	UndoHost UndoRule = "host"
+	// UndoVote takes a move back once every player asked for it.
+	UndoVote UndoRule = "vote"
+	// UndoOff disables undo.
+	UndoOff UndoRule = "off"
+)
+
+// maxUndo bounds how many moves can be taken back.
+const maxUndo = 50
+
+// snapshot is the mutable part of a game, taken before each move.
+type snapshot struct {
+	players     []Player
+	turnIndex   int
+	winner      *string
+	winningTeam *string
+	lastPlayed  map[string]int
+	lastRoll    int
+	lastMove    *Move
+	moves       int
+	// draws is how many values the dice had drawn, so the roll that
+	// follows comes out the same again
+	draws uint64
+}
+
+// UndoResult reports an undo request. Undone is the move taken back; it is
+// nil while a vote is still collecting Votes out of Needed.
+type UndoResult struct {
+	Undone *Move    `json:"undone,omitempty"`
+	Votes  []string `json:"votes,omitempty"`
+	Needed int      `json:"needed,omitempty"`
+}
+
+// save pushes the current state on the undo stack, before a roll. Called
+// with g.mu held.
+func (g *Game) save() {
+	g.undo = append(g.undo, g.capture())
+	if len(g.undo) > maxUndo { g.undo = g.undo[len(g.undo)-maxUndo:] }
+	g.undoVotes = nil
+}
+
+// capture copies the mutable state. Called with g.mu held.
+func (g *Game) capture() snapshot {
+	s := snapshot{
+		players:     make([]Player, len(g.players)),
+		turnIndex:   g.turnIndex,
+		winner:      g.winner,
+		winningTeam: g.winningTeam,
+		lastPlayed:  make(map[string]int, len(g.lastPlayed)),
+		lastRoll:    g.lastRoll,
+		lastMove:    g.lastMove,
+		moves:       len(g.history),
+		draws:       g.dice.draws,
+	}
+	for i, p := range g.players { s.players[i] = p.clone() }
+	for k, v := range g.lastPlayed { s.lastPlayed[k] = v }
+	return s
+}
+
+// Undo asks to take back the last move on behalf of player. With UndoHost only
+// the host may ask; with UndoVote the move is taken back once every player
+// asked. A roll waiting for a pawn choice is the move taken back: only it is
+// cancelled and the player rolls again. Undone moves leave the dice where
+// they were, so rolling again gives the same number.
+func (g *Game) Undo(player string) (UndoResult, error) {
+	g.mu.Lock()
+	var who *Player
+	for i := range g.players {
+		if strings.EqualFold(g.players[i].Name, player) { who = &g.players[i] }
+	}
+	switch {
+	case g.rules.Undo == UndoOff:
+		g.mu.Unlock()
+		return UndoResult{}, ErrUndoDisabled
+	case who == nil:
+		g.mu.Unlock()
+		return UndoResult{}, ErrUnknownPlayer.with(map[string]interface{}{"player": player}, "unknown player %q", player)
+	case len(g.undo) == 0 || (g.lastMove == nil && g.pending == nil):
+		g.mu.Unlock()
+		return UndoResult{}, ErrNothingToUndo
+	case g.rules.Undo == UndoHost && who != &g.players[0]:
+		g.mu.Unlock()
+		return UndoResult{}, ErrNotHost.with(map[string]interface{}{"host": g.players[0].Name}, "only the host (%s) can undo", g.players[0].Name)
+	}
+	if g.rules.Undo == UndoVote {
+		voted := false
+		for _, v := range g.undoVotes {
+			if v == who.Name { voted = true }
+		}
+		if !voted { g.undoVotes = append(g.undoVotes, who.Name) }
+		if len(g.undoVotes) < len(g.players) {
+			res := UndoResult{Votes: append([]string(nil), g.undoVotes...), Needed: len(g.players)}
+			g.mu.Unlock()
+			g.broadcast()
+			return res, nil
+		}
+	}
+	var undone Move
+	if pm := g.pending; pm != nil {
+		p := &g.players[g.turnIndex]
+		undone = Move{Player: pm.Player, Roll: pm.Roll, From: p.Position.Square(), Choices: pm.Pawns}
+	} else {
+		undone = *g.lastMove
+	}
+	g.restore()
+	g.mu.Unlock()
+	g.broadcastEvent("rollback", undone)
+	g.broadcast()
+	return UndoResult{Undone: &undone}, nil
+}
+
+// restore pops the undo stack. Called with g.mu held.
+func (g *Game) restore() {
+	s := g.undo[len(g.undo)-1]
+	g.undo = g.undo[:len(g.undo)-1]
+	if g.pendingTimer != nil { g.pendingTimer.Stop() }
+	g.pending, g.pendingTimer = nil, nil
+	g.apply(s)
+	if g.dice.draws != s.draws { g.dice = restoreDice(g.dice.seed, s.draws) }
+	g.history = g.history[:s.moves]
+	g.undoVotes = nil
+}
+
+// apply overwrites the mutable state with s. Called with g.mu held.
+func (g *Game) apply(s snapshot) {
+	g.players = s.players
+	g.turnIndex = s.turnIndex
+	g.winner = s.winner
+	g.winningTeam = s.winningTeam
+	g.lastPlayed = s.lastPlayed
+	g.lastRoll = s.lastRoll
+	g.lastMove = s.lastMove
+}
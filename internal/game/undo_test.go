@@ -0,0 +1,120 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+var undoBoard = BoardDef{Width: 10, Height: 10, Ladders: []Jump{{From: 4, To: 40}}}
+
+func TestHostUndoRestoresState(t *testing.T) {
+	g := newTestGame(t, undoBoard, Rules{Undo: UndoHost, Collision: CollisionCapture}, "Host", "Guest")
+	playFixed(g, 4) // Host climbs to 40
+	before := g.State()
+	g.players[1].Position = g.PointAt(36)
+	playFixed(g, 4) // Guest captures Host on 40
+	if g.players[0].Position.Square() != 0 { t.Fatal("expected capture") }
+
+	if _, err := g.Undo("Guest"); err == nil { t.Fatal("only the host may undo") }
+	res, err := g.Undo("host")
+	if err != nil { t.Fatalf("undo: %v", err) }
+	if res.Undone == nil || res.Undone.Player != "Guest" { t.Fatalf("expected Guest's move undone, got %+v", res) }
+	st := g.State()
+	if st.TurnIndex != 1 || st.Players[0].Position.Square() != 40 || st.Players[1].Position.Square() != 36 {
+		t.Fatalf("state not restored: turn=%d host=%d guest=%d", st.TurnIndex, st.Players[0].Position.Square(), st.Players[1].Position.Square())
+	}
+	if st.Moves != 1 || st.LastMove == nil || st.LastMove.Player != "Host" || st.Undoable != 1 || before.LastRoll != st.LastRoll {
+		t.Fatalf("history not restored: %+v", st)
+	}
+	if _, err := g.Undo("Host"); err != nil { t.Fatalf("second undo: %v", err) }
+	if _, err := g.Undo("Host"); err == nil { t.Fatal("expected nothing left to undo") }
+}
+
+func TestUndoClearsWinner(t *testing.T) {
+	g := newTestGame(t, undoBoard, Rules{Undo: UndoHost, Collision: CollisionCapture}, "Host", "Guest")
+	g.players[0].Position = g.PointAt(98)
+	playFixed(g, 2)
+	if g.State().Winner == nil { t.Fatal("expected a winner") }
+	if _, err := g.Undo("Host"); err != nil { t.Fatalf("undo: %v", err) }
+	if st := g.State(); st.Winner != nil || st.Players[0].Position.Square() != 98 { t.Fatalf("winner not cleared: %+v", st.Winner) }
+}
+
+func TestUndoByVote(t *testing.T) {
+	g := newTestGame(t, undoBoard, Rules{Undo: UndoVote, Collision: CollisionCapture}, "Host", "Guest")
+	playFixed(g, 2)
+	res, err := g.Undo("Guest")
+	if err != nil { t.Fatalf("vote: %v", err) }
+	if res.Undone != nil || len(res.Votes) != 1 || res.Needed != 2 { t.Fatalf("expected a pending vote, got %+v", res) }
+	if res, _ = g.Undo("Guest"); len(res.Votes) != 1 { t.Fatal("voting twice should count once") }
+	if st := g.State(); len(st.UndoVotes) != 1 || st.Moves != 1 { t.Fatalf("vote should not undo yet: %+v", st) }
+	res, err = g.Undo("Host")
+	if err != nil || res.Undone == nil { t.Fatalf("expected undo after all votes, got %+v %v", res, err) }
+	if st := g.State(); st.Moves != 0 || len(st.UndoVotes) != 0 || st.Players[0].Position.Square() != 0 { t.Fatalf("move not undone: %+v", st) }
+}
+
+func TestUndoOffAndUnknownPlayer(t *testing.T) {
+	g := newTestGame(t, undoBoard, Rules{Undo: UndoOff, Collision: CollisionCapture}, "Host", "Guest")
+	playFixed(g, 2)
+	if _, err := g.Undo("Host"); err == nil { t.Fatal("undo should be off") }
+	g = newTestGame(t, undoBoard, Rules{Undo: UndoHost, Collision: CollisionCapture}, "Host", "Guest")
+	playFixed(g, 2)
+	if _, err := g.Undo("Nobody"); err == nil { t.Fatal("expected error for unknown player") }
+}
+
+func TestUndoBroadcastsRollback(t *testing.T) {
+	g := newTestGame(t, undoBoard, Rules{Undo: UndoHost, Collision: CollisionCapture}, "Host", "Guest")
+	playFixed(g, 2)
+	sub, _ := g.bus.Subscribe(g.topic)
+	defer sub.Close()
+	if _, err := g.Undo("Host"); err != nil { t.Fatalf("undo: %v", err) }
+	if ev := string(<-sub.C); !strings.HasPrefix(ev, "event: rollback\n") { t.Fatalf("expected rollback event first, got %q", ev) }
+	if ev := string(<-sub.C); !strings.HasPrefix(ev, "data: ") { t.Fatalf("expected a state update after rollback, got %q", ev) }
+}
+
+func TestUndoNeedsALastMove(t *testing.T) {
+	g := newTestGame(t, undoBoard, Rules{Undo: UndoHost, Collision: CollisionCapture}, "Host", "Guest")
+	playFixed(g, 2)
+	s := g.Snapshot()
+	s.LastMove = nil
+	if _, err := FromSnapshot(s); err == nil { t.Fatal("expected a snapshot with undo but no last move to be refused") }
+	g.mu.Lock()
+	g.lastMove = nil
+	g.mu.Unlock()
+	if _, err := g.Undo("Host"); err != ErrNothingToUndo { t.Fatalf("expected nothing to undo, got %v", err) }
+	// the game is not left locked
+	if st := g.State(); st.Undoable != 1 { t.Fatalf("expected the undo stack untouched, got %d", st.Undoable) }
+}
+
+func TestUndoCancelsOnlyThePendingRoll(t *testing.T) {
+	g := newTestGame(t, pawnBoard, Rules{Pawns: 2}, "A", "B")
+	g.players[0].Pawns[0] = g.PointAt(5)
+	playFixed(g, 1) // A moves a pawn to 6
+	g.players[1].Pawns[0] = g.PointAt(3)
+	playFixed(g, 1) // B moves a pawn to 4
+	m, _, err := g.Roll()
+	if err != nil || m.Choices == nil { t.Fatalf("expected A's roll to wait for a pawn, got %+v %v", m, err) }
+	sub, _ := g.bus.Subscribe(g.topic)
+	defer sub.Close()
+	res, err := g.Undo("A")
+	if err != nil || res.Undone == nil || res.Undone.Player != "A" || res.Undone.Roll != m.Roll || res.Undone.Choices == nil { t.Fatalf("expected A's pending roll undone, got %+v %v", res, err) }
+	if ev := string(<-sub.C); !strings.Contains(ev, `"player":"A"`) { t.Fatalf("rollback reports another move: %q", ev) }
+	st := g.State()
+	if st.Pending != nil || st.TurnIndex != 0 || st.Moves != 2 || st.LastMove == nil || st.LastMove.Player != "B" || st.Players[1].Pawns[0].Square() != 4 {
+		t.Fatalf("B's move should stand with A to roll again: %+v", st)
+	}
+}
+
+func TestUndoRollsTheSameNumber(t *testing.T) {
+	g := newTestGame(t, undoBoard, Rules{Undo: UndoHost}, "Host", "Guest")
+	first, _, err := g.Roll()
+	if err != nil { t.Fatal(err) }
+	if _, err := g.Undo("Host"); err != nil { t.Fatalf("undo: %v", err) }
+	again, _, err := g.Roll()
+	if err != nil || again.Roll != first.Roll { t.Fatalf("undo gave a fresh roll: %d then %d", first.Roll, again.Roll) }
+	next, _, _ := g.Roll()
+	if _, err := g.Undo("Host"); err != nil { t.Fatalf("undo: %v", err) }
+	// taken back and reimported, the dice still come out the same
+	g, err = FromSnapshot(g.Snapshot())
+	if err != nil { t.Fatalf("import: %v", err) }
+	if m, _, _ := g.Roll(); m.Roll != next.Roll { t.Fatalf("reimported game rolled %d, want %d", m.Roll, next.Roll) }
+}
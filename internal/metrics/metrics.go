@@ -0,0 +1,233 @@
+// Package metrics keeps counters, gauges and histograms and writes them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram bounds, in seconds, used for latencies.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds metrics and writes them in the order they were added.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry { return &Registry{} }
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		if err := m.write(w); err != nil { return err }
+	}
+	return nil
+}
+
+// ContentType is the media type of what Write produces.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// desc names a metric and its labels.
+type desc struct {
+	name, help, kind string
+	labels           []string
+}
+
+func (d desc) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+	return err
+}
+
+// key joins label values into a map key, checking their number.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// series formats name{labels} for the label values in key, plus extra
+// label pairs such as le.
+func (d desc) series(suffix, key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") { pairs = append(pairs, d.labels[i]+`="`+escapeValue(v)+`"`) }
+	}
+	for i := 0; i+1 < len(extra); i += 2 { pairs = append(pairs, extra[i]+`="`+escapeValue(extra[i+1])+`"`) }
+	if len(pairs) == 0 { return d.name + suffix }
+	return d.name + suffix + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m { keys = append(keys, k) }
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a family of counters, one per set of label values.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter adds a counter to r.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}, values: map[string]float64{}}
+	r.add(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *Counter) Inc(values ...string) { c.Add(1, values...) }
+
+// Add adds v, which must not be negative, to the counter with the given
+// label values.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 { panic("metrics: counters cannot decrease") }
+	k := c.key(values)
+	c.mu.Lock()
+	c.values[k] += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w io.Writer) error {
+	if err := c.header(w); err != nil { return err }
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range sortedKeys(c.values) {
+		if _, err := fmt.Fprintf(w, "%s %s\n", c.series("", k), formatFloat(c.values[k])); err != nil { return err }
+	}
+	return nil
+}
+
+// Func is a metric read when the registry is written, for values kept
+// elsewhere. It returns one value per set of label values, keyed by the
+// values joined with Join.
+type Func struct {
+	desc
+	read func() map[string]float64
+}
+
+// Join makes the key Func readers use for a set of label values.
+func Join(values ...string) string { return strings.Join(values, "\xff") }
+
+// NewGaugeFunc adds a gauge read from read to r.
+func (r *Registry) NewGaugeFunc(name, help string, read func() map[string]float64, labels ...string) *Func {
+	f := &Func{desc{name, help, "gauge", labels}, read}
+	r.add(f)
+	return f
+}
+
+// NewCounterFunc adds a counter read from read to r. What read returns must
+// never decrease.
+func (r *Registry) NewCounterFunc(name, help string, read func() map[string]float64, labels ...string) *Func {
+	f := &Func{desc{name, help, "counter", labels}, read}
+	r.add(f)
+	return f
+}
+
+func (f *Func) write(w io.Writer) error {
+	if err := f.header(w); err != nil { return err }
+	values := f.read()
+	for _, k := range sortedKeys(values) {
+		if _, err := fmt.Fprintf(w, "%s %s\n", f.series("", k), formatFloat(values[k])); err != nil { return err }
+	}
+	return nil
+}
+
+// Histogram is a family of histograms, one per set of label values.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram adds a histogram with the given upper bounds, in increasing
+// order, to r.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) { panic("metrics: buckets must be sorted") }
+	h := &Histogram{desc: desc{name, help, "histogram", labels}, buckets: buckets, values: map[string]*histogram{}}
+	r.add(h)
+	return h
+}
+
+// Observe records v in the histogram with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	k := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[k]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[k] = s
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, v)]++
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	if err := h.header(w); err != nil { return err }
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range sortedKeys(h.values) {
+		s := h.values[k]
+		var cum uint64
+		for i, n := range s.counts {
+			cum += n
+			le := math.Inf(1)
+			if i < len(h.buckets) { le = h.buckets[i] }
+			if _, err := fmt.Fprintf(w, "%s %d\n", h.series("_bucket", k, "le", formatFloat(le)), cum); err != nil { return err }
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n%s %d\n", h.series("_sum", k), formatFloat(s.sum), h.series("_count", k), s.count); err != nil { return err }
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests served.", "route", "status")
+	c.Inc("/b", "200")
+	c.Inc("/a", "404")
+	c.Add(2, "/b", "200")
+	r.NewGaugeFunc("games", "Games by state.", func() map[string]float64 {
+		return map[string]float64{Join("playing"): 3, Join("finished"): 1}
+	}, "state")
+	r.NewCounterFunc("dropped_total", "Dropped.\nSecond line.", func() map[string]float64 { return map[string]float64{"": 7} })
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, `say "hi"`)
+	h.Observe(0.1, `say "hi"`)
+	h.Observe(3, `say "hi"`)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil { t.Fatal(err) }
+	want := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/a",status="404"} 1
+requests_total{route="/b",status="200"} 3
+# HELP games Games by state.
+# TYPE games gauge
+games{state="finished"} 1
+games{state="playing"} 3
+# HELP dropped_total Dropped.\nSecond line.
+# TYPE dropped_total counter
+dropped_total 7
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="say \"hi\"",le="0.1"} 2
+latency_seconds_bucket{op="say \"hi\"",le="1"} 2
+latency_seconds_bucket{op="say \"hi\"",le="+Inf"} 3
+latency_seconds_sum{op="say \"hi\""} 3.15
+latency_seconds_count{op="say \"hi\""} 3
+`
+	if buf.String() != want { t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want) }
+}
+
+func TestLabelCountIsChecked(t *testing.T) {
+	defer func() {
+		if recover() == nil { t.Fatal("expected a panic for missing label values") }
+	}()
+	NewRegistry().NewCounter("c", "c", "a", "b").Inc("only one")
+}
@@ -0,0 +1,155 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+// PNG writes b as a PNG image, or fails with ErrTooLarge. Shapes are
+// anti-aliased; text uses a small built-in bitmap font of digits and capital
+// letters.
+func PNG(w io.Writer, b *Board, opts Options) error {
+	s, err := layout(b, opts)
+	if err != nil { return err }
+	img := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	for _, v := range s.shapes {
+		switch v := v.(type) {
+		case rect:
+			fillRect(img, int(math.Round(v.x)), int(math.Round(v.y)), int(math.Round(v.x+v.w)), int(math.Round(v.y+v.h)), v.fill)
+		case line:
+			stroke(img, v)
+		case circle:
+			// a circle is a line of one point
+			stroke(img, line{[]point{v.c}, 2 * v.r, v.fill})
+		case text:
+			write(img, v)
+		}
+	}
+	return (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(w, img)
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	r := image.Rect(x0, y0, x1, y1).Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ { img.SetRGBA(x, y, c) }
+	}
+}
+
+// stroke paints every pixel within half the width of the polyline, blending
+// the edge pixels by how much of them is covered. Coverage is gathered per
+// segment first so that joints are not painted twice.
+func stroke(img *image.RGBA, l line) {
+	if len(l.pts) == 0 { return }
+	half := l.width / 2
+	box := func(a, b point) image.Rectangle {
+		return image.Rect(int(math.Min(a.x, b.x)-half-1), int(math.Min(a.y, b.y)-half-1), int(math.Max(a.x, b.x)+half+2), int(math.Max(a.y, b.y)+half+2)).Intersect(img.Bounds())
+	}
+	var r image.Rectangle
+	for i := range l.pts { r = r.Union(box(l.pts[i], l.pts[max(i-1, 0)])) }
+	if r.Empty() { return }
+	cover := make([]float64, r.Dx()*r.Dy())
+	for i := range l.pts {
+		a, b := l.pts[max(i-1, 0)], l.pts[i]
+		seg := box(a, b)
+		for y := seg.Min.Y; y < seg.Max.Y; y++ {
+			for x := seg.Min.X; x < seg.Max.X; x++ {
+				c := half + 0.5 - segmentDistance(point{float64(x) + 0.5, float64(y) + 0.5}, a, b)
+				k := (y-r.Min.Y)*r.Dx() + x - r.Min.X
+				cover[k] = math.Max(cover[k], math.Min(c, 1))
+			}
+		}
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if c := cover[(y-r.Min.Y)*r.Dx()+x-r.Min.X]; c > 0 { blend(img, x, y, l.stroke, c) }
+		}
+	}
+}
+
+// segmentDistance is the distance from p to the segment from a to b.
+func segmentDistance(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 { t = math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/l)) }
+	return math.Hypot(p.x-a.x-t*dx, p.y-a.y-t*dy)
+}
+
+// blend paints c over the pixel at x, y with the given opacity.
+func blend(img *image.RGBA, x, y int, c color.RGBA, alpha float64) {
+	if alpha >= 1 {
+		img.SetRGBA(x, y, c)
+		return
+	}
+	under := img.RGBAAt(x, y)
+	mix := func(a, b uint8) uint8 { return uint8(math.Round(float64(a)*(1-alpha) + float64(b)*alpha)) }
+	img.SetRGBA(x, y, color.RGBA{mix(under.R, c.R), mix(under.G, c.G), mix(under.B, c.B), mix(under.A, c.A)})
+}
+
+// glyphChars are the characters of the bitmap font, in the order of glyphs;
+// others are left blank.
+const glyphChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ "
+
+// glyphs are 3x5 pixels, one row of three bits per string.
+var glyphs = [...][5]string{
+	{"111", "101", "101", "101", "111"}, // 0
+	{"010", "110", "010", "010", "111"}, // 1
+	{"111", "001", "111", "100", "111"}, // 2
+	{"111", "001", "011", "001", "111"}, // 3
+	{"101", "101", "111", "001", "001"}, // 4
+	{"111", "100", "111", "001", "111"}, // 5
+	{"111", "100", "111", "101", "111"}, // 6
+	{"111", "001", "010", "010", "010"}, // 7
+	{"111", "101", "111", "101", "111"}, // 8
+	{"111", "101", "111", "001", "111"}, // 9
+	{"010", "101", "111", "101", "101"}, // A
+	{"110", "101", "110", "101", "110"}, // B
+	{"011", "100", "100", "100", "011"}, // C
+	{"110", "101", "101", "101", "110"}, // D
+	{"111", "100", "110", "100", "111"}, // E
+	{"111", "100", "110", "100", "100"}, // F
+	{"011", "100", "101", "101", "011"}, // G
+	{"101", "101", "111", "101", "101"}, // H
+	{"111", "010", "010", "010", "111"}, // I
+	{"001", "001", "001", "101", "010"}, // J
+	{"101", "101", "110", "101", "101"}, // K
+	{"100", "100", "100", "100", "111"}, // L
+	{"101", "111", "111", "101", "101"}, // M
+	{"110", "101", "101", "101", "101"}, // N
+	{"010", "101", "101", "101", "010"}, // O
+	{"110", "101", "110", "100", "100"}, // P
+	{"010", "101", "101", "110", "011"}, // Q
+	{"110", "101", "110", "101", "101"}, // R
+	{"011", "100", "010", "001", "110"}, // S
+	{"111", "010", "010", "010", "010"}, // T
+	{"101", "101", "101", "101", "111"}, // U
+	{"101", "101", "101", "101", "010"}, // V
+	{"101", "101", "111", "111", "101"}, // W
+	{"101", "101", "010", "101", "101"}, // X
+	{"101", "101", "010", "010", "010"}, // Y
+	{"111", "001", "010", "100", "111"}, // Z
+	{"000", "000", "000", "000", "000"}, // space
+}
+
+// write draws t in the bitmap font, scaled so that capitals are about as
+// tall as in the SVG.
+func write(img *image.RGBA, t text) {
+	scale := max(1, int(math.Round(t.size*0.14)))
+	s := strings.ToUpper(t.s)
+	x := int(math.Round(t.at.x))
+	if t.middle { x -= (len(s)*4 - 1) * scale / 2 }
+	top := int(math.Round(t.at.y)) - 5*scale
+	for i := 0; i < len(s); i++ {
+		if g := strings.IndexByte(glyphChars, s[i]); g >= 0 {
+			for row, bits := range glyphs[g] {
+				for col, bit := range bits {
+					if bit == '1' { fillRect(img, x+col*scale, top+row*scale, x+(col+1)*scale, top+(row+1)*scale, t.fill) }
+				}
+			}
+		}
+		x += 4 * scale
+	}
+}
@@ -0,0 +1,273 @@
+// Package render draws boards, and optionally the pawns on them, as SVG and
+// PNG images for chat messages, docs and printed sheets. Both formats are
+// drawn from the same scene, so they look alike; the colors follow the web
+// client.
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// Cell sizes in pixels. A zero Options.Cell picks DefaultCell; any size is
+// shrunk so that the longer side of the image stays within MaxSide, but not
+// below MinCell.
+const (
+	DefaultCell = 48
+	MinCell     = 8
+	MaxCell     = 128
+	MaxSide     = 2048
+)
+
+// MaxBoardSide is the most squares a board drawn may have across or down:
+// MaxSide at MinCell.
+const MaxBoardSide = MaxSide / MinCell
+
+// ErrTooLarge is returned for boards wider or taller than MaxBoardSide.
+var ErrTooLarge = fmt.Errorf("board is too large to draw: more than %d squares wide or tall", MaxBoardSide)
+
+// Board is what gets drawn. Square n is Cells[n-1]; jumps and tiles use
+// square numbers.
+type Board struct {
+	Width, Height int
+	Cells         []game.Cell
+	Snakes        []game.Jump
+	Ladders       []game.Jump
+	Tiles         []game.Tile
+	Pawns         []Pawn
+}
+
+// Pawn is a pawn on Square. Player, the seat in joining order, picks its
+// color and Label is written on it.
+type Pawn struct {
+	Player int
+	Label  string
+	Square int
+}
+
+// Options tune the image.
+type Options struct {
+	// Cell is the side of a square in pixels.
+	Cell int
+}
+
+// FromGame returns the board of g, with the pawns on it when pawns is set.
+// Pawns still at the start are left out.
+func FromGame(g *game.Game, pawns bool) *Board {
+	snap := g.Snapshot()
+	board := g.Board()
+	b := &Board{
+		Width:   board.Width(),
+		Height:  board.Height(),
+		Cells:   board.Cells(),
+		Snakes:  snap.Board.Snakes,
+		Ladders: snap.Board.Ladders,
+		Tiles:   snap.Board.Tiles,
+	}
+	if !pawns { return b }
+	for i, p := range snap.Players {
+		label := initial(p.Name, i)
+		for j, sq := range p.Pawns {
+			if sq == 0 { continue }
+			l := label
+			if len(p.Pawns) > 1 { l += strconv.Itoa(j + 1) }
+			b.Pawns = append(b.Pawns, Pawn{Player: i, Label: l, Square: sq})
+		}
+	}
+	return b
+}
+
+// initial is the upper-cased first letter of name, or the seat number when
+// that is not a plain letter or digit, which every format can write.
+func initial(name string, seat int) string {
+	if name != "" {
+		if c := strings.ToUpper(name[:1]); strings.Contains(glyphChars, c) && c != " " { return c }
+	}
+	return strconv.Itoa(seat + 1)
+}
+
+// Colors, as in web/app.js.
+var (
+	gapColor    = hex("#e2e8f0")
+	cellColors  = []color.RGBA{hex("#fef08a"), hex("#c7d2fe"), hex("#f5d0fe"), hex("#bbf7d0")}
+	numberColor = hex("#64748b")
+	tileColor   = hex("#6d28d9")
+	snakeColors = []color.RGBA{hex("#ef4444"), hex("#22c55e"), hex("#f97316"), hex("#a78bfa"), hex("#ff4d8d"), hex("#34d399")}
+	railColor   = hex("#8b5a2b")
+	rungColor   = hex("#deb887")
+	pawnColors  = []color.RGBA{hex("#10b981"), hex("#3b82f6"), hex("#a855f7"), hex("#f59e0b"), hex("#ef4444"), hex("#14b8a6")}
+	white       = hex("#ffffff")
+	ink         = hex("#0f172a")
+)
+
+// tileLabels are written on special squares.
+var tileLabels = map[game.TileEffect]string{
+	game.TileSkipTurn:   "SKIP",
+	game.TileExtraRoll:  "ROLL",
+	game.TileSwapLeader: "SWAP",
+	game.TileShield:     "SHIELD",
+}
+
+// hex parses a #rrggbb color.
+func hex(s string) color.RGBA {
+	n, _ := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	return color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 0xff}
+}
+
+// Shapes of a scene, in pixels with y pointing down.
+type (
+	point struct{ x, y float64 }
+	rect  struct {
+		x, y, w, h float64
+		fill       color.RGBA
+	}
+	// line is a polyline stroked with round caps and joins.
+	line struct {
+		pts    []point
+		width  float64
+		stroke color.RGBA
+	}
+	circle struct {
+		c    point
+		r    float64
+		fill color.RGBA
+	}
+	// text sits on its baseline at at, starting there or centered on it.
+	text struct {
+		at     point
+		size   float64
+		fill   color.RGBA
+		s      string
+		middle bool
+		bold   bool
+	}
+)
+
+// scene is a board laid out as shapes, drawn in order.
+type scene struct {
+	width, height int
+	shapes        []interface{}
+}
+
+// cellSize is the side of a square for b under opts. Boards up to
+// MaxBoardSide stay within MaxSide.
+func cellSize(b *Board, opts Options) int {
+	c := opts.Cell
+	if c == 0 { c = DefaultCell }
+	c = min(c, MaxCell, MaxSide/max(b.Width, b.Height, 1))
+	return max(c, MinCell)
+}
+
+// layout places the squares, numbers, tiles, snakes, ladders and pawns of b.
+func layout(b *Board, opts Options) (*scene, error) {
+	if b.Width > MaxBoardSide || b.Height > MaxBoardSide { return nil, ErrTooLarge }
+	cell := float64(cellSize(b, opts))
+	s := &scene{width: b.Width * int(cell), height: b.Height * int(cell)}
+	add := func(v interface{}) { s.shapes = append(s.shapes, v) }
+	// corner is the top left of a cell, center the middle of a square
+	corner := func(c game.Cell) point { return point{float64(c.Y) * cell, float64(b.Height-1-c.X) * cell} }
+	center := func(square int) (point, bool) {
+		if square < 1 || square > len(b.Cells) { return point{}, false }
+		p := corner(b.Cells[square-1])
+		return point{p.x + cell/2, p.y + cell/2}, true
+	}
+
+	add(rect{0, 0, float64(s.width), float64(s.height), gapColor})
+	gap := math.Max(1, cell/32)
+	for i, c := range b.Cells {
+		p := corner(c)
+		add(rect{p.x + gap/2, p.y + gap/2, cell - gap, cell - gap, cellColors[(b.Height-1-c.X+c.Y)%len(cellColors)]})
+		add(text{at: point{p.x + cell*0.1, p.y + cell*0.3}, size: math.Max(7, cell*0.24), fill: numberColor, s: strconv.Itoa(i + 1)})
+	}
+	for _, t := range b.Tiles {
+		c, ok := center(t.Square)
+		if !ok { continue }
+		label := tileLabels[t.Effect]
+		if t.Effect == game.TileTeleport { label = "TO " + strconv.Itoa(t.Target) }
+		add(text{at: point{c.x, c.y + cell*0.4}, size: math.Max(6, cell*0.18), fill: tileColor, s: label, middle: true, bold: true})
+	}
+	for i, j := range b.Snakes {
+		head, ok1 := center(j.From)
+		tail, ok2 := center(j.To)
+		if !ok1 || !ok2 { continue }
+		snake(add, head, tail, cell, snakeColors[i%len(snakeColors)])
+	}
+	for _, j := range b.Ladders {
+		bottom, ok1 := center(j.From)
+		top, ok2 := center(j.To)
+		if !ok1 || !ok2 { continue }
+		ladder(add, bottom, top, cell)
+	}
+	// pawns sharing a square are spread around its middle
+	bySquare := map[int][]Pawn{}
+	var squares []int
+	for _, p := range b.Pawns {
+		if _, ok := center(p.Square); !ok { continue }
+		if bySquare[p.Square] == nil { squares = append(squares, p.Square) }
+		bySquare[p.Square] = append(bySquare[p.Square], p)
+	}
+	for _, sq := range squares {
+		c, _ := center(sq)
+		group := bySquare[sq]
+		r, spread := cell*0.26, 0.0
+		if len(group) > 1 { r, spread = cell*0.17, cell*0.2 }
+		for k, p := range group {
+			a := 2*math.Pi*float64(k)/float64(len(group)) - math.Pi/2
+			at := point{c.x + spread*math.Cos(a), c.y + spread*math.Sin(a)}
+			add(circle{at, r + math.Max(1, cell/24), white})
+			add(circle{at, r, pawnColors[p.Player%len(pawnColors)]})
+			size := r * 1.1
+			if len(p.Label) > 1 { size = r * 0.9 }
+			add(text{at: point{at.x, at.y + size*0.36}, size: size, fill: white, s: p.Label, middle: true, bold: true})
+		}
+	}
+	return s, nil
+}
+
+// snake adds a wavy body from head to tail and a head with eyes.
+func snake(add func(interface{}), head, tail point, cell float64, c color.RGBA) {
+	dx, dy := tail.x-head.x, tail.y-head.y
+	length := math.Hypot(dx, dy)
+	if length == 0 { return }
+	nx, ny := -dy/length, dx/length
+	waves := math.Max(1, math.Round(length/cell/1.5))
+	const steps = 48
+	body := make([]point, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / steps
+		// the wave fades out at both ends so the snake meets its squares
+		off := cell * 0.22 * math.Sin(t*waves*2*math.Pi) * math.Sin(t*math.Pi)
+		body = append(body, point{head.x + dx*t + nx*off, head.y + dy*t + ny*off})
+	}
+	add(line{body, math.Max(2, cell*0.13), c})
+	add(circle{head, math.Max(2, cell*0.14), c})
+	ex, ey := dx/length*cell*0.04, dy/length*cell*0.04
+	for _, side := range []float64{-1, 1} {
+		eye := point{head.x - ex + side*nx*cell*0.06, head.y - ey + side*ny*cell*0.06}
+		add(circle{eye, math.Max(1, cell*0.035), white})
+		add(circle{eye, math.Max(0.5, cell*0.018), ink})
+	}
+}
+
+// ladder adds two rails from bottom to top joined by rungs.
+func ladder(add func(interface{}), bottom, top point, cell float64) {
+	dx, dy := top.x-bottom.x, top.y-bottom.y
+	length := math.Hypot(dx, dy)
+	if length == 0 { return }
+	ux, uy := dx/length, dy/length
+	nx, ny := -uy*cell*0.16, ux*cell*0.16
+	rung := math.Max(1, cell*0.05)
+	for d := cell * 0.2; d < length-cell*0.1; d += cell * 0.3 {
+		p := point{bottom.x + ux*d, bottom.y + uy*d}
+		add(line{[]point{{p.x - nx, p.y - ny}, {p.x + nx, p.y + ny}}, rung, rungColor})
+	}
+	rail := math.Max(1.5, cell*0.06)
+	for _, side := range []float64{-1, 1} {
+		add(line{[]point{{bottom.x + side*nx, bottom.y + side*ny}, {top.x + side*nx, top.y + side*ny}}, rail, railColor})
+	}
+}
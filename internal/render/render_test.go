@@ -0,0 +1,142 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// board is a 4x3 row-major board: squares 1-4 along the bottom row.
+func board(t *testing.T) *Board {
+	t.Helper()
+	def, err := game.ParseBoardDef(strings.NewReader(`{"width": 4, "height": 3, "layout": "row-major",
+		"snakes": [{"from": 11, "to": 2}], "ladders": [{"from": 3, "to": 9}],
+		"tiles": [{"square": 6, "effect": "teleport", "target": 10}]}`))
+	if err != nil { t.Fatalf("parse: %v", err) }
+	g, err := game.NewSeeded(def, 7)
+	if err != nil { t.Fatalf("new: %v", err) }
+	_ = g.SetRules(game.Rules{Pawns: 2})
+	_ = g.AddPlayer("arun")
+	_ = g.AddPlayer("Élan")
+	for g.Snapshot().Players[1].Pawns[0] == 0 {
+		m, _, err := g.Roll()
+		if err != nil { t.Fatalf("roll: %v", err) }
+		if len(m.Choices) > 0 { _, _, _ = g.MovePawn(m.Choices[0]) }
+	}
+	return FromGame(g, true)
+}
+
+func TestFromGame(t *testing.T) {
+	b := board(t)
+	if b.Width != 4 || b.Height != 3 || len(b.Cells) != 12 { t.Fatalf("unexpected board %dx%d with %d cells", b.Width, b.Height, len(b.Cells)) }
+	if len(b.Snakes) != 1 || b.Snakes[0] != (game.Jump{From: 11, To: 2}) { t.Fatalf("unexpected snakes %+v", b.Snakes) }
+	if len(b.Ladders) != 1 || b.Ladders[0] != (game.Jump{From: 3, To: 9}) { t.Fatalf("unexpected ladders %+v", b.Ladders) }
+	seen := map[string]bool{}
+	for _, p := range b.Pawns {
+		if p.Square < 1 || p.Square > 12 { t.Fatalf("pawn %+v is off the board", p) }
+		seen[p.Label] = true
+	}
+	// names without a plain initial are numbered by seat
+	if !seen["21"] { t.Fatalf("expected pawn 21 of the second player, got %+v", b.Pawns) }
+	for l := range seen {
+		if !strings.HasPrefix(l, "A") && !strings.HasPrefix(l, "2") { t.Fatalf("unexpected label %q", l) }
+	}
+	g, _ := game.NewSeeded(&game.BoardDef{Width: 5, Height: 5}, 1)
+	_ = g.AddPlayer("A")
+	_ = g.AddPlayer("B")
+	_, _, _ = g.Roll()
+	if b := FromGame(g, false); len(b.Pawns) != 0 { t.Fatalf("expected no pawns, got %+v", b.Pawns) }
+}
+
+func TestCellSize(t *testing.T) {
+	for _, tc := range []struct {
+		side, cell, want int
+	}{
+		{10, 0, DefaultCell},
+		{10, 20, 20},
+		{10, 1000, MaxCell},
+		{10, 1, MinCell},
+		{100, 0, MaxSide / 100},
+		{MaxBoardSide, 0, MinCell},
+	} {
+		if got := cellSize(&Board{Width: tc.side, Height: tc.side / 2}, Options{Cell: tc.cell}); got != tc.want {
+			t.Errorf("side %d cell %d: expected %d, got %d", tc.side, tc.cell, tc.want, got)
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	b := board(t)
+	var buf bytes.Buffer
+	if err := SVG(&buf, b, Options{Cell: 40}); err != nil { t.Fatalf("svg: %v", err) }
+	dec := xml.NewDecoder(&buf)
+	var texts []string
+	counts := map[string]int{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF { break }
+		if err != nil { t.Fatalf("invalid SVG: %v", err) }
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			counts[tok.Name.Local]++
+			if tok.Name.Local == "svg" {
+				for _, a := range tok.Attr {
+					if (a.Name.Local == "width" && a.Value != "160") || (a.Name.Local == "height" && a.Value != "120") { t.Fatalf("unexpected %s %s", a.Name.Local, a.Value) }
+				}
+			}
+		case xml.CharData:
+			if s := strings.TrimSpace(string(tok)); s != "" { texts = append(texts, s) }
+		}
+	}
+	for sq := 1; sq <= 12; sq++ {
+		if texts[sq-1] != strconv.Itoa(sq) { t.Fatalf("expected square %d to be numbered, got %q", sq, texts[sq-1]) }
+	}
+	if !strings.Contains(strings.Join(texts, " "), "TO 10") { t.Fatalf("expected the teleport tile, got %q", texts) }
+	if counts["text"] != 12+1+len(b.Pawns) { t.Fatalf("expected numbers, a tile and pawn labels, got %d texts", counts["text"]) }
+	// two rails and the snake body
+	if counts["polyline"] < 3 { t.Fatalf("expected a snake and a ladder, got %d lines", counts["polyline"]) }
+}
+
+func TestPNG(t *testing.T) {
+	b := &Board{Width: 4, Height: 3, Cells: board(t).Cells, Pawns: []Pawn{{Player: 1, Label: "M", Square: 8}}}
+	var buf bytes.Buffer
+	if err := PNG(&buf, b, Options{Cell: 40}); err != nil { t.Fatalf("png: %v", err) }
+	img, err := png.Decode(&buf)
+	if err != nil { t.Fatalf("decode: %v", err) }
+	if got := img.Bounds().Size(); got.X != 160 || got.Y != 120 { t.Fatalf("expected 160x120, got %v", got) }
+	for _, tc := range []struct {
+		name string
+		x, y int
+		want string
+	}{
+		// square 1 is the bottom left cell, square 8 the right end of the
+		// middle row
+		{"square 1", 30, 110, "#f5d0fe"},
+		{"square 2", 70, 110, "#bbf7d0"},
+		{"gap", 40, 100, "#e2e8f0"},
+		{"pawn", 133, 60, "#3b82f6"},
+	} {
+		if got := rgb(color.RGBAModel.Convert(img.At(tc.x, tc.y)).(color.RGBA)); got != tc.want { t.Errorf("%s at %d,%d: expected %s, got %s", tc.name, tc.x, tc.y, tc.want, got) }
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	side := MaxBoardSide + 1
+	b := &Board{Width: side, Height: 2, Cells: []game.Cell{{X: 0, Y: 0}, {X: 1, Y: side - 1}}}
+	for name, draw := range map[string]func(io.Writer, *Board, Options) error{"svg": SVG, "png": PNG} {
+		var buf bytes.Buffer
+		if err := draw(&buf, b, Options{}); err != ErrTooLarge || buf.Len() != 0 { t.Errorf("%s: expected ErrTooLarge and no output, got %v and %d bytes", name, err, buf.Len()) }
+	}
+	b.Width = MaxBoardSide
+	b.Cells[1].Y = MaxBoardSide - 1
+	var buf bytes.Buffer
+	if err := PNG(&buf, b, Options{Cell: MaxCell}); err != nil { t.Fatalf("png: %v", err) }
+	if cfg, err := png.DecodeConfig(&buf); err != nil || cfg.Width != MaxSide { t.Fatalf("expected %d pixels across, got %+v: %v", MaxSide, cfg, err) }
+}
@@ -0,0 +1,46 @@
+package render
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SVG writes b as an SVG document, or fails with ErrTooLarge.
+func SVG(w io.Writer, b *Board, opts Options) error {
+	s, err := layout(b, opts)
+	if err != nil { return err }
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Poppins, Helvetica, Arial, sans-serif">`+"\n", s.width, s.height, s.width, s.height)
+	for _, v := range s.shapes {
+		switch v := v.(type) {
+		case rect:
+			fmt.Fprintf(bw, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n", num(v.x), num(v.y), num(v.w), num(v.h), rgb(v.fill))
+		case line:
+			pts := make([]string, len(v.pts))
+			for i, p := range v.pts { pts[i] = num(p.x) + "," + num(p.y) }
+			fmt.Fprintf(bw, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>`+"\n", strings.Join(pts, " "), rgb(v.stroke), num(v.width))
+		case circle:
+			fmt.Fprintf(bw, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`+"\n", num(v.c.x), num(v.c.y), num(v.r), rgb(v.fill))
+		case text:
+			var attrs string
+			if v.middle { attrs += ` text-anchor="middle"` }
+			if v.bold { attrs += ` font-weight="bold"` }
+			fmt.Fprintf(bw, `<text x="%s" y="%s" font-size="%s" fill="%s"%s>`, num(v.at.x), num(v.at.y), num(v.size), rgb(v.fill), attrs)
+			_ = xml.EscapeText(bw, []byte(v.s))
+			bw.WriteString("</text>\n")
+		}
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// num writes a coordinate to a hundredth of a pixel.
+func num(f float64) string { return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64) }
+
+func rgb(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
@@ -0,0 +1,255 @@
+// Package client talks to the Snake & Ladder server over its HTTP API.
+//
+//	c := client.New("http://localhost:8080")
+//	id, err := c.CreateGame(ctx, client.GameOptions{Grid: 10})
+//	_, err = c.AddPlayer(ctx, id, "Arun")
+//	res, err := c.Roll(ctx, id)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arsulegai/snakeandladder/internal/game"
+)
+
+// Client is safe for concurrent use.
+type Client struct {
+	base      string
+	http      *http.Client
+	reconnect time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient makes the client send requests through hc. Streams should
+// not be subject to hc's Timeout.
+func WithHTTPClient(hc *http.Client) Option { return func(c *Client) { c.http = hc } }
+
+// New returns a client for the server at baseURL, such as
+// "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{base: strings.TrimRight(baseURL, "/"), http: http.DefaultClient}
+	for _, o := range opts { o(c) }
+	return c
+}
+
+// APIError is an error response from the server. Code is the stable error
+// code, such as "not_host"; Details holds the values the message was built
+// from.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    map[string]interface{}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("snakeandladder: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+}
+
+// Is matches the game errors below by code, so errors.Is(err,
+// client.ErrNotHost) works on errors returned by the server.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*game.Error)
+	return ok && t.Code == e.Code
+}
+
+// Errors the server reports, for use with errors.Is.
+var (
+	ErrInvalidBoard      = game.ErrInvalidBoard
+	ErrInvalidRules      = game.ErrInvalidRules
+	ErrInvalidSnapshot   = game.ErrInvalidSnapshot
+	ErrRulesLocked       = game.ErrRulesLocked
+	ErrGameFinished      = game.ErrGameFinished
+	ErrDuplicateName     = game.ErrDuplicateName
+	ErrGameFull          = game.ErrGameFull
+	ErrTeamRequired      = game.ErrTeamRequired
+	ErrTeamNotAllowed    = game.ErrTeamNotAllowed
+	ErrUnknownPlayer     = game.ErrUnknownPlayer
+	ErrNotEnoughPlayers  = game.ErrNotEnoughPlayers
+	ErrNotEnoughTeams    = game.ErrNotEnoughTeams
+	ErrPawnChoicePending = game.ErrPawnChoicePending
+	ErrNoPawnChoice      = game.ErrNoPawnChoice
+	ErrPawnCannotMove    = game.ErrPawnCannotMove
+	ErrUndoDisabled      = game.ErrUndoDisabled
+	ErrNothingToUndo     = game.ErrNothingToUndo
+	ErrNotHost           = game.ErrNotHost
+	ErrTooManyGames      = game.ErrTooManyGames
+	ErrGameBusy          = game.ErrGameBusy
+)
+
+// GameOptions describes a new game. Board, when set, wins over Grid, Width,
+// Height and Layout; zero values take the server defaults.
+type GameOptions struct {
+	Grid          int
+	Width, Height int
+	Layout        Layout
+	Board         *BoardDef
+	Rules         Rules
+	// Seed fixes board generation and dice.
+	Seed *int64
+}
+
+func (o GameOptions) query() url.Values {
+	q := url.Values{}
+	setInt := func(k string, v int) {
+		if v != 0 { q.Set(k, strconv.Itoa(v)) }
+	}
+	setInt("grid", o.Grid)
+	setInt("width", o.Width)
+	setInt("height", o.Height)
+	if o.Layout != "" { q.Set("layout", string(o.Layout)) }
+	setInt("pawns", o.Rules.Pawns)
+	setInt("moveSeconds", o.Rules.MoveSeconds)
+	if o.Rules.Collision != "" { q.Set("collision", string(o.Rules.Collision)) }
+	if o.Rules.TeamWin != "" { q.Set("teamWin", string(o.Rules.TeamWin)) }
+	if o.Rules.Undo != "" { q.Set("undo", string(o.Rules.Undo)) }
+	if o.Seed != nil { q.Set("seed", strconv.FormatInt(*o.Seed, 10)) }
+	return q
+}
+
+// RollResult is the outcome of a roll or a pawn choice. Winner is empty
+// while the game goes on.
+type RollResult struct {
+	Roll   int    `json:"roll"`
+	Move   Move   `json:"move"`
+	Winner string `json:"winner,omitempty"`
+}
+
+// CreateGame creates a game and returns its id.
+func (c *Client) CreateGame(ctx context.Context, o GameOptions) (string, error) {
+	var body interface{}
+	if o.Board != nil { body = o.Board }
+	return c.create(ctx, "/api/v1/games?"+o.query().Encode(), body)
+}
+
+// AddPlayer seats a player and returns the updated state.
+func (c *Client) AddPlayer(ctx context.Context, id, name string) (State, error) {
+	return c.AddPlayerToTeam(ctx, id, name, "")
+}
+
+// AddPlayerToTeam seats a player in a team game.
+func (c *Client) AddPlayerToTeam(ctx context.Context, id, name, team string) (State, error) {
+	var st State
+	body := map[string]string{"name": name}
+	if team != "" { body["team"] = team }
+	err := c.do(ctx, http.MethodPost, gamePath(id, "players"), body, &st)
+	return st, err
+}
+
+// Roll plays the current player's turn. When the roll waits for a pawn
+// choice, Move.Choices lists the pawns to pick from with MovePawn.
+func (c *Client) Roll(ctx context.Context, id string) (RollResult, error) {
+	var res RollResult
+	err := c.do(ctx, http.MethodPost, gamePath(id, "roll"), nil, &res)
+	return res, err
+}
+
+// MovePawn picks the pawn for a roll waiting on a choice.
+func (c *Client) MovePawn(ctx context.Context, id string, pawn int) (RollResult, error) {
+	var res RollResult
+	err := c.do(ctx, http.MethodPost, gamePath(id, "move"), map[string]int{"pawn": pawn}, &res)
+	return res, err
+}
+
+// Undo asks to take back the last move on behalf of player.
+func (c *Client) Undo(ctx context.Context, id, player string) (UndoResult, error) {
+	var res UndoResult
+	err := c.do(ctx, http.MethodPost, gamePath(id, "undo"), map[string]string{"player": player}, &res)
+	return res, err
+}
+
+// State fetches the game state.
+func (c *Client) State(ctx context.Context, id string) (State, error) {
+	var st State
+	err := c.do(ctx, http.MethodGet, gamePath(id, "state"), nil, &st)
+	return st, err
+}
+
+// Events fetches the moves played after the first since.
+func (c *Client) Events(ctx context.Context, id string, since int) ([]Move, error) {
+	var moves []Move
+	err := c.do(ctx, http.MethodGet, gamePath(id, "events")+"?since="+strconv.Itoa(since), nil, &moves)
+	return moves, err
+}
+
+// Snapshot exports the game.
+func (c *Client) Snapshot(ctx context.Context, id string) (Snapshot, error) {
+	var s Snapshot
+	err := c.do(ctx, http.MethodGet, gamePath(id, "snapshot"), nil, &s)
+	return s, err
+}
+
+// Import creates a game from a snapshot and returns its id.
+func (c *Client) Import(ctx context.Context, s Snapshot) (string, error) {
+	return c.create(ctx, "/api/v1/games/import", s)
+}
+
+// Fork copies a game and returns the id of the copy.
+func (c *Client) Fork(ctx context.Context, id string) (string, error) {
+	return c.create(ctx, gamePath(id, "fork"), nil)
+}
+
+func (c *Client) create(ctx context.Context, path string, body interface{}) (string, error) {
+	var res struct{ ID string `json:"id"` }
+	if err := c.do(ctx, http.MethodPost, path, body, &res); err != nil { return "", err }
+	return res.ID, nil
+}
+
+func gamePath(id, action string) string {
+	return "/api/v1/games/" + url.PathEscape(id) + "/" + action
+}
+
+// do sends a request with an optional JSON body and decodes the response
+// into out.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil { return err }
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil { return err }
+	if body != nil { req.Header.Set("Content-Type", "application/json") }
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 { return readError(resp) }
+	if out == nil { return nil }
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("snakeandladder: decoding %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// readError turns an error response into an *APIError. Bodies that are not
+// the server's JSON error, as from a proxy, become the message.
+func readError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	e := &APIError{StatusCode: resp.StatusCode}
+	var body struct {
+		Error struct {
+			Code    string                 `json:"code"`
+			Message string                 `json:"message"`
+			Details map[string]interface{} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Code != "" {
+		e.Code, e.Message, e.Details = body.Error.Code, body.Error.Message, body.Error.Details
+		return e
+	}
+	if e.Message = strings.TrimSpace(string(data)); e.Message == "" { e.Message = http.StatusText(resp.StatusCode) }
+	return e
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCreateGameSendsOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/games" || q.Get("pawns") != "2" || q.Get("collision") != "capture" || q.Get("seed") != "9" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if string(body) != `{"width":4,"height":3}` { t.Errorf("unexpected body %s", body) }
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"7"}`))
+	}))
+	defer ts.Close()
+	seed := int64(9)
+	id, err := New(ts.URL+"/").CreateGame(context.Background(), GameOptions{
+		Board: &BoardDef{Width: 4, Height: 3},
+		Rules: Rules{Pawns: 2, Collision: CollisionCapture},
+		Seed:  &seed,
+	})
+	if err != nil || id != "7" { t.Fatalf("create game: %q %v", id, err) }
+}
+
+func TestErrorsAreTyped(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/games/1/roll" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"code":"not_enough_players","message":"need at least 2 players","details":{"players":1}}}`))
+			return
+		}
+		http.Error(w, "upstream down", http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	_, err := c.Roll(context.Background(), "1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || apiErr.Message != "need at least 2 players" || apiErr.Details["players"] != 1.0 {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if !errors.Is(err, ErrNotEnoughPlayers) || errors.Is(err, ErrNotHost) { t.Fatalf("error %v should match by code", err) }
+	_, err = c.State(context.Background(), "1")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Message != "upstream down" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestRollDecodesMove(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"roll":4,"move":{"player":"A","pawn":0,"roll":4,"from":0,"landed":4,"to":14,"moved":true,"steps":[{"kind":"ladder","from":4,"to":14}]},"winner":"A"}`))
+	}))
+	defer ts.Close()
+	res, err := New(ts.URL).Roll(context.Background(), "1")
+	if err != nil { t.Fatalf("roll: %v", err) }
+	if res.Roll != 4 || res.Move.To != 14 || len(res.Move.Steps) != 1 || res.Winner != "A" { t.Fatalf("unexpected result %+v", res) }
+}
+
+func TestStateDecodesBoard(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"layout":"serpentine","players":[{"name":"A","position":{"x":1,"y":8,"total":11,"square":12}}],` +
+			`"snakes":[{"head":{"x":2,"y":3,"total":23},"tail":{"x":0,"y":4,"total":4}}],"ladders":[{"top":{"x":3,"y":0,"total":30},"bottom":{"x":0,"y":1,"total":1}}],"turnIndex":0}`))
+	}))
+	defer ts.Close()
+	st, err := New(ts.URL).State(context.Background(), "1")
+	if err != nil { t.Fatalf("state: %v", err) }
+	var pos Point = st.Players[0].Position
+	if pos.X() != 1 || pos.Y() != 8 || pos.Square() != 12 { t.Fatalf("position %d,%d on %d", pos.X(), pos.Y(), pos.Square()) }
+	if s := st.Snakes[0]; s.Head().Square() != 24 || s.Tail().Y() != 4 { t.Fatalf("snake %+v", s) }
+	if l := st.Ladders[0]; l.Bottom().Square() != 2 || l.Top().X() != 3 { t.Fatalf("ladder %+v", l) }
+}
+
+func TestStreamReconnects(t *testing.T) {
+	var conns int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&conns, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"turnIndex\":%d,\"players\":[]}\n\n", n)
+		if n == 1 {
+			// first connection drops after a rollback
+			fmt.Fprint(w, "event: rollback\ndata: {\"player\":\"A\",\"roll\":3}\n\n")
+			return
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := New(ts.URL, WithReconnectDelay(10*time.Millisecond)).Stream(ctx, "1")
+	if err != nil { t.Fatalf("stream: %v", err) }
+	next := func() Event {
+		select {
+		case ev := <-ch:
+			return ev
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+		return Event{}
+	}
+	if ev := next(); ev.State == nil || ev.State.TurnIndex != 1 { t.Fatalf("expected first state, got %+v", ev) }
+	if ev := next(); ev.Name != "rollback" || ev.Move == nil || ev.Move.Roll != 3 { t.Fatalf("expected rollback, got %+v", ev) }
+	if ev := next(); ev.State == nil || ev.State.TurnIndex != 2 { t.Fatalf("expected state after reconnecting, got %+v", ev) }
+	cancel()
+	for range ch {
+	}
+}
+
+func TestStreamUnknownGame(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": "game_not_found", "message": "game not found"}})
+	}))
+	defer ts.Close()
+	_, err := New(ts.URL).Stream(context.Background(), "nope")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "game_not_found" { t.Fatalf("unexpected error %v", err) }
+}
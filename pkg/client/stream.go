@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Event is one message from a game stream. Name is empty for state updates,
+// which set State; a "rollback" sets Move to the move taken back. Data holds
+// the raw payload of every event, including ones this package does not know.
+type Event struct {
+	Name  string
+	State *State
+	Move  *Move
+	Data  json.RawMessage
+}
+
+const (
+	defaultReconnect = 500 * time.Millisecond
+	maxReconnect     = 10 * time.Second
+	maxEventSize     = 4 << 20
+)
+
+// WithReconnectDelay sets the first wait before reconnecting a dropped
+// stream; later attempts back off up to 10 seconds.
+func WithReconnectDelay(d time.Duration) Option { return func(c *Client) { c.reconnect = d } }
+
+// Stream subscribes to a game's events. The first connection is made before
+// Stream returns; when it drops the client reconnects, and the server
+// resends the current state. The channel is closed once ctx is done or the
+// server refuses to reconnect, for instance because the game is gone.
+func (c *Client) Stream(ctx context.Context, id string) (<-chan Event, error) {
+	resp, err := c.openStream(ctx, id)
+	if err != nil { return nil, err }
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		delay := c.reconnectDelay()
+		for {
+			if c.readStream(ctx, resp, ch) { delay = c.reconnectDelay() }
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(delay):
+				}
+				if delay *= 2; delay > maxReconnect { delay = maxReconnect }
+				resp, err = c.openStream(ctx, id)
+				var apiErr *APIError
+				if errors.As(err, &apiErr) && apiErr.StatusCode < 500 { return }
+				if err == nil { break }
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (c *Client) reconnectDelay() time.Duration {
+	if c.reconnect > 0 { return c.reconnect }
+	return defaultReconnect
+}
+
+func (c *Client) openStream(ctx context.Context, id string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+gamePath(id, "stream"), nil)
+	if err != nil { return nil, err }
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.http.Do(req)
+	if err != nil { return nil, err }
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, readError(resp)
+	}
+	return resp, nil
+}
+
+// readStream forwards the events of one connection until it ends and
+// reports whether any arrived.
+func (c *Client) readStream(ctx context.Context, resp *http.Response, ch chan<- Event) bool {
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64<<10), maxEventSize)
+	got := false
+	var name string
+	var data []string
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if len(data) == 0 { continue }
+			ev := decodeEvent(name, strings.Join(data, "\n"))
+			name, data = "", nil
+			select {
+			case ch <- ev:
+				got = true
+			case <-ctx.Done():
+				return got
+			}
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return got
+}
+
+func decodeEvent(name, data string) Event {
+	ev := Event{Name: name, Data: json.RawMessage(data)}
+	switch name {
+	case "":
+		var st State
+		if json.Unmarshal(ev.Data, &st) == nil { ev.State = &st }
+	case "rollback":
+		var m Move
+		if json.Unmarshal(ev.Data, &m) == nil { ev.Move = &m }
+	}
+	return ev
+}
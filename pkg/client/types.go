@@ -0,0 +1,68 @@
+package client
+
+import "github.com/arsulegai/snakeandladder/internal/game"
+
+// Types shared with the server. Callers outside this module cannot import
+// the game package, so everything a response holds is named here.
+type (
+	State    = game.State
+	Move     = game.Move
+	Step     = game.Step
+	StepKind = game.StepKind
+	Player   = game.Player
+	Team     = game.Team
+	// Point is a board cell: X and Y place it, Square numbers it.
+	Point         = game.Point
+	Snake         = game.Snake
+	Ladder        = game.Ladder
+	Tile          = game.Tile
+	TileEffect    = game.TileEffect
+	Shape         = game.Shape
+	Rules         = game.Rules
+	CollisionRule = game.CollisionRule
+	TeamWinRule   = game.TeamWinRule
+	UndoRule      = game.UndoRule
+	Layout        = game.Layout
+	BoardDef      = game.BoardDef
+	Jump          = game.Jump
+	Snapshot      = game.Snapshot
+	SavedTurn     = game.SavedTurn
+	SavedPlayer   = game.SavedPlayer
+	DiceState     = game.DiceState
+	UndoResult    = game.UndoResult
+	PendingMove   = game.PendingMove
+)
+
+// Values of the types above, as the server sends them.
+const (
+	LayoutRowMajor   = game.LayoutRowMajor
+	LayoutSerpentine = game.LayoutSerpentine
+	LayoutPath       = game.LayoutPath
+
+	CollisionIgnore  = game.CollisionIgnore
+	CollisionCapture = game.CollisionCapture
+	CollisionSwap    = game.CollisionSwap
+	TeamWinAny       = game.TeamWinAny
+	TeamWinAll       = game.TeamWinAll
+	UndoHost         = game.UndoHost
+	UndoVote         = game.UndoVote
+	UndoOff          = game.UndoOff
+
+	TileTeleport   = game.TileTeleport
+	TileSkipTurn   = game.TileSkipTurn
+	TileExtraRoll  = game.TileExtraRoll
+	TileSwapLeader = game.TileSwapLeader
+	TileShield     = game.TileShield
+
+	StepSnake      = game.StepSnake
+	StepLadder     = game.StepLadder
+	StepTeleport   = game.StepTeleport
+	StepShield     = game.StepShield
+	StepGainShield = game.StepGainShield
+	StepSkipTurn   = game.StepSkipTurn
+	StepExtraRoll  = game.StepExtraRoll
+	StepSwap       = game.StepSwap
+	StepSkipped    = game.StepSkipped
+	StepCapture    = game.StepCapture
+	StepBump       = game.StepBump
+)
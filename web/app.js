@@ -1,29 +1,55 @@
 const $ = (sel) => document.querySelector(sel);
+// apiError turns an error response into an Error carrying the server's code.
+async function apiError(res, fallback) {
+  let body = {};
+  try { body = (await res.json()).error || {}; } catch (_) { /* not JSON */ }
+  const err = new Error(body.message || fallback);
+  err.code = body.code;
+  err.details = body.details;
+  return err;
+}
 const api = {
-  async createGame(grid) {
-    const res = await fetch(`/api/games?grid=${grid}`, { method: 'POST' });
-    if (!res.ok) throw new Error('Failed to create game');
+  async createGame(grid, layout, boardDef, pawns, collision, teamWin) {
+    const opts = { method: 'POST' };
+    if (boardDef) { opts.headers = { 'Content-Type': 'application/json' }; opts.body = boardDef; }
+    const res = await fetch(`/api/v1/games?grid=${grid}&layout=${encodeURIComponent(layout || 'serpentine')}&pawns=${pawns || 1}&collision=${encodeURIComponent(collision || 'ignore')}&teamWin=${encodeURIComponent(teamWin || 'any')}`, opts);
+    if (!res.ok) throw await apiError(res, 'Failed to create game');
     const j = await res.json();
     return j.id;
   },
-  async addPlayer(id, name) {
-    const res = await fetch(`/api/games/${id}/players`, {
-      method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ name })
+  async addPlayer(id, name, team) {
+    const res = await fetch(`/api/v1/games/${id}/players`, {
+      method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ name, team })
     });
-    if (!res.ok) throw new Error((await res.json()).error || 'Failed to add player');
+    if (!res.ok) throw await apiError(res, 'Failed to add player');
     return res.json();
   },
   async roll(id) {
-    const res = await fetch(`/api/games/${id}/roll`, { method: 'POST' });
-    if (!res.ok) throw new Error((await res.json()).error || 'Failed to roll');
+    const res = await fetch(`/api/v1/games/${id}/roll`, { method: 'POST' });
+    if (!res.ok) throw await apiError(res, 'Failed to roll');
+    return res.json();
+  },
+  async move(id, pawn) {
+    const res = await fetch(`/api/v1/games/${id}/move`, {
+      method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ pawn })
+    });
+    if (!res.ok) throw await apiError(res, 'Failed to move');
     return res.json();
   },
   async state(id) {
-    const res = await fetch(`/api/games/${id}/state`); return res.json();
+    const res = await fetch(`/api/v1/games/${id}/state`); return res.json();
+  },
+  async undo(id, player) {
+    const res = await fetch(`/api/v1/games/${id}/undo`, {
+      method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ player })
+    });
+    if (!res.ok) throw await apiError(res, 'Failed to undo');
+    return res.json();
   },
-  stream(id, cb) {
-    const es = new EventSource(`/api/games/${id}/stream`);
+  stream(id, cb, onRollback) {
+    const es = new EventSource(`/api/v1/games/${id}/stream`);
     es.onmessage = (ev) => { cb(JSON.parse(ev.data)); };
+    if (onRollback) es.addEventListener('rollback', (ev) => { onRollback(JSON.parse(ev.data)); });
     return es;
   }
 };
@@ -138,6 +164,7 @@ let lastWinPlayed = null;
 let diceAnimating = false;
 let pendingState = null;
 let renderOverrides = null; // used by global animation loop during tweens
+let animGen = 0; // bumped to cancel running tweens
 let shakeFX = { type: null, start: 0, dur: 0, amp: 0 };
 
 const canvas = $('#board');
@@ -154,24 +181,54 @@ const snakeThemes = [
 ];
 
 // coordinate helpers (bottom-left origin)
+// board shape sent by the server: {width, height, layout, squares, cells?}
+function boardOf(state){
+  if (state && state.board && state.board.width) return state.board;
+  const N = (state && state.gridSize) || 10;
+  return { width: N, height: N, layout: (state && state.layout) || 'serpentine', squares: N*N };
+}
+
 function gridToCanvas(x, y, cell){
-  // server uses top-left origin with total = x*N + y (row-major, top to bottom)
-  // map using total to bottom-left serpentine coordinates
-  const N = gameState ? gameState.gridSize : 10;
-  const total = x*N + y;
-  const rb = Math.floor(total / N); // row from bottom
-  const c = total % N;
-  const col = (rb % 2 === 0) ? c : (N - 1 - c);
-  const cx = col*cell + cell/2;
-  const cy = (N - 1 - rb)*cell + cell/2; // canvas Y grows downward; rb is bottom-based
+  // server sends x = row counted from the bottom, y = column from the left;
+  // the layout (row-major, serpentine or path) is already applied by the engine
+  const B = boardOf(gameState);
+  const cx = y*cell + cell/2;
+  const cy = (B.height - 1 - x)*cell + cell/2; // canvas Y grows downward
   return {cx, cy};
 }
 
+// 1-based square number -> {x, y} cell, mirroring the engine's layout
+function squareToCell(square, B){
+  if (B.cells) { const c = B.cells[square - 1]; return {x: c[0], y: c[1]}; }
+  const idx = square - 1;
+  const x = Math.floor(idx / B.width);
+  let y = idx % B.width;
+  if (B.layout === 'serpentine' && x % 2 === 1) y = B.width - 1 - y;
+  return {x, y};
+}
+
+// {x, y} cell -> 1-based square number, or 0 if the cell is off the path
+function cellToSquare(x, y, B){
+  if (B.cells) {
+    if (!B.squareIndex) {
+      B.squareIndex = new Map(B.cells.map((c, i)=>[c[0]+','+c[1], i+1]));
+    }
+    return B.squareIndex.get(x+','+y) || 0;
+  }
+  const c = (B.layout === 'serpentine' && x % 2 === 1) ? (B.width - 1 - y) : y;
+  return x*B.width + c + 1;
+}
+
+// side of one cell in canvas pixels
+function cellSize(B){ return Math.min(canvas.width / B.width, canvas.height / B.height); }
+
 function drawBoard(state, overrides) {
-  const N = state.gridSize;
+  const B = boardOf(state);
+  const N = Math.max(B.width, B.height);
   const W = canvas.width, H = canvas.height;
   ctx.clearRect(0, 0, W, H);
-  const cell = W / N;
+  const cell = cellSize(B);
+  const BW = cell*B.width, BH = cell*B.height;
   const now = performance.now();
   // apply board shake FX
   ctx.save();
@@ -190,42 +247,59 @@ function drawBoard(state, overrides) {
     ctx.translate(dx, dy);
     if (t >= 1){ shakeFX.type = null; }
   }
-  // colorful checkerboard background
+  // colorful checkerboard background, only on cells that belong to the path
   const palette = ['#fef08a','#c7d2fe','#f5d0fe','#bbf7d0'];
-  for (let r=0; r<N; r++){
-    for (let c=0; c<N; c++){
+  for (let r=0; r<B.height; r++){
+    for (let c=0; c<B.width; c++){
+      if (B.cells && !cellToSquare(B.height-1-r, c, B)) continue;
       const idx = (r+c)%palette.length;
       fillCell3D(c*cell, r*cell, cell, palette[idx]);
     }
   }
-  // embossed grid lines for 3D feel (light up/left, dark down/right)
-  ctx.save();
-  ctx.translate(-0.5, -0.5);
-  ctx.strokeStyle = 'rgba(255,255,255,0.65)'; ctx.lineWidth = 1.5;
-  for (let i = 0; i <= N; i++) {
-    ctx.beginPath(); ctx.moveTo(i*cell, 0); ctx.lineTo(i*cell, H); ctx.stroke();
-    ctx.beginPath(); ctx.moveTo(0, i*cell); ctx.lineTo(W, i*cell); ctx.stroke();
-  }
-  ctx.restore();
-  ctx.save();
-  ctx.translate(0.5, 0.5);
-  ctx.strokeStyle = 'rgba(15,23,42,0.18)'; ctx.lineWidth = 1.5;
-  for (let i = 0; i <= N; i++) {
-    ctx.beginPath(); ctx.moveTo(i*cell, 0); ctx.lineTo(i*cell, H); ctx.stroke();
-    ctx.beginPath(); ctx.moveTo(0, i*cell); ctx.lineTo(W, i*cell); ctx.stroke();
+  // embossed grid lines for 3D feel (light up/left, dark down/right); path
+  // boards rely on the per-cell bevel instead
+  if (!B.cells){
+    ctx.save();
+    ctx.translate(-0.5, -0.5);
+    ctx.strokeStyle = 'rgba(255,255,255,0.65)'; ctx.lineWidth = 1.5;
+    for (let i = 0; i <= B.width; i++) { ctx.beginPath(); ctx.moveTo(i*cell, 0); ctx.lineTo(i*cell, BH); ctx.stroke(); }
+    for (let i = 0; i <= B.height; i++) { ctx.beginPath(); ctx.moveTo(0, i*cell); ctx.lineTo(BW, i*cell); ctx.stroke(); }
+    ctx.restore();
+    ctx.save();
+    ctx.translate(0.5, 0.5);
+    ctx.strokeStyle = 'rgba(15,23,42,0.18)'; ctx.lineWidth = 1.5;
+    for (let i = 0; i <= B.width; i++) { ctx.beginPath(); ctx.moveTo(i*cell, 0); ctx.lineTo(i*cell, BH); ctx.stroke(); }
+    for (let i = 0; i <= B.height; i++) { ctx.beginPath(); ctx.moveTo(0, i*cell); ctx.lineTo(BW, i*cell); ctx.stroke(); }
+    ctx.restore();
   }
-  ctx.restore();
-  // labels with bottom-left origin, serpentine (alternating) numbering
+  // labels with bottom-left origin, numbered per the game's layout
   ctx.fillStyle = '#94a3b8'; ctx.font = `${Math.max(10, cell*0.25)}px Poppins, sans-serif`;
-  for (let r = 0; r < N; r++) {
-    for (let c = 0; c < N; c++) {
-      const rb = N-1 - r; // row index from bottom
-      const col = (rb % 2 === 0) ? c : (N-1 - c);
-      const idx = rb*N + col; // 0-based index
+  for (let r = 0; r < B.height; r++) {
+    for (let c = 0; c < B.width; c++) {
+      const rb = B.height-1 - r; // row index from bottom
+      const sq = cellToSquare(rb, c, B);
+      if (!sq) continue;
       const x = c*cell + 6, y = r*cell + cell - 8;
-      ctx.fillText(String(idx+1), x, y);
+      ctx.fillText(String(sq), x, y);
     }
   }
+  // special squares
+  const tileIcons = { 'teleport': '🌀', 'skip-turn': '⏸️', 'extra-roll': '🎲', 'swap-leader': '🔄', 'shield': '🛡️' };
+  ctx.save();
+  ctx.textAlign = 'center'; ctx.textBaseline = 'middle';
+  ctx.font = `${Math.max(12, cell*0.42)}px sans-serif`;
+  (state.tiles || []).forEach(t=>{
+    const c = squareToCell(t.square, B);
+    const {cx, cy} = gridToCanvas(c.x, c.y, cell);
+    ctx.globalAlpha = 0.85;
+    ctx.fillText(tileIcons[t.effect] || '✨', cx, cy);
+    if (t.effect === 'teleport'){
+      ctx.globalAlpha = 1; ctx.fillStyle = '#6d28d9'; ctx.font = `${Math.max(9, cell*0.2)}px Poppins, sans-serif`;
+      ctx.fillText(`→${t.target}`, cx, cy + cell*0.32);
+      ctx.font = `${Math.max(12, cell*0.42)}px sans-serif`;
+    }
+  });
+  ctx.restore();
   // snakes - varied shapes with patterns
   state.snakes.forEach((s, i) => { drawSnake(s.head, s.tail, cell, i, N, now); });
   // ladders - colorful wood
@@ -233,14 +307,18 @@ function drawBoard(state, overrides) {
   // players (chess-like pawns)
   const colors = ['#10b981','#3b82f6','#a855f7','#f59e0b','#ef4444','#14b8a6'];
   state.players.forEach((p, i) => {
-    if (p.position.x < 0) return;
-    const ov = overrides || renderOverrides;
-    const pos = ov && ov[i] ? ov[i] : p.position;
-    const {cx, cy} = gridToCanvas(pos.x, pos.y, cell);
-    drawChessPawn(cx, cy, cell, colors[i % colors.length]);
-    // overlay initial letter badge for readability
+    const pawns = p.pawns && p.pawns.length ? p.pawns : [p.position];
     const init = (p.name && p.name.length) ? p.name[0].toUpperCase() : String(i+1);
-    drawPawnLabel(cx, cy, cell, init);
+    pawns.forEach((pawn, j) => {
+      if (pawn.x < 0) return;
+      const ov = overrides || renderOverrides;
+      const key = `${i}:${j}`;
+      const pos = ov && ov[key] ? ov[key] : pawn;
+      const {cx, cy} = gridToCanvas(pos.x, pos.y, cell);
+      drawChessPawn(cx, cy, cell, colors[i % colors.length]);
+      // overlay initial letter badge (plus pawn number) for readability
+      drawPawnLabel(cx, cy, cell, pawns.length > 1 ? `${init}${j+1}` : init);
+    });
   });
   ctx.restore(); // for shake transform
 }
@@ -421,75 +499,59 @@ function updateUI(state){
   const prev = gameState;
   if (diceAnimating){ pendingState = state; return; }
   gameState = state;
-  const N = state.gridSize;
-  const cell = canvas.width / N;
+  const B = boardOf(state);
+  const PB = prev ? boardOf(prev) : null;
+  if ((!PB || PB.width !== B.width || PB.height !== B.height) && window.resizeBoard) window.resizeBoard();
+  const cell = cellSize(B);
   let animated = false;
-  if (prev && prev.players.length === state.players.length){
-    const mover = (state.turnIndex - 1 + state.players.length) % state.players.length;
+  const mv = state.lastMove;
+  // only animate moves forward; a rollback jumps straight to the restored state
+  const newMove = prev && mv && prev.players.length === state.players.length && (state.moves || 0) > (prev.moves || 0);
+  if (newMove){
+    // the move tells us who moved and every hop, so extra rolls and skipped
+    // turns don't confuse the animation
+    const mover = state.players.findIndex(p=>p.name === mv.player);
     const pPrev = prev.players[mover];
     const pNow = state.players[mover];
-    if (pPrev && pNow && (pPrev.Position ? pPrev.Position : pPrev.position) && (pNow.Position ? pNow.Position : pNow.position)){
-      const prevPos = pPrev.Position || pPrev.position;
-      const nowPos = pNow.Position || pNow.position;
+    const pawnOf = (p)=> p.pawns && p.pawns.length ? p.pawns[mv.pawn] : p.position;
+    if (pPrev && pNow){
+      const prevPos = pawnOf(pPrev);
+      const nowPos = pawnOf(pNow);
       if (prevPos.x !== nowPos.x || prevPos.y !== nowPos.y){
         animated = true;
         const ease = t=>t<0.5?4*t*t*t:1-Math.pow(-2*t+2,3)/2;
+        const gen = animGen;
         const tween = (from, to, onDone, dur=600)=>{
           const start = performance.now();
           const step = (ts)=>{
+            if (gen !== animGen) return; // cancelled by a rollback
             const t = Math.min(1, (ts-start)/dur); const et = ease(t);
             const ix = from.x + (to.x - from.x)*et; const iy = from.y + (to.y - from.y)*et;
             if (!renderOverrides) renderOverrides = {};
-            renderOverrides[mover] = {x: ix, y: iy};
+            renderOverrides[`${mover}:${mv.pawn}`] = {x: ix, y: iy};
             if (t < 1) requestAnimationFrame(step); else { renderOverrides = null; onDone && onDone(); }
           };
           requestAnimationFrame(step);
         };
-
-        // compute potential intermediate landing (snake/ladder square)
-        const prevTotal = prevPos.x>=0? prevPos.x*N + prevPos.y : -1;
-        const finalTotal = nowPos.x*N + nowPos.y;
-        const landing = prevTotal >= 0 ? Math.min(N*N-1, prevTotal + (state.lastRoll||0)) : (state.lastRoll||0) - 1;
-        const landingPos = { x: Math.floor(landing / N), y: landing % N };
-        // map snakes/ladders to totals
-        const headTotals = new Set(state.snakes.map(s=>s.head.x*N + s.head.y));
-        const tailTotals = new Map(state.snakes.map(s=>[s.head.x*N+s.head.y, s.tail.x*N+s.tail.y]));
-        const bottomTotals = new Set(state.ladders.map(l=>l.bottom.x*N + l.bottom.y));
-        const topTotals = new Map(state.ladders.map(l=>[l.bottom.x*N+l.bottom.y, l.top.x*N+l.top.y]));
-
-        // Build chained transitions (ladder->ladder or snake->snake sequences)
-        const hops = [landing];
-        let cur = landing;
-        let guard = 0;
-        while (guard++ < 10){
-          if (headTotals.has(cur)) { cur = tailTotals.get(cur); hops.push(cur); continue; }
-          if (bottomTotals.has(cur)) { cur = topTotals.get(cur); hops.push(cur); continue; }
-          break;
-        }
+        const cellOf = (sq)=>squareToCell(sq, B);
+        // one segment for the dice move, then one per snake/ladder/teleport/swap hop
         const segments = [];
-        if (prevTotal >= 0) segments.push({from: prevPos, to: landingPos});
-        for (let i=0;i<hops.length-1;i++){
-          const from = { x: Math.floor(hops[i]/N), y: hops[i]%N };
-          const toT = hops[i+1];
-          const to = { x: Math.floor(toT/N), y: toT%N };
-          segments.push({from, to});
-        }
-        // Final move to nowPos if chain ends before finalTotal
-        if (hops[hops.length-1] !== finalTotal){ segments.push({from: {x: Math.floor(hops[hops.length-1]/N), y: hops[hops.length-1]%N}, to: nowPos}); }
-        // Play sounds on each board interaction during tweening
-        const playFor = (fromTotal)=>{ if (headTotals.has(fromTotal)) playSnakeSound(); else if (bottomTotals.has(fromTotal)) playLadderSound(); };
+        if (mv.from > 0) segments.push({from: prevPos, to: cellOf(mv.landed), kind: 'roll'});
+        let last = mv.landed;
+        (mv.steps || []).forEach(st=>{
+          if (!['snake','ladder','teleport','swap'].includes(st.kind)) return;
+          segments.push({from: cellOf(st.from), to: cellOf(st.to), kind: st.kind});
+          last = st.to;
+        });
+        // Final move to nowPos if the hops end elsewhere
+        if (last !== nowPos.square){ segments.push({from: cellOf(last), to: nowPos, kind: 'roll'}); }
         const runSegments = (k)=>{
           if (k>=segments.length){ return; }
           const seg = segments[k];
-          // figure total index for sound
-          const tFrom = seg.from.x*N + seg.from.y;
-          if (k>0) playFor(tFrom);
           // slow, dramatic motion on interactions and trigger board shake
           let dur = 600;
-          if (k>0){
-            if (headTotals.has(tFrom)) { shakeFX = {type:'snake', start: performance.now(), dur: 900, amp: Math.max(6, cell*0.12)}; dur = 900; }
-            if (bottomTotals.has(tFrom)) { shakeFX = {type:'ladder', start: performance.now(), dur: 900, amp: Math.max(5, cell*0.1)}; dur = 900; }
-          }
+          if (seg.kind === 'snake') { playSnakeSound(); shakeFX = {type:'snake', start: performance.now(), dur: 900, amp: Math.max(6, cell*0.12)}; dur = 900; }
+          if (seg.kind === 'ladder' || seg.kind === 'teleport') { playLadderSound(); shakeFX = {type:'ladder', start: performance.now(), dur: 900, amp: Math.max(5, cell*0.1)}; dur = 900; }
           tween(seg.from, seg.to, ()=>runSegments(k+1), dur);
         };
         runSegments(0);
@@ -502,19 +564,43 @@ function updateUI(state){
   }
   const playersDiv = $('#players');
   playersDiv.innerHTML = '';
-  state.players.forEach((p,i)=>{
+  // in team games, list players under their team heading
+  const order = state.players.map((p,i)=>i);
+  if (state.teams && state.teams.length){
+    order.sort((a,b)=>{
+      const ta = state.teams.findIndex(t=>t.name === state.players[a].team);
+      const tb = state.teams.findIndex(t=>t.name === state.players[b].team);
+      return ta - tb || a - b;
+    });
+  }
+  let lastTeam = null;
+  order.forEach(i=>{
+    const p = state.players[i];
+    if (p.team && p.team !== lastTeam){
+      const h = document.createElement('div'); h.className = 'team-name';
+      const t = state.teams.find(t=>t.name === p.team);
+      h.textContent = `${p.team}${t && t.finished ? ` (${t.finished.length}/${t.members.length} home)` : ''}`;
+      playersDiv.appendChild(h); lastTeam = p.team;
+    }
     const el = document.createElement('div');
     el.className = 'player';
-    const total = (typeof p.position.total === 'number' && p.position.total >= 0) ? p.position.total : (p.position.x>=0? (p.position.x*state.gridSize + p.position.y): -1);
-    const square = total >= 0 ? (total+1) : 'Start';
-    el.textContent = `${i===state.turnIndex ? '👉 ' : ''}${p.name} — ${square}`;
+    const sq = (pos)=> pos.square > 0 ? pos.square : 'Start';
+    const square = p.pawns && p.pawns.length > 1 ? p.pawns.map(sq).join(' · ') : sq(p.position);
+    const badges = (p.shield ? ' 🛡️' : '') + (p.skipTurns ? ' ⏸️' : '');
+    el.textContent = `${i===state.turnIndex ? '👉 ' : ''}${p.name} — ${square}${badges}`;
     playersDiv.appendChild(el);
   });
   // Turn box is redundant; leave empty to hide via CSS
   $('#turn').textContent = '';
   $('#last').textContent = state.lastRoll ? `Last Roll: ${state.lastRoll}` : '';
+  // call out collisions from the last move
+  const hits = ((state.lastMove && state.lastMove.steps) || []).filter(st=>st.kind === 'capture' || st.kind === 'bump');
+  hits.forEach(st=>{
+    const what = st.kind === 'capture' ? `sent ${st.player} back to start` : `bumped ${st.player} to ${st.to || 'start'}`;
+    $('#last').textContent += ` · ${state.lastMove.player} ${what}`;
+  });
   if (state.winner){
-    $('#winner').textContent = `Winner: ${state.winner} 🎉`;
+    $('#winner').textContent = state.winningTeam ? `Winner: team ${state.winningTeam} (${state.winner}) 🎉` : `Winner: ${state.winner} 🎉`;
     $('#rollBtn').disabled = true;
     if (lastWinPlayed !== state.winner){
       playApplauseSound();
@@ -528,6 +614,51 @@ function updateUI(state){
     lastWinPlayed = null;
     const ng = $('#newGameBtn'); if (ng) ng.style.display = 'none';
   }
+  const undoBtn = $('#undoBtn');
+  if (undoBtn){
+    undoBtn.style.display = state.rules && state.rules.undo === 'off' ? 'none' : 'inline-block';
+    undoBtn.disabled = !state.undoable;
+    undoBtn.textContent = state.undoVotes && state.undoVotes.length ? `Undo (${state.undoVotes.length}/${state.players.length})` : 'Undo';
+  }
+  renderPawnChoice(state);
+}
+
+// a move was taken back: stop any animation of it
+function onRollback(move){
+  animGen++;
+  renderOverrides = null;
+  shakeFX.type = null;
+  console.log('[Undo] rolled back move by', move.player);
+}
+
+// pawn picker shown while a roll waits for the player to choose a pawn
+let choiceTimer = null;
+function renderPawnChoice(state){
+  const box = $('#pawnChoice');
+  if (!box) return;
+  clearInterval(choiceTimer); choiceTimer = null;
+  const pm = state.pending;
+  box.innerHTML = '';
+  if (!pm){ box.style.display = 'none'; return; }
+  box.style.display = 'block';
+  const title = document.createElement('div');
+  const tick = ()=>{
+    const left = Math.max(0, Math.ceil((new Date(pm.deadline) - Date.now())/1000));
+    title.textContent = `${pm.player} rolled ${pm.roll} — pick a pawn (${left}s)`;
+  };
+  tick(); choiceTimer = setInterval(tick, 500);
+  box.appendChild(title);
+  const player = state.players.find(p=>p.name === pm.player);
+  pm.pawns.forEach(i=>{
+    const b = document.createElement('button');
+    const at = player && player.pawns ? player.pawns[i].square : 0;
+    b.textContent = `Pawn ${i+1} (${at > 0 ? at : 'Start'})`;
+    b.onclick = async ()=>{
+      try { await api.move(gameId, i); } catch (e){ alert(e.message); }
+    };
+    box.appendChild(b);
+  });
+  $('#rollBtn').disabled = true;
 }
 
 // Dice: CSS 3D cube
@@ -626,7 +757,10 @@ function setupStartModal(){
   const inputsWrap = $('#playerInputs');
   let starting = false;
   addBtn.onclick = () => {
-    const inp = document.createElement('input'); inp.placeholder = `Player ${inputsWrap.children.length+1}`; inputsWrap.appendChild(inp);
+    const row = document.createElement('div'); row.className = 'player-row';
+    const inp = document.createElement('input'); inp.className = 'name'; inp.placeholder = `Player ${inputsWrap.children.length+1}`;
+    const team = document.createElement('input'); team.className = 'team'; team.placeholder = 'Team (optional)';
+    row.appendChild(inp); row.appendChild(team); inputsWrap.appendChild(row);
   };
   const startBtn = $('#startBtn');
   startBtn.onclick = async () => {
@@ -635,21 +769,31 @@ function setupStartModal(){
     startBtn.disabled = true;
     try {
       const grid = Number($('#gridInput').value) || 10;
-      let names = Array.from(inputsWrap.querySelectorAll('input')).map(i=>i.value.trim());
-      names = names.filter(Boolean);
-      if (names.length === 0) { names = ['Player 1','Player 2']; }
-      if (names.length === 1) { names.push('Player 2'); }
-      console.log('[Start] creating game with grid', grid, 'players', names);
-      const id = await api.createGame(grid); gameId = id; $('#gameId').textContent = `Game ID: ${id}`;
+      const layout = $('#layoutInput').value;
+      const file = $('#boardInput').files[0];
+      const boardDef = file ? await file.text() : null;
+      const pawns = Number($('#pawnsInput').value) || 1;
+      const collision = $('#collisionInput').value;
+      const teamWin = $('#teamWinInput').value;
+      let entries = Array.from(inputsWrap.querySelectorAll('.player-row')).map(r=>({
+        name: r.querySelector('.name').value.trim(), team: r.querySelector('.team').value.trim()
+      }));
+      entries = entries.filter(e=>e.name);
+      if (entries.length === 0) { entries = [{name: 'Player 1', team: ''}, {name: 'Player 2', team: ''}]; }
+      if (entries.length === 1) { entries.push({name: 'Player 2', team: ''}); }
+      const anyTeam = entries.some(e=>e.team);
+      if (anyTeam && entries.some(e=>!e.team)) throw new Error('Give every player a team, or leave all teams empty.');
+      console.log('[Start] creating game with grid', grid, 'players', entries);
+      const id = await api.createGame(grid, layout, boardDef, pawns, collision, teamWin); gameId = id; $('#gameId').textContent = `Game ID: ${id}`;
       console.log('[Start] game created id=', id);
       // add players sequentially with logs to diagnose any hang
-      for (const n of names){
-        console.log('[Start] adding player', n);
-        await withTimeout(api.addPlayer(id, n), 10000);
-        console.log('[Start] added player', n);
+      for (const e of entries){
+        console.log('[Start] adding player', e.name);
+        await withTimeout(api.addPlayer(id, e.name, e.team), 10000);
+        console.log('[Start] added player', e.name);
       }
       console.log('[Start] players added');
-      if (es) es.close(); es = api.stream(id, updateUI); console.log('[Start] sse subscribed');
+      if (es) es.close(); es = api.stream(id, updateUI, onRollback); console.log('[Start] sse subscribed');
       const st = await api.state(id); console.log('[Start] initial state', st); updateUI(st);
       modal.classList.remove('visible');
       // enable rolling if we have players
@@ -682,6 +826,22 @@ function main(){
   // new game button opens the modal
   const ng = $('#newGameBtn');
   if (ng){ ng.onclick = () => { const m = $('#startModal'); if (m) m.classList.add('visible'); }; }
+  const undoBtn = $('#undoBtn');
+  if (undoBtn){
+    undoBtn.onclick = async () => {
+      if (!gameId || !gameState) return;
+      // the host undoes directly; in vote mode each player has to ask
+      let player = gameState.players.length ? gameState.players[0].name : '';
+      if (gameState.rules && gameState.rules.undo === 'vote'){
+        player = prompt('Who is asking to undo the last move?', '');
+        if (!player) return;
+      }
+      try {
+        const res = await api.undo(gameId, player);
+        if (!res.undone) $('#last').textContent = `Undo votes: ${res.votes.length}/${res.needed}`;
+      } catch (e){ alert(e.message); }
+    };
+  }
   $('#rollBtn').onclick = async () => {
     if (!gameId) return;
     try {
@@ -691,14 +851,19 @@ function main(){
       await animateDice(res.roll);
     } catch (e){
       diceAnimating = false;
+      if (e.code === 'pawn_choice_pending'){ $('#last').textContent = 'Pick a pawn to move first'; return; }
       alert(e.message);
     }
   };
   function resize(){
-    const sz = Math.min(window.innerWidth-320, window.innerHeight-180, 820);
-    canvas.width = canvas.height = Math.max(420, sz);
+    const sz = Math.max(420, Math.min(window.innerWidth-320, window.innerHeight-180, 820));
+    // keep cells square: the longer side of the board gets the full size
+    const B = boardOf(gameState);
+    const cell = sz / Math.max(B.width, B.height);
+    canvas.width = Math.round(cell*B.width); canvas.height = Math.round(cell*B.height);
     if (gameState) drawBoard(gameState);
   }
+  window.resizeBoard = resize;
   // build dice immediately to keep consistent look before/after game
   buildDiceCube(); showDiceFace(1);
   window.addEventListener('resize', resize); resize();
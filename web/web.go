@@ -0,0 +1,13 @@
+// Package web holds the browser frontend, built into the server.
+package web
+
+import "embed"
+
+// Files holds index.html, the scripts and styles it loads, and their
+// brotli-compressed copies (name.br). The standard library cannot write
+// brotli, so the copies are made with the brotli tool; run go generate here
+// after changing a script or style.
+//
+//go:generate brotli --force --keep --best app.js style.css
+//go:embed *.html *.js *.css *.br
+var Files embed.FS